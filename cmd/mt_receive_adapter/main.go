@@ -0,0 +1,45 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"knative.dev/eventing/pkg/adapter/v2"
+	secretinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/secret"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/signals"
+
+	mongodbadapter "github.com/googleinterns/knative-source-mongodb/pkg/adapter"
+	"github.com/googleinterns/knative-source-mongodb/pkg/client/injection/informers/sources/v1alpha1/mongodbsource"
+)
+
+func main() {
+	env := adapter.ConstructEnvOrDie(mongodbadapter.NewMultiTenantEnvConfig)
+
+	// The informers only watch the namespace of a namespace-scoped receive adapter.
+	ctx := signals.NewContext()
+	if namespace := mongodbadapter.WatchedNamespace(env); namespace != "" {
+		ctx = injection.WithNamespaceScope(ctx, namespace)
+	}
+	ctx = adapter.WithInjectorEnabled(ctx)
+
+	adapter.MainWithEnv(ctx, "mongodbsource-mt", env, func(ctx context.Context, env adapter.EnvConfigAccessor, ceClient cloudevents.Client) adapter.Adapter {
+		return mongodbadapter.NewMultiTenantAdapter(ctx, env, ceClient, mongodbsource.Get(ctx), secretinformer.Get(ctx).Lister())
+	})
+}
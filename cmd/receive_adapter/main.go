@@ -18,10 +18,13 @@ package main
 
 import (
 	"knative.dev/eventing/pkg/adapter/v2"
+	"knative.dev/pkg/signals"
 
 	mongodbadapter "github.com/googleinterns/knative-source-mongodb/pkg/adapter"
 )
 
 func main() {
-	adapter.Main("mongodbsource", mongodbadapter.NewEnvConfig, mongodbadapter.NewAdapter)
+	// The injected Kubernetes client is used to store the checkpoints.
+	ctx := adapter.WithInjectorEnabled(signals.NewContext())
+	adapter.MainWithContext(ctx, "mongodbsource", mongodbadapter.NewEnvConfig, mongodbadapter.NewAdapter)
 }
@@ -7,6 +7,7 @@ require (
 	github.com/google/go-cmp v0.5.1
 	github.com/stretchr/testify v1.5.1
 	go.mongodb.org/mongo-driver v1.1.2
+	go.opencensus.io v0.22.4
 	go.uber.org/zap v1.15.0
 	k8s.io/api v0.18.7-rc.0
 	k8s.io/apimachinery v0.18.7-rc.0
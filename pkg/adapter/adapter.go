@@ -19,44 +19,146 @@ package adapter
 import (
 	"context"
 	"crypto/md5"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/extensions"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
 	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
 	"github.com/googleinterns/knative-source-mongodb/pkg/utils"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opencensus.io/trace"
 	"go.uber.org/zap"
 	"knative.dev/eventing/pkg/adapter/v2"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	"knative.dev/pkg/logging"
 )
 
+const (
+	// changeSpanName is the name of the span of each change, from its decoding until it is
+	// handled, and sendSpanName the name of its child span of each attempt to send it.
+	changeSpanName = "mongodb.change"
+	sendSpanName   = "mongodb.send"
+
+	// queueSize is the number of changes read from the stream that can wait to be sent.
+	queueSize = 100
+
+	// closeTimeout bounds the time spent closing the change stream and the client on shutdown.
+	closeTimeout = 10 * time.Second
+
+	// minSendRetryDelay and maxSendRetryDelay bound the time waited before sending again an event
+	// the sink did not acknowledge.
+	minSendRetryDelay = time.Second
+	maxSendRetryDelay = time.Minute
+
+	// credentialsCheckInterval is the time between two checks of the mounted credentials, which
+	// the kubelet updates about a minute after their secret changed.
+	credentialsCheckInterval = 10 * time.Second
+)
+
 type envConfig struct {
 	adapter.EnvConfig
 
-	MongoDbCredentialsPath string `envconfig:"MONGODB_CREDENTIALS" required:"true"`
-	Database               string `envconfig:"MONGODB_DATABASE" required:"true"`
-	Collection             string `envconfig:"MONGODB_COLLECTION" required:"false"`
-	CeSourcePrefix         string `envconfig:"CE_SOURCE_PREFIX" required:"true"`
+	MongoDbCredentialsPath string        `envconfig:"MONGODB_CREDENTIALS" required:"true"`
+	SecretKeys             string        `envconfig:"MONGODB_SECRET_KEYS" required:"false"`
+	Connection             string        `envconfig:"MONGODB_CONNECTION" required:"false"`
+	TLS                    bool          `envconfig:"MONGODB_TLS" default:"false"`
+	TLSCAFile              string        `envconfig:"MONGODB_TLS_CA_FILE" required:"false"`
+	TLSCertificateFile     string        `envconfig:"MONGODB_TLS_CERTIFICATE_FILE" required:"false"`
+	TLSKeyFile             string        `envconfig:"MONGODB_TLS_KEY_FILE" required:"false"`
+	Database               string        `envconfig:"MONGODB_DATABASE" required:"true"`
+	Collection             string        `envconfig:"MONGODB_COLLECTION" required:"false"`
+	ClusterName            string        `envconfig:"MONGODB_CLUSTER_NAME" required:"true"`
+	SourceTemplate         string        `envconfig:"MONGODB_SOURCE_TEMPLATE" required:"false"`
+	TraceParentField       string        `envconfig:"MONGODB_TRACE_PARENT_FIELD" required:"false"`
+	CheckpointConfigMap    string        `envconfig:"MONGODB_CHECKPOINT_CONFIGMAP" required:"false"`
+	CheckpointInterval     time.Duration `envconfig:"MONGODB_CHECKPOINT_INTERVAL" default:"5s"`
+	LeaseName              string        `envconfig:"MONGODB_LEASE_NAME" required:"false"`
+	LeaseLabels            string        `envconfig:"MONGODB_LEASE_LABELS" required:"false"`
+	CheckpointKey          string        `envconfig:"MONGODB_CHECKPOINT_KEY" default:"checkpoint"`
+	PartitionCollections   string        `envconfig:"MONGODB_PARTITION_COLLECTIONS" required:"false"`
+	SnapshotMode           string        `envconfig:"MONGODB_SNAPSHOT_MODE" default:"never"`
+	SnapshotBatchSize      int32         `envconfig:"MONGODB_SNAPSHOT_BATCH_SIZE" required:"false"`
+	OnHistoryLost          string        `envconfig:"MONGODB_ON_HISTORY_LOST" default:"fail"`
+	Mode                   string        `envconfig:"MONGODB_MODE" default:"changeStream"`
+	PollField              string        `envconfig:"MONGODB_POLL_FIELD" default:"_id"`
+	PollInterval           time.Duration `envconfig:"MONGODB_POLL_INTERVAL" default:"10s"`
+	PollDetectDeletes      bool          `envconfig:"MONGODB_POLL_DETECT_DELETES" default:"false"`
+	StartAtTime            string        `envconfig:"MONGODB_START_AT_TIME" required:"false"`
+	StartAtResumeToken     string        `envconfig:"MONGODB_START_AT_RESUME_TOKEN" required:"false"`
+	Rewind                 string        `envconfig:"MONGODB_REWIND" required:"false"`
+	ShutdownGracePeriod    time.Duration `envconfig:"MONGODB_SHUTDOWN_GRACE_PERIOD" default:"20s"`
 }
 
 type mongoDbAdapter struct {
-	namespace       string
-	ceClient        cloudevents.Client
+	// name is the name of the source, the default application name of the client.
+	name      string
+	namespace string
+	ceClient  cloudevents.Client
+	// ceSourcePrefix is the prefix of the default CloudEvents source, naming the cluster, and
+	// sourceTemplate overrides the source if set.
 	ceSourcePrefix  string
+	clusterName     string
+	sourceTemplate  *template.Template
 	database        string
 	collection      string
 	credentialsPath string
-	logger          *zap.SugaredLogger
+	// secretKeys are the names of the keys of the credentials, nil for the defaults.
+	secretKeys *v1alpha1.MongoDbSourceSecretKeys
+	// tls enables TLS, with the PEM files of the CA bundle and client certificate if set.
+	tls                                       bool
+	tlsCAFile, tlsCertificateFile, tlsKeyFile string
+	// connection tunes the connections and the reads of the changes, nil for the defaults.
+	connection *v1alpha1.MongoDbSourceConnection
+	// credentialsInterval is the time between two checks of the mounted credentials.
+	credentialsInterval time.Duration
+	// loadCredentials reads the credentials from the API instead of the mounted files if set.
+	loadCredentials func() (*credentials, error)
+	// partitionCollections are the collections watched when the database is partitioned.
+	partitionCollections []string
+	// snapshotMode is when the existing documents are sent, read by batches of snapshotBatchSize.
+	snapshotMode      v1alpha1.SnapshotMode
+	snapshotBatchSize int32
+	// mode is how the changes are read: from a change stream, the oplog, or by polling. When
+	// polling, the documents whose pollField increased are queried every pollInterval, and
+	// deletions are detected if pollDetectDeletes is set.
+	mode              v1alpha1.SourceMode
+	pollField         string
+	pollInterval      time.Duration
+	pollDetectDeletes bool
+	// onHistoryLost is what is done when the change stream cannot be resumed from the checkpoint.
+	onHistoryLost v1alpha1.HistoryLostPolicy
+	// startAt is where the change stream starts when there is no checkpoint.
+	startAt position
+	// createClientFn creates the Mongo client, it is replaced in unit tests.
+	createClientFn mongoclient.CreateFn
+	// traceParentField is the document field that may hold the W3C traceparent of the writer.
+	traceParentField string
+	// checkpointer persists the position of the last change acknowledged by the sink.
+	checkpointer       *checkpointer
+	checkpointInterval time.Duration
+	// shutdownGracePeriod bounds the time spent sending the queued changes once ctx is done.
+	shutdownGracePeriod time.Duration
+	// elector elects the replica watching the change stream, if there is one.
+	elector *elector
+	logger  *zap.SugaredLogger
 }
 
 // dataSource interface to interact with either a mongo.database or a mongo.collection.
 type dataSource interface {
-	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (mongoclient.ChangeStream, error)
 }
 
 // NewEnvConfig creates an empty environement variables configuration.
@@ -70,41 +172,165 @@ func NewAdapter(ctx context.Context, processed adapter.EnvConfigAccessor, ceClie
 	logger := logging.FromContext(ctx)
 	env := processed.(*envConfig)
 
+	var err error
+	var store checkpoint.Store
+	if env.CheckpointConfigMap != "" {
+		store = checkpoint.NewConfigMapStore(kubeclient.Get(ctx), env.Namespace, env.CheckpointConfigMap, env.CheckpointKey)
+	}
+
+	var partitionCollections []string
+	if env.PartitionCollections != "" {
+		if err = json.Unmarshal([]byte(env.PartitionCollections), &partitionCollections); err != nil {
+			logger.Fatalw("Failed to parse the partition collections", zap.Error(err))
+		}
+	}
+
+	var secretKeys *v1alpha1.MongoDbSourceSecretKeys
+	if env.SecretKeys != "" {
+		if err = json.Unmarshal([]byte(env.SecretKeys), &secretKeys); err != nil {
+			logger.Fatalw("Failed to parse the secret keys", zap.Error(err))
+		}
+	}
+
+	var connection *v1alpha1.MongoDbSourceConnection
+	if env.Connection != "" {
+		if err = json.Unmarshal([]byte(env.Connection), &connection); err != nil {
+			logger.Fatalw("Failed to parse the connection options", zap.Error(err))
+		}
+	}
+
+	var sourceTemplate *template.Template
+	if env.SourceTemplate != "" {
+		if sourceTemplate, err = v1alpha1.ParseSourceTemplate(env.SourceTemplate); err != nil {
+			logger.Fatalw("Failed to parse the source template", zap.Error(err))
+		}
+	}
+
+	startAt, err := parseStartAt(env)
+	if err != nil {
+		logger.Fatalw("Failed to parse where the change stream starts", zap.Error(err))
+	}
+
+	checkpointer := newCheckpointer(store)
+	if checkpointer != nil {
+		checkpointer.rewind = env.Rewind
+	}
+
+	var elector *elector
+	if env.LeaseName != "" {
+		elector, err = newElector(kubeclient.Get(ctx), env)
+		if err != nil {
+			logger.Fatalw("Failed to set up leader election", zap.Error(err))
+		}
+	}
+
 	return &mongoDbAdapter{
-		namespace:       env.Namespace,
-		ceClient:        ceClient,
-		database:        env.Database,
-		collection:      env.Collection,
-		ceSourcePrefix:  env.CeSourcePrefix,
-		credentialsPath: env.MongoDbCredentialsPath,
-		logger:          logger,
+		name:                 env.Name,
+		namespace:            env.Namespace,
+		ceClient:             ceClient,
+		database:             env.Database,
+		collection:           env.Collection,
+		ceSourcePrefix:       ceSourcePrefix(env.ClusterName),
+		clusterName:          env.ClusterName,
+		sourceTemplate:       sourceTemplate,
+		credentialsPath:      env.MongoDbCredentialsPath,
+		secretKeys:           secretKeys,
+		tls:                  env.TLS,
+		tlsCAFile:            env.TLSCAFile,
+		tlsCertificateFile:   env.TLSCertificateFile,
+		tlsKeyFile:           env.TLSKeyFile,
+		connection:           connection,
+		credentialsInterval:  credentialsCheckInterval,
+		partitionCollections: partitionCollections,
+		snapshotMode:         v1alpha1.SnapshotMode(env.SnapshotMode),
+		snapshotBatchSize:    env.SnapshotBatchSize,
+		mode:                 v1alpha1.SourceMode(env.Mode),
+		pollField:            env.PollField,
+		pollInterval:         env.PollInterval,
+		pollDetectDeletes:    env.PollDetectDeletes,
+		onHistoryLost:        v1alpha1.HistoryLostPolicy(env.OnHistoryLost),
+		startAt:              startAt,
+		createClientFn:       mongoclient.NewClient,
+		traceParentField:     env.TraceParentField,
+		checkpointer:         checkpointer,
+		checkpointInterval:   env.CheckpointInterval,
+		shutdownGracePeriod:  env.ShutdownGracePeriod,
+		elector:              elector,
+		logger:               logger,
 	}
 }
 
-// Start connects to the database and creates the watch stream that will watch for dataSource changes.
+// parseStartAt returns where the change stream starts when there is no checkpoint, either after a
+// resume token or at an RFC3339 time.
+func parseStartAt(env *envConfig) (position, error) {
+	switch {
+	case env.StartAtResumeToken != "":
+		token, err := bson.Marshal(bson.M{"_data": env.StartAtResumeToken})
+		if err != nil {
+			return position{}, fmt.Errorf("error marshalling resume token: %w", err)
+		}
+		return position{resumeToken: token}, nil
+	case env.StartAtTime != "":
+		t, err := time.Parse(time.RFC3339, env.StartAtTime)
+		if err != nil {
+			return position{}, fmt.Errorf("error parsing start time: %w", err)
+		}
+		return position{operationTime: &primitive.Timestamp{T: uint32(t.Unix())}}, nil
+	}
+	return position{}, nil
+}
+
+// Start connects to the database and, once elected if there are several replicas, creates the
+// watch stream that will watch for dataSource changes. It reconnects whenever the mounted
+// credentials change.
 func (a *mongoDbAdapter) Start(ctx context.Context) error {
-	// Read the Credentials.
-	rawURI, err := ioutil.ReadFile(a.credentialsPath + "/URI")
+	for {
+		creds, err := a.readCredentials()
+		if err != nil {
+			return err
+		}
+
+		// Reconnect when the credentials are rotated.
+		runCtx, cancel := context.WithCancel(ctx)
+		rotated := make(chan bool, 1)
+		go func() {
+			changed := a.watchCredentials(runCtx, creds)
+			if changed {
+				cancel()
+			}
+			rotated <- changed
+		}()
+		err = a.run(runCtx, creds)
+		cancel()
+		if !<-rotated || ctx.Err() != nil {
+			return err
+		}
+		a.logger.Desugar().Info("MongoDb credentials changed, reconnecting")
+	}
+}
+
+// run connects to the database with the given credentials and, once elected if there are several
+// replicas, reads the changes until ctx is done.
+func (a *mongoDbAdapter) run(ctx context.Context, creds *credentials) error {
+	clientOpts, err := creds.clientOptions(a.secretKeys, a.tls)
 	if err != nil {
-		return fmt.Errorf("unable to get MongoDb URI field: secretPath %s/URI : %w", a.credentialsPath, err)
+		return err
 	}
-	URI := string(rawURI)
-	if URI == "" {
-		return errors.New("MongoDb URI field is empty")
+	if err := mongoclient.SetConnection(clientOpts, a.connection, a.name); err != nil {
+		return err
 	}
 
 	// Create new Client.
-	client, err := mongo.NewClient(options.Client().ApplyURI(URI))
+	client, err := a.createClientFn(clientOpts)
 	if err != nil {
 		return fmt.Errorf("error creating mongo client: %w", err)
 	}
 
 	// Get dataSource: either a mongo.Collection or a mongo.Database.
-	var dataSource dataSource
+	database := client.Database(a.database)
+	var dataSource dataSource = database
 	if a.collection != "" {
-		dataSource = client.Database(a.database).Collection(a.collection)
-	} else {
-		dataSource = client.Database(a.database)
+		dataSource = database.Collection(a.collection)
 	}
 
 	// Connect to Client.
@@ -112,42 +338,391 @@ func (a *mongoDbAdapter) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("error connecting to database: %w", err)
 	}
-	defer client.Disconnect(ctx)
+	// ctx is already cancelled on shutdown, use a fresh one to close the client and the stream.
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := client.Disconnect(closeCtx); err != nil {
+			a.logger.Desugar().Warn("Error disconnecting from database", zap.Error(err))
+		}
+	}()
+
+	// Standbys stay connected so that they can take over quickly.
+	return a.elector.run(ctx, func(ctx context.Context) error {
+		switch a.mode {
+		case v1alpha1.SourceModePoll:
+			return a.poll(ctx, database)
+		case v1alpha1.SourceModeOplog:
+			return a.tailOplog(ctx, client)
+		}
+		return a.watch(ctx, database, dataSource)
+	}, a.logger)
+}
+
+// watch sends the snapshot of the existing documents if needed, then watches and processes the
+// changes of dataSource until ctx is done, resuming from the last checkpoint.
+func (a *mongoDbAdapter) watch(ctx context.Context, database mongoclient.Database, dataSource dataSource) error {
+	pos, err := a.checkpointer.load(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading checkpoint: %w", err)
+	}
+	if pos.isZero() {
+		pos = a.startAt
+	}
+
+	// Periodically persist the position of the adapter while processing, and once done.
+	defer a.startCheckpoints(ctx)()
+
+	if a.startsSnapshot(pos) {
+		if pos, err = a.startSnapshot(ctx, database); err != nil {
+			return err
+		}
+	}
+	err = a.resume(ctx, database, dataSource, pos)
+	if !isHistoryLost(err) {
+		return err
+	}
+	if pos, err = a.recoverHistoryLost(ctx, database, err); err != nil {
+		return err
+	}
+	return a.resume(ctx, database, dataSource, pos)
+}
+
+// startCheckpoints periodically persists the position of the adapter until the returned function
+// is called, which persists it one last time.
+func (a *mongoDbAdapter) startCheckpoints(ctx context.Context) func() {
+	checkpointCtx, stopCheckpoints := context.WithCancel(ctx)
+	go a.checkpointer.run(checkpointCtx, a.checkpointInterval, a.logger)
+	return func() {
+		stopCheckpoints()
+		saveCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := a.checkpointer.save(saveCtx); err != nil {
+			a.logger.Desugar().Error("Failed to save final checkpoint", zap.Error(err))
+		}
+	}
+}
+
+// resume sends the rest of the snapshot in progress at pos, if any, then watches and processes the
+// changes after pos until ctx is done.
+func (a *mongoDbAdapter) resume(ctx context.Context, database mongoclient.Database, dataSource dataSource, pos position) error {
+	if pos.snapshot != nil {
+		if err := a.sendSnapshot(ctx, database, *pos.snapshot); err != nil || ctx.Err() != nil {
+			return err
+		}
+		pos = position{operationTime: &pos.snapshot.OperationTime}
+		a.checkpointer.acknowledge(pos)
+	}
+
+	// Resume after the last acknowledged change, if any.
+	streamOpts := options.ChangeStream()
+	streamOpts.BatchSize, streamOpts.MaxAwaitTime = mongoclient.BatchSize(a.connection), mongoclient.MaxAwaitTime(a.connection)
+	if pos.resumeToken != nil {
+		a.logger.Desugar().Info("Resuming change stream from checkpoint", zap.Stringer("resumeToken", pos.resumeToken))
+		streamOpts.SetResumeAfter(pos.resumeToken)
+	} else if pos.operationTime != nil {
+		// Checkpoints of snapshots and rebalanced partitions only have an operation time.
+		a.logger.Desugar().Info("Starting change stream at checkpoint operation time", zap.Uint32("t", pos.operationTime.T), zap.Uint32("i", pos.operationTime.I))
+		streamOpts.SetStartAtOperationTime(pos.operationTime)
+	}
 
 	// Create a watch stream for either the database or collection.
-	stream, err := dataSource.Watch(ctx, mongo.Pipeline{})
+	stream, err := dataSource.Watch(ctx, a.pipeline(), streamOpts)
 	if err != nil {
 		return fmt.Errorf("error setting up changeStream: %w", err)
 	}
-	defer stream.Close(ctx)
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := stream.Close(closeCtx); err != nil {
+			a.logger.Desugar().Warn("Error closing changeStream", zap.Error(err))
+		}
+	}()
 
 	// Watch and process changes.
-	a.processChanges(ctx, stream)
+	a.processChanges(ctx, streamReader{stream})
+
+	if err := stream.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error watching changeStream: %w", err)
+	}
 	return nil
 }
 
-// processChanges processes the new incoming change, creates a cloud event and sends it.
-func (a *mongoDbAdapter) processChanges(ctx context.Context, stream mongoclient.ChangeStream) {
+// pipeline returns the change stream pipeline, restricted to the collections of the partition of
+// the adapter if the database is partitioned.
+func (a *mongoDbAdapter) pipeline() mongo.Pipeline {
+	if a.partitionCollections == nil {
+		return mongo.Pipeline{}
+	}
+	return mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "ns.coll", Value: bson.D{{Key: "$in", Value: a.partitionCollections}}}}}}}
+}
+
+// changeReader reads changes, from a change stream or a snapshot.
+type changeReader interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	// position returns the position of the change last read.
+	position(data bson.M) position
+}
+
+// streamReader reads the changes of a change stream.
+type streamReader struct {
+	mongoclient.ChangeStream
+}
+
+// position implements changeReader.position.
+func (r streamReader) position(data bson.M) position {
+	p := position{resumeToken: append(bson.Raw(nil), r.ResumeToken()...)}
+	if clusterTime, ok := data["clusterTime"].(primitive.Timestamp); ok {
+		p.operationTime = &clusterTime
+	}
+	return p
+}
+
+// change is a change read along with its position, and its span started before decoding it.
+type change struct {
+	data     bson.M
+	position position
+	span     *trace.Span
+}
+
+// processChanges reads the incoming changes and queues them for sending until the reader is
+// exhausted or ctx is done. It then waits for the queued and in-flight changes to be sent, for at
+// most the shutdown grace period if ctx is done.
+func (a *mongoDbAdapter) processChanges(ctx context.Context, reader changeReader) {
+	// Sends must outlive ctx so that in-flight events are not lost on shutdown.
+	sendCtx, cancelSends := context.WithCancel(detach(ctx))
+	defer cancelSends()
+
+	queue := make(chan change, queueSize)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		a.sendChanges(sendCtx, queue)
+	}()
+
 	// For each new change recorded.
-	for stream.Next(ctx) {
+	for reader.Next(ctx) {
+		_, span := trace.StartSpan(ctx, changeSpanName, trace.WithSpanKind(trace.SpanKindClient))
 		var data bson.M
-		if err := stream.Decode(&data); err != nil {
+		if err := reader.Decode(&data); err != nil {
 			a.logger.Desugar().Error("Error decoding the change stream", zap.Error(err))
+			span.SetStatus(trace.Status{Code: trace.StatusCodeInvalidArgument, Message: err.Error()})
+			span.End()
 			continue
 		}
-		// Create corresponding event.
-		event, err := a.makeCloudEvent(data)
-		if err != nil {
-			a.logger.Desugar().Error("Failed to create event", zap.Error(err))
-			continue
+		a.annotateChangeSpan(span, data)
+		select {
+		case queue <- change{data: data, position: reader.position(data), span: span}:
+		case <-ctx.Done():
+			span.End()
 		}
+	}
+	close(queue)
 
-		// Send that Event.
-		if result := a.ceClient.Send(ctx, *event); cloudevents.IsUndelivered(result) {
-			a.logger.Desugar().Error("Failed to send event", zap.Any("result", result))
+	if ctx.Err() == nil {
+		<-sent
+		return
+	}
+	a.logger.Desugar().Info("Draining queued changes", zap.Int("queued", len(queue)), zap.Duration("gracePeriod", a.shutdownGracePeriod))
+	select {
+	case <-sent:
+	case <-time.After(a.shutdownGracePeriod):
+		a.logger.Desugar().Warn("Shutdown grace period expired, abandoning queued changes", zap.Int("abandoned", len(queue)))
+		cancelSends()
+		<-sent
+	}
+}
+
+// sendChanges sends the queued changes in order, acknowledging each of them once delivered.
+func (a *mongoDbAdapter) sendChanges(ctx context.Context, queue <-chan change) {
+	for c := range queue {
+		handled := a.sendChange(trace.NewContext(ctx, c.span), c.data)
+		c.span.End()
+		if !handled {
+			// Interrupted: the change will be processed again after resuming.
+			break
 		}
+		a.checkpointer.acknowledge(c.position)
+	}
+	// The queue is closed once ctx is done, end the spans of the abandoned changes.
+	for c := range queue {
+		c.span.SetStatus(trace.Status{Code: trace.StatusCodeCancelled, Message: "abandoned on shutdown"})
+		c.span.End()
 	}
-	return
+}
+
+// sendChange processes a change until it is delivered, backing off between the attempts so that
+// the stream waits for the sink to come back rather than skipping changes. A change that can never
+// be delivered is skipped. It returns false if ctx is done before the change was handled, which
+// then must not be acknowledged.
+func (a *mongoDbAdapter) sendChange(ctx context.Context, data bson.M) bool {
+	delay := minSendRetryDelay
+	for {
+		err := a.processChange(ctx, data)
+		if ctx.Err() != nil {
+			trace.FromContext(ctx).SetStatus(trace.Status{Code: trace.StatusCodeCancelled, Message: "interrupted on shutdown"})
+			return false
+		}
+		var undeliverable *undeliverableError
+		if err == nil || errors.As(err, &undeliverable) {
+			return true
+		}
+		a.logger.Desugar().Warn("Failed to deliver event, retrying", zap.Error(err), zap.Duration("delay", delay))
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxSendRetryDelay {
+			delay = maxSendRetryDelay
+		}
+	}
+}
+
+// undeliverableError is the error of a change that can never be delivered, which retrying would
+// only block the stream on.
+type undeliverableError struct {
+	err error
+}
+
+// Error implements error.Error.
+func (e *undeliverableError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *undeliverableError) Unwrap() error {
+	return e.err
+}
+
+// processChange creates a cloud event out of a single change and sends it, all within a span
+// that is propagated to the sink, a child of the span of the change in ctx. It returns an error
+// unless the sink acknowledged the event; the error is an undeliverableError if the change cannot
+// be made into an event or the sink rejected it as invalid.
+func (a *mongoDbAdapter) processChange(ctx context.Context, data bson.M) error {
+	ctx, span := a.startSendSpan(ctx, data)
+	defer span.End()
+
+	// Create corresponding event.
+	event, err := a.makeCloudEvent(data)
+	if err != nil {
+		a.logger.Desugar().Error("Failed to create event, skipping the change", zap.Error(err))
+		span.SetStatus(trace.Status{Code: trace.StatusCodeInvalidArgument, Message: err.Error()})
+		return &undeliverableError{err: err}
+	}
+	span.AddAttributes(
+		trace.StringAttribute("cloudevents.id", event.ID()),
+		trace.StringAttribute("cloudevents.type", event.Type()),
+		trace.StringAttribute("cloudevents.source", event.Source()),
+	)
+	extensions.FromSpanContext(span.SpanContext()).AddTracingAttributes(event)
+
+	// Send that Event.
+	result := a.ceClient.Send(ctx, *event)
+	if cloudevents.IsACK(result) {
+		return nil
+	}
+	span.SetStatus(trace.Status{Code: trace.StatusCodeUnavailable, Message: result.Error()})
+	if rejected(result) {
+		a.logger.Desugar().Error("The sink rejected the event, skipping the change", zap.Any("result", result))
+		return &undeliverableError{err: result}
+	}
+	return fmt.Errorf("error sending event: %w", result)
+}
+
+// rejected returns whether the sink answered the event with a client error that sending it again
+// would not fix. Timeouts and throttling are retried.
+func rejected(result protocol.Result) bool {
+	var httpResult *cehttp.Result
+	if !errors.As(result, &httpResult) {
+		return false
+	}
+	code := httpResult.StatusCode
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+}
+
+// startSendSpan starts the span sending a change, as a child of the trace context stored in the
+// changed document if there is one, linked to the span of the change in ctx, or else as a child of
+// the latter.
+func (a *mongoDbAdapter) startSendSpan(ctx context.Context, data bson.M) (context.Context, *trace.Span) {
+	var span *trace.Span
+	if parent, ok := a.traceParent(data); ok {
+		changeSpan := trace.FromContext(ctx)
+		ctx, span = trace.StartSpanWithRemoteParent(ctx, sendSpanName, parent, trace.WithSpanKind(trace.SpanKindClient))
+		if changeSpan != nil {
+			sc := changeSpan.SpanContext()
+			span.AddLink(trace.Link{TraceID: sc.TraceID, SpanID: sc.SpanID, Type: trace.LinkTypeParent})
+		}
+	} else {
+		ctx, span = trace.StartSpan(ctx, sendSpanName, trace.WithSpanKind(trace.SpanKindClient))
+	}
+	a.annotateChangeSpan(span, data)
+	return ctx, span
+}
+
+// annotateChangeSpan adds the database, collection and operation of a change to a span.
+func (a *mongoDbAdapter) annotateChangeSpan(span *trace.Span, data bson.M) {
+	span.AddAttributes(trace.StringAttribute("mongodb.database", a.database))
+	if operationType, ok := data["operationType"].(string); ok {
+		span.AddAttributes(trace.StringAttribute("mongodb.operation", operationType))
+	}
+	if ns, ok := data["ns"].(bson.M); ok {
+		if collection, ok := ns["coll"].(string); ok {
+			span.AddAttributes(trace.StringAttribute("mongodb.collection", collection))
+		}
+	}
+}
+
+// traceParent looks up the W3C traceparent stored in the changed document, if configured.
+func (a *mongoDbAdapter) traceParent(data bson.M) (trace.SpanContext, bool) {
+	if a.traceParentField == "" {
+		return trace.SpanContext{}, false
+	}
+	// Deletions only carry the document key.
+	doc, found := data["fullDocument"].(bson.M)
+	if !found {
+		return trace.SpanContext{}, false
+	}
+	path := strings.Split(a.traceParentField, ".")
+	for _, field := range path[:len(path)-1] {
+		if doc, found = doc[field].(bson.M); !found {
+			return trace.SpanContext{}, false
+		}
+	}
+	traceParent, found := doc[path[len(path)-1]].(string)
+	if !found {
+		return trace.SpanContext{}, false
+	}
+	sc, err := extensions.DistributedTracingExtension{TraceParent: traceParent}.ToSpanContext()
+	if err != nil {
+		a.logger.Desugar().Debug("Ignoring invalid traceparent", zap.String("traceparent", traceParent), zap.Error(err))
+		return trace.SpanContext{}, false
+	}
+	return sc, true
+}
+
+// ceSourcePrefix returns the prefix of the default CloudEvents source of the events of a cluster.
+func ceSourcePrefix(clusterName string) string {
+	return "mongodb://" + clusterName
+}
+
+// eventSource returns the CloudEvents source of the events about a collection, or about the whole
+// database if collection is empty.
+func (a *mongoDbAdapter) eventSource(collection string) (string, error) {
+	if a.sourceTemplate == nil {
+		source := fmt.Sprintf("%s/databases/%s", a.ceSourcePrefix, a.database)
+		if collection != "" {
+			source = fmt.Sprintf("%s/collections/%s", source, collection)
+		}
+		return source, nil
+	}
+	var b strings.Builder
+	data := v1alpha1.SourceTemplateData{Cluster: a.clusterName, Database: a.database, Collection: collection}
+	if err := a.sourceTemplate.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering the source template: %w", err)
+	}
+	return b.String(), nil
 }
 
 // makeCloudEvent makes a cloud event out of the change object recevied.
@@ -162,7 +737,11 @@ func (a *mongoDbAdapter) makeCloudEvent(data bson.M) (*cloudevents.Event, error)
 	}
 	// Set cloud event specs and attributes.
 	event.SetID(fmt.Sprintf("%x", md5.Sum([]byte(change.ID))))
-	event.SetSource(fmt.Sprintf("%s/databases/%s/collections/%s", a.ceSourcePrefix, a.database, change.Collection))
+	source, err := a.eventSource(change.Collection)
+	if err != nil {
+		return nil, err
+	}
+	event.SetSource(source)
 	event.SetData(cloudevents.ApplicationJSON, change.Payload)
 	eventType, found := v1alpha1.MongoDbSourceEventTypes[change.OperationType]
 	if !found {
@@ -21,6 +21,8 @@ import (
 	"crypto/md5"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
@@ -28,6 +30,9 @@ import (
 	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
 	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opencensus.io/trace"
 	testcloudclient "knative.dev/eventing/pkg/adapter/v2/test"
 	"knative.dev/pkg/logging"
 )
@@ -154,6 +159,58 @@ func makeCloudEventTest(data *bson.M) *cloudevents.Event {
 	return &event
 }
 
+func TestEventSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		collection string
+		want       string
+	}{
+		{
+			name:       "collection",
+			collection: coll,
+			want:       "mongodb://rs0/databases/db/collections/coll",
+		},
+		{
+			name: "database",
+			want: "mongodb://rs0/databases/db",
+		},
+		{
+			name:       "template",
+			template:   "https://mongo.example.com/{{.Cluster}}/{{.Database}}{{with .Collection}}.{{.}}{{end}}",
+			collection: coll,
+			want:       "https://mongo.example.com/rs0/db.coll",
+		},
+		{
+			name:     "template without collection",
+			template: "https://mongo.example.com/{{.Cluster}}/{{.Database}}{{with .Collection}}.{{.}}{{end}}",
+			want:     "https://mongo.example.com/rs0/db",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := &mongoDbAdapter{
+				ceSourcePrefix: ceSourcePrefix("rs0"),
+				clusterName:    "rs0",
+				database:       db,
+			}
+			if test.template != "" {
+				var err error
+				if a.sourceTemplate, err = v1alpha1.ParseSourceTemplate(test.template); err != nil {
+					t.Fatalf("ParseSourceTemplate got error %v", err)
+				}
+			}
+			got, err := a.eventSource(test.collection)
+			if err != nil {
+				t.Fatalf("eventSource got error %v", err)
+			}
+			if got != test.want {
+				t.Errorf("eventSource got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
 func TestProcessChanges(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -233,7 +290,7 @@ func TestProcessChanges(t *testing.T) {
 			stream := &mongotesting.TestChangeStream{
 				Data: test.testCSdata,
 			}
-			a.processChanges(ctx, stream)
+			a.processChanges(ctx, streamReader{stream})
 			if test.wantCE {
 				validateSent(t, ce, `{"_id":"docID","key1":"value1"}`)
 			}
@@ -241,6 +298,180 @@ func TestProcessChanges(t *testing.T) {
 	}
 }
 
+func TestProcessChangeTracing(t *testing.T) {
+	const (
+		traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+		traceParent = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+	tests := []struct {
+		name             string
+		traceParentField string
+		document         bson.M
+		wantTraceID      string
+	}{
+		{
+			name:     "new trace",
+			document: bson.M{"_id": docID},
+		},
+		{
+			name:             "parent not in document",
+			traceParentField: "traceparent",
+			document:         bson.M{"_id": docID},
+		},
+		{
+			name:             "invalid parent",
+			traceParentField: "traceparent",
+			document:         bson.M{"_id": docID, "traceparent": "invalid"},
+		},
+		{
+			name:             "parent in document",
+			traceParentField: "traceparent",
+			document:         bson.M{"_id": docID, "traceparent": traceParent},
+			wantTraceID:      traceID,
+		},
+		{
+			name:             "parent in nested document",
+			traceParentField: "meta.trace",
+			document:         bson.M{"_id": docID, "meta": bson.M{"trace": traceParent}},
+			wantTraceID:      traceID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			ce := testcloudclient.NewTestClient()
+			a := mongoDbAdapter{
+				namespace:        "namespace",
+				ceSourcePrefix:   "CEPrefix",
+				database:         db,
+				collection:       coll,
+				traceParentField: test.traceParentField,
+				ceClient:         ce,
+				logger:           logging.FromContext(ctx),
+			}
+			a.processChange(ctx, bson.M{
+				"ns": bson.M{
+					"coll": coll,
+					"db":   db,
+				},
+				"_id": bson.M{
+					"_data": ID,
+				},
+				"fullDocument":  test.document,
+				"operationType": "insert",
+			})
+
+			if got := len(ce.Sent()); got != 1 {
+				t.Fatalf("Expected 1 event to be sent, got %d", got)
+			}
+			got, ok := ce.Sent()[0].Extensions()["traceparent"].(string)
+			if !ok {
+				t.Fatalf("Expected traceparent extension, got %v", ce.Sent()[0].Extensions())
+			}
+			if test.wantTraceID != "" && !strings.HasPrefix(got, "00-"+test.wantTraceID+"-") {
+				t.Errorf("Expected traceparent in trace %q, got %q", test.wantTraceID, got)
+			}
+			if got == traceParent {
+				t.Errorf("Expected a child span of %q, got the same span", traceParent)
+			}
+		})
+	}
+}
+
+// spanRecorder records the spans ended.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func TestProcessChangesSpans(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.RegisterExporter(recorder)
+	defer trace.UnregisterExporter(recorder)
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+
+	tests := []struct {
+		name       string
+		data       mongotesting.TestCSData
+		wantStatus int32
+		wantSend   bool
+	}{
+		{
+			name:       "decode error",
+			data:       mongotesting.TestCSData{DecodeErr: errors.New("corrupted")},
+			wantStatus: trace.StatusCodeInvalidArgument,
+		},
+		{
+			name: "sent",
+			data: mongotesting.TestCSData{
+				NewChange: bson.M{
+					"ns": bson.M{
+						"coll": coll,
+						"db":   db,
+					},
+					"_id": bson.M{
+						"_data": ID,
+					},
+					"fullDocument": bson.M{
+						"_id": docID,
+					},
+					"operationType": "insert",
+				},
+			},
+			wantStatus: trace.StatusCodeOK,
+			wantSend:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder.mu.Lock()
+			recorder.spans = nil
+			recorder.mu.Unlock()
+
+			ctx := context.Background()
+			a := mongoDbAdapter{
+				namespace:      "namespace",
+				ceSourcePrefix: "CEPrefix",
+				database:       db,
+				collection:     coll,
+				ceClient:       testcloudclient.NewTestClient(),
+				logger:         logging.FromContext(ctx),
+			}
+			a.processChanges(ctx, streamReader{&mongotesting.TestChangeStream{Data: test.data}})
+
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			spans := make(map[string]*trace.SpanData)
+			for _, span := range recorder.spans {
+				spans[span.Name] = span
+			}
+			change, ok := spans[changeSpanName]
+			if !ok {
+				t.Fatalf("Expected a %s span, got %v", changeSpanName, recorder.spans)
+			}
+			if change.Status.Code != test.wantStatus {
+				t.Errorf("Expected the change span status %d, got %d", test.wantStatus, change.Status.Code)
+			}
+			send, ok := spans[sendSpanName]
+			if ok != test.wantSend {
+				t.Fatalf("Expected a %s span %v, got %v", sendSpanName, test.wantSend, recorder.spans)
+			}
+			if ok && send.ParentSpanID != change.SpanID {
+				t.Errorf("Expected the send span to be a child of the change span %v, got parent %v", change.SpanID, send.ParentSpanID)
+			}
+		})
+	}
+}
+
 func validateSent(t *testing.T, ce *testcloudclient.TestCloudEventsClient, wantData string) {
 	if got := len(ce.Sent()); got != 1 {
 		t.Errorf("Expected 1 event to be sent, got %d", got)
@@ -250,3 +481,74 @@ func validateSent(t *testing.T, ce *testcloudclient.TestCloudEventsClient, wantD
 		t.Errorf("Expected %q event to be sent, got %q", wantData, string(got))
 	}
 }
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name        string
+		collections []string
+		want        mongo.Pipeline
+	}{
+		{
+			name: "not partitioned",
+			want: mongo.Pipeline{},
+		},
+		{
+			name:        "partition",
+			collections: []string{"coll1", "coll2"},
+			want:        mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "ns.coll", Value: bson.D{{Key: "$in", Value: []string{"coll1", "coll2"}}}}}}}},
+		},
+		{
+			name:        "empty partition",
+			collections: []string{},
+			want:        mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "ns.coll", Value: bson.D{{Key: "$in", Value: []string{}}}}}}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := mongoDbAdapter{partitionCollections: test.collections}
+			if diff := cmp.Diff(test.want, a.pipeline()); diff != "" {
+				t.Errorf("pipeline() got unexpected pipeline (-want +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseStartAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     envConfig
+		want    position
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "time",
+			env:  envConfig{StartAtTime: "2020-08-01T00:00:00Z"},
+			want: position{operationTime: &primitive.Timestamp{T: 1596240000}},
+		},
+		{
+			name: "resume token",
+			env:  envConfig{StartAtResumeToken: ID},
+			want: position{resumeToken: mustMarshal(t, bson.M{"_data": ID})},
+		},
+		{
+			name:    "invalid time",
+			env:     envConfig{StartAtTime: "yesterday"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseStartAt(&test.env)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseStartAt got error %v, want error %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(position{})); diff != "" {
+				t.Errorf("parseStartAt got unexpected position (-want +got) %s", diff)
+			}
+		})
+	}
+}
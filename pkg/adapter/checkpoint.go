@@ -0,0 +1,212 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// position is the position of the adapter in the change stream, or in the snapshot sent before.
+type position struct {
+	resumeToken   bson.Raw
+	operationTime *primitive.Timestamp
+	snapshot      *checkpoint.Snapshot
+	// poll are the high-water marks of the polled collections.
+	poll map[string]string
+}
+
+// isZero returns whether the position is unknown.
+func (p position) isZero() bool {
+	return p.resumeToken == nil && p.operationTime == nil && p.snapshot == nil && p.poll == nil
+}
+
+// checkpointer tracks the position of the last change acknowledged by the sink and persists it in
+// a checkpoint.Store. A nil checkpointer does not persist anything.
+type checkpointer struct {
+	store checkpoint.Store
+	// rewind is the rewind of the source, checkpoints made before it are loaded at its time.
+	rewind string
+
+	// saveMu serializes the saves.
+	saveMu sync.Mutex
+	// mu guards acked, historyLost, seq and savedSeq.
+	mu    sync.Mutex
+	acked position
+	// historyLost is the last loss of the history of the change stream, kept across checkpoints.
+	historyLost *checkpoint.HistoryLost
+	// seq counts the acknowledgements, savedSeq is the value of seq at the last save.
+	seq, savedSeq int
+}
+
+// newCheckpointer creates a checkpointer persisting into store, or nil if store is nil.
+func newCheckpointer(store checkpoint.Store) *checkpointer {
+	if store == nil {
+		return nil
+	}
+	return &checkpointer{store: store}
+}
+
+// load returns the stored position, which is zero if there is no checkpoint.
+func (c *checkpointer) load(ctx context.Context) (position, error) {
+	if c == nil {
+		return position{}, nil
+	}
+	cp, err := c.store.Load(ctx)
+	if err != nil {
+		return position{}, err
+	}
+	if c.rewind != "" && (cp == nil || cp.Rewind != c.rewind) {
+		// The checkpoint was saved by an adapter started before the rewind.
+		if cp, err = checkpoint.NewRewind(c.rewind); err != nil {
+			return position{}, err
+		}
+	}
+	token, err := cp.GetResumeToken()
+	if err != nil {
+		return position{}, err
+	}
+	p := position{resumeToken: token}
+	var historyLost *checkpoint.HistoryLost
+	if cp != nil {
+		p.operationTime, p.snapshot, p.poll, historyLost = cp.OperationTime, cp.Snapshot, cp.Poll, cp.HistoryLost
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.acked, c.historyLost, c.savedSeq = p, historyLost, c.seq
+	return p, nil
+}
+
+// acknowledge records that the change at the given position has been handled.
+func (c *checkpointer) acknowledge(p position) {
+	if c == nil || p.isZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.acked = p
+	c.seq++
+}
+
+// acknowledged returns the position of the last change acknowledged, or loaded.
+func (c *checkpointer) acknowledged() position {
+	if c == nil {
+		return position{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.acked
+}
+
+// markHistoryLost records a loss of the history of the change stream, persisted by the next save.
+func (c *checkpointer) markHistoryLost(lost *checkpoint.HistoryLost) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.historyLost = lost
+	c.seq++
+}
+
+// save persists the last acknowledged position if it changed since the last save.
+func (c *checkpointer) save(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	acked, historyLost, seq, savedSeq := c.acked, c.historyLost, c.seq, c.savedSeq
+	c.mu.Unlock()
+	if seq == savedSeq {
+		return nil
+	}
+
+	cp := &checkpoint.Checkpoint{}
+	if acked.resumeToken != nil {
+		var err error
+		if cp, err = checkpoint.NewResumeToken(acked.resumeToken); err != nil {
+			return err
+		}
+	}
+	cp.OperationTime, cp.Snapshot, cp.Poll = acked.operationTime, acked.snapshot, acked.poll
+	cp.Rewind, cp.HistoryLost = c.rewind, historyLost
+	if err := c.store.Save(ctx, cp); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.savedSeq = seq
+	return nil
+}
+
+// run saves the checkpoint every interval until ctx is done.
+func (c *checkpointer) run(ctx context.Context, interval time.Duration, logger *zap.SugaredLogger) {
+	if c == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.save(ctx); err != nil {
+				logger.Desugar().Error("Failed to save checkpoint", zap.Error(err))
+			}
+		}
+	}
+}
+
+// detachedContext carries the values of its parent but is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+// detach returns a context with the values of ctx that is not cancelled along with it.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// Deadline implements context.Context.Deadline.
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done implements context.Context.Done.
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err implements context.Context.Err.
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Value implements context.Context.Value.
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	testcloudclient "knative.dev/eventing/pkg/adapter/v2/test"
+	"knative.dev/pkg/logging"
+)
+
+// testStore is an in-memory checkpoint.Store.
+type testStore struct {
+	checkpoint *checkpoint.Checkpoint
+	saves      int
+	err        error
+}
+
+func (s *testStore) Load(ctx context.Context) (*checkpoint.Checkpoint, error) {
+	return s.checkpoint, s.err
+}
+
+func (s *testStore) Save(ctx context.Context, cp *checkpoint.Checkpoint) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.checkpoint = cp
+	s.saves++
+	return nil
+}
+
+// blockingClient is a cloudevents.Client whose sends block until their context is done.
+type blockingClient struct {
+	cloudevents.Client
+}
+
+func (blockingClient) Send(ctx context.Context, event cloudevents.Event) protocol.Result {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// slowClient is a cloudevents.Client whose sends take some time, and fail if their context is
+// done by then.
+type slowClient struct {
+	cloudevents.Client
+	delay time.Duration
+}
+
+func (c slowClient) Send(ctx context.Context, event cloudevents.Event) protocol.Result {
+	time.Sleep(c.delay)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Client.Send(ctx, event)
+}
+
+// failingClient is a cloudevents.Client whose sends return the given results in turn, and then
+// those of Client.
+type failingClient struct {
+	cloudevents.Client
+	results []protocol.Result
+	sends   int
+}
+
+func (c *failingClient) Send(ctx context.Context, event cloudevents.Event) protocol.Result {
+	c.sends++
+	if c.sends <= len(c.results) {
+		return c.results[c.sends-1]
+	}
+	return c.Client.Send(ctx, event)
+}
+
+// cancellingStream is a change stream that cancels the adapter context once its changes are read.
+type cancellingStream struct {
+	*mongotesting.TestChangeStream
+	cancel context.CancelFunc
+}
+
+func (s cancellingStream) Next(ctx context.Context) bool {
+	if s.TestChangeStream.Next(ctx) {
+		return true
+	}
+	s.cancel()
+	return false
+}
+
+func TestCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	token := mustMarshal(t, bson.M{"_data": ID})
+	store := &testStore{}
+	c := newCheckpointer(store)
+
+	if got, err := c.load(ctx); !got.isZero() || err != nil {
+		t.Fatalf("load got (%v, %v), want zero position", got, err)
+	}
+
+	// Nothing acknowledged yet.
+	if err := c.save(ctx); err != nil {
+		t.Fatalf("save got error %v", err)
+	}
+	if store.saves != 0 {
+		t.Errorf("Expected no save without acknowledged change, got %d", store.saves)
+	}
+
+	want := position{resumeToken: token, operationTime: &primitive.Timestamp{T: 1597, I: 3}}
+	c.acknowledge(want)
+	if err := c.save(ctx); err != nil {
+		t.Fatalf("save got error %v", err)
+	}
+	if err := c.save(ctx); err != nil {
+		t.Fatalf("save got error %v", err)
+	}
+	if store.saves != 1 {
+		t.Errorf("Expected 1 save, got %d", store.saves)
+	}
+
+	got, err := newCheckpointer(store).load(ctx)
+	if err != nil {
+		t.Fatalf("load got error %v", err)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(position{})); diff != "" {
+		t.Errorf("load got unexpected position (-want +got) %s", diff)
+	}
+
+	// Snapshots in progress are checkpointed too.
+	snapshot := position{snapshot: &checkpoint.Snapshot{OperationTime: primitive.Timestamp{T: 1597}, Collection: coll, LastID: `{"_id":"docID"}`}}
+	c.acknowledge(snapshot)
+	if err := c.save(ctx); err != nil {
+		t.Fatalf("save got error %v", err)
+	}
+	got, err = newCheckpointer(store).load(ctx)
+	if err != nil {
+		t.Fatalf("load got error %v", err)
+	}
+	if diff := cmp.Diff(snapshot, got, cmp.AllowUnexported(position{})); diff != "" {
+		t.Errorf("load got unexpected snapshot position (-want +got) %s", diff)
+	}
+
+	store.err = errors.New("unavailable")
+	if _, err := c.load(ctx); err == nil {
+		t.Error("load expected error from store")
+	}
+
+	// A nil checkpointer is a no-op.
+	var nilCheckpointer *checkpointer
+	nilCheckpointer.acknowledge(want)
+	if err := nilCheckpointer.save(ctx); err != nil {
+		t.Errorf("save of nil checkpointer got error %v", err)
+	}
+}
+
+func TestCheckpointerRewind(t *testing.T) {
+	ctx := context.Background()
+	const rewind = "2020-08-01T00:00:00Z"
+	rewound := position{operationTime: &primitive.Timestamp{T: 1596240000}}
+
+	// The checkpoint was saved by an adapter started before the rewind.
+	store := &testStore{checkpoint: &checkpoint.Checkpoint{OperationTime: &primitive.Timestamp{T: 1597}}}
+	c := newCheckpointer(store)
+	c.rewind = rewind
+	got, err := c.load(ctx)
+	if err != nil {
+		t.Fatalf("load got error %v", err)
+	}
+	if diff := cmp.Diff(rewound, got, cmp.AllowUnexported(position{})); diff != "" {
+		t.Errorf("load got unexpected position (-want +got) %s", diff)
+	}
+
+	// The checkpoints saved after the rewind are resumed from.
+	want := position{operationTime: &primitive.Timestamp{T: 1596240010}}
+	c.acknowledge(want)
+	if err := c.save(ctx); err != nil {
+		t.Fatalf("save got error %v", err)
+	}
+	if store.checkpoint.Rewind != rewind {
+		t.Errorf("Expected checkpoint to follow rewind %q, got %q", rewind, store.checkpoint.Rewind)
+	}
+	if got, err = c.load(ctx); err != nil {
+		t.Fatalf("load got error %v", err)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(position{})); diff != "" {
+		t.Errorf("load got unexpected position (-want +got) %s", diff)
+	}
+}
+
+func TestProcessChangesShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		blockSend   bool
+		wantSaved   bool
+		gracePeriod time.Duration
+	}{
+		{
+			name:        "in-flight change is sent after cancellation",
+			gracePeriod: time.Minute,
+			wantSaved:   true,
+		},
+		{
+			name:        "grace period expires",
+			blockSend:   true,
+			gracePeriod: 10 * time.Millisecond,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ce := testcloudclient.NewTestClient()
+			var ceClient cloudevents.Client = slowClient{Client: ce, delay: 50 * time.Millisecond}
+			if test.blockSend {
+				ceClient = blockingClient{}
+			}
+			store := &testStore{}
+			a := mongoDbAdapter{
+				namespace:           "namespace",
+				ceSourcePrefix:      "CEPrefix",
+				database:            db,
+				collection:          coll,
+				ceClient:            ceClient,
+				checkpointer:        newCheckpointer(store),
+				shutdownGracePeriod: test.gracePeriod,
+				logger:              logging.FromContext(ctx),
+			}
+			// The adapter shuts down right after reading the change.
+			stream := cancellingStream{cancel: cancel, TestChangeStream: &mongotesting.TestChangeStream{
+				Data: mongotesting.TestCSData{
+					NewChange: bson.M{
+						"ns": bson.M{
+							"coll": coll,
+							"db":   db,
+						},
+						"_id": bson.M{
+							"_data": ID,
+						},
+						"fullDocument": bson.M{
+							"_id":  docID,
+							"key1": "value1",
+						},
+						"operationType": "insert",
+					},
+				},
+			}}
+			a.processChanges(ctx, streamReader{stream})
+			if err := a.checkpointer.save(context.Background()); err != nil {
+				t.Fatalf("save got error %v", err)
+			}
+
+			if test.wantSaved {
+				validateSent(t, ce, `{"_id":"docID","key1":"value1"}`)
+				want, err := checkpoint.NewResumeToken(mustMarshal(t, bson.M{"_data": ID}))
+				if err != nil {
+					t.Fatalf("NewResumeToken got error %v", err)
+				}
+				if diff := cmp.Diff(want, store.checkpoint); diff != "" {
+					t.Errorf("Unexpected checkpoint (-want +got) %s", diff)
+				}
+			} else if store.checkpoint != nil {
+				t.Errorf("Expected no checkpoint for an interrupted send, got %v", store.checkpoint)
+			}
+		})
+	}
+}
+
+func TestSendChange(t *testing.T) {
+	unavailable := cehttp.NewResult(http.StatusServiceUnavailable, "%w", protocol.ResultNACK)
+	tests := []struct {
+		name          string
+		operationType string
+		results       []protocol.Result
+		timeout       time.Duration
+		wantHandled   bool
+		wantSent      int
+	}{
+		{
+			name:          "delivered",
+			operationType: "insert",
+			wantHandled:   true,
+			wantSent:      1,
+		},
+		{
+			name:          "delivered once the sink is back",
+			operationType: "insert",
+			results:       []protocol.Result{unavailable},
+			wantHandled:   true,
+			wantSent:      1,
+		},
+		{
+			name:          "sink unavailable until shutdown",
+			operationType: "insert",
+			results:       []protocol.Result{unavailable, unavailable, unavailable},
+			timeout:       100 * time.Millisecond,
+		},
+		{
+			name:          "rejected by the sink",
+			operationType: "insert",
+			results:       []protocol.Result{cehttp.NewResult(http.StatusBadRequest, "%w", protocol.ResultNACK)},
+			wantHandled:   true,
+		},
+		{
+			name:          "not an event",
+			operationType: "drop",
+			wantHandled:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+			ce := testcloudclient.NewTestClient()
+			a := mongoDbAdapter{
+				namespace:      "namespace",
+				ceSourcePrefix: "CEPrefix",
+				database:       db,
+				collection:     coll,
+				ceClient:       &failingClient{Client: ce, results: test.results},
+				logger:         logging.FromContext(ctx),
+			}
+			handled := a.sendChange(ctx, bson.M{
+				"ns": bson.M{
+					"coll": coll,
+					"db":   db,
+				},
+				"_id": bson.M{
+					"_data": ID,
+				},
+				"fullDocument": bson.M{
+					"_id": docID,
+				},
+				"operationType": test.operationType,
+			})
+			if handled != test.wantHandled {
+				t.Errorf("sendChange got %v, want %v", handled, test.wantHandled)
+			}
+			if got := len(ce.Sent()); got != test.wantSent {
+				t.Errorf("Expected %d events to be delivered, got %d", test.wantSent, got)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, val interface{}) bson.Raw {
+	t.Helper()
+	raw, err := bson.Marshal(val)
+	if err != nil {
+		t.Fatalf("Failed to marshal %v: %v", val, err)
+	}
+	return raw
+}
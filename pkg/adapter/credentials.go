@@ -0,0 +1,142 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// credentials are the mounted credentials secret and TLS files.
+type credentials struct {
+	data                 map[string][]byte
+	ca, certificate, key []byte
+}
+
+// readCredentials reads the mounted credentials.
+func (a *mongoDbAdapter) readCredentials() (*credentials, error) {
+	if a.loadCredentials != nil {
+		return a.loadCredentials()
+	}
+	data, err := readSecret(a.credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read MongoDb credentials: secretPath %s : %w", a.credentialsPath, err)
+	}
+	creds := &credentials{data: data}
+	if !a.tls {
+		return creds, nil
+	}
+	for _, file := range []struct {
+		path string
+		data *[]byte
+	}{
+		{a.tlsCAFile, &creds.ca},
+		{a.tlsCertificateFile, &creds.certificate},
+		{a.tlsKeyFile, &creds.key},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if *file.data, err = ioutil.ReadFile(file.path); err != nil {
+			return nil, fmt.Errorf("unable to read TLS file %s: %w", file.path, err)
+		}
+	}
+	return creds, nil
+}
+
+// readSecret returns the keys of the secret mounted at path.
+func readSecret(path string) (map[string][]byte, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(files))
+	for _, file := range files {
+		// Skip the directories of the atomic updates of the volume.
+		if file.IsDir() || strings.HasPrefix(file.Name(), "..") {
+			continue
+		}
+		if data[file.Name()], err = ioutil.ReadFile(filepath.Join(path, file.Name())); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// clientOptions returns the client options configured by the credentials.
+func (c *credentials) clientOptions(keys *v1alpha1.MongoDbSourceSecretKeys, tls bool) (*options.ClientOptions, error) {
+	if uri, ok := c.data[mongoclient.SecretKeys(keys).URI]; ok && len(uri) == 0 {
+		return nil, errors.New("MongoDb URI field is empty")
+	}
+	opts, err := mongoclient.ClientOptions(c.data, keys)
+	if err != nil {
+		return nil, err
+	}
+	if tls {
+		if err := mongoclient.SetTLS(opts, c.ca, c.certificate, c.key); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// equal returns whether the credentials are the same as other.
+func (c *credentials) equal(other *credentials) bool {
+	if len(c.data) != len(other.data) {
+		return false
+	}
+	for key, value := range c.data {
+		if otherValue, ok := other.data[key]; !ok || !bytes.Equal(value, otherValue) {
+			return false
+		}
+	}
+	return bytes.Equal(c.ca, other.ca) && bytes.Equal(c.certificate, other.certificate) && bytes.Equal(c.key, other.key)
+}
+
+// watchCredentials checks the mounted credentials every credentialsInterval and returns true once
+// they differ from current, or false once ctx is done.
+func (a *mongoDbAdapter) watchCredentials(ctx context.Context, current *credentials) bool {
+	ticker := time.NewTicker(a.credentialsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			creds, err := a.readCredentials()
+			if err != nil {
+				// The volume may be in the middle of an update.
+				a.logger.Desugar().Warn("Failed to check the MongoDb credentials", zap.Error(err))
+				continue
+			}
+			if !creds.equal(current) {
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/logging"
+)
+
+func TestWatchCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("Failed to create credentials directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, value string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("hosts", "host1")
+	write("password", "old")
+	// The data of secret volumes is under a hidden directory.
+	if err := os.Mkdir(filepath.Join(dir, "..data"), 0700); err != nil {
+		t.Fatalf("Failed to create data directory: %v", err)
+	}
+
+	ctx := context.Background()
+	a := &mongoDbAdapter{
+		credentialsPath:     dir,
+		credentialsInterval: time.Millisecond,
+		logger:              logging.FromContext(ctx),
+	}
+	creds, err := a.readCredentials()
+	if err != nil {
+		t.Fatalf("readCredentials got error %v", err)
+	}
+	if len(creds.data) != 2 || string(creds.data["password"]) != "old" {
+		t.Errorf("Unexpected credentials %q", creds.data)
+	}
+
+	// The credentials are unchanged until ctx is done.
+	unchangedCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if a.watchCredentials(unchangedCtx, creds) {
+		t.Errorf("watchCredentials reported unchanged credentials as changed")
+	}
+
+	write("password", "new")
+	changedCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if !a.watchCredentials(changedCtx, creds) {
+		t.Errorf("watchCredentials did not report the new password")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// historyLostCode is the code of the ChangeStreamHistoryLost error, returned since MongoDb 4.4
+	// when the oplog no longer covers the position a change stream is resumed from.
+	historyLostCode = 286
+
+	// changeStreamFatalCode is the code returned instead by earlier versions of MongoDb.
+	changeStreamFatalCode = 280
+)
+
+// gap is the data of the CloudEvent sent when the changes made during a period were lost.
+type gap struct {
+	// From is the time of the last change sent before the loss, if known.
+	From *time.Time `json:"from,omitempty"`
+	// To is the time from which the changes are sent again.
+	To time.Time `json:"to"`
+	// Collections are the watched collections, all the collections of the database if empty.
+	Collections []string `json:"collections,omitempty"`
+}
+
+// isHistoryLost returns whether err is returned because the oplog no longer covers the position a
+// change stream is resumed from, or the oplog is tailed from.
+func isHistoryLost(err error) bool {
+	var oplogErr *oplogHistoryLostError
+	if errors.As(err, &oplogErr) {
+		return true
+	}
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	return cmdErr.Code == historyLostCode || cmdErr.Code == changeStreamFatalCode
+}
+
+// recoverHistoryLost records the loss of the history of the change stream, caused by cause, and
+// applies the history lost policy. It returns the position to resume from, unless the policy is to
+// fail.
+func (a *mongoDbAdapter) recoverHistoryLost(ctx context.Context, database mongoclient.Database, cause error) (position, error) {
+	a.logger.Desugar().Warn("Change stream history lost", zap.String("policy", string(a.onHistoryLost)), zap.Error(cause))
+	a.checkpointer.markHistoryLost(&checkpoint.HistoryLost{
+		Time:    metav1.Now(),
+		Policy:  string(a.onHistoryLost),
+		Message: cause.Error(),
+	})
+
+	switch a.onHistoryLost {
+	case v1alpha1.HistoryLostRestart:
+		now, err := clusterTime(ctx, database)
+		if err != nil {
+			return position{}, fmt.Errorf("error getting the cluster time to restart from: %w", err)
+		}
+		if err := a.sendGap(ctx, a.checkpointer.acknowledged().operationTime, now); err != nil {
+			return position{}, err
+		}
+		pos := position{operationTime: &now}
+		a.checkpointer.acknowledge(pos)
+		if err := a.checkpointer.save(ctx); err != nil {
+			return position{}, fmt.Errorf("error saving checkpoint: %w", err)
+		}
+		return pos, nil
+
+	case v1alpha1.HistoryLostSnapshot:
+		return a.startSnapshot(ctx, database)
+
+	default:
+		if err := a.checkpointer.save(ctx); err != nil {
+			a.logger.Desugar().Error("Failed to save checkpoint", zap.Error(err))
+		}
+		return position{}, cause
+	}
+}
+
+// sendGap sends the CloudEvent telling that the changes made from the given time, if known, until
+// the other were lost.
+func (a *mongoDbAdapter) sendGap(ctx context.Context, from *primitive.Timestamp, to primitive.Timestamp) error {
+	data := gap{
+		To:          time.Unix(int64(to.T), 0).UTC(),
+		Collections: a.partitionCollections,
+	}
+	if from != nil {
+		t := time.Unix(int64(from.T), 0).UTC()
+		data.From = &t
+	}
+	if a.collection != "" {
+		data.Collections = []string{a.collection}
+	}
+	source, err := a.eventSource(a.collection)
+	if err != nil {
+		return err
+	}
+
+	event := cloudevents.NewEvent(cloudevents.VersionV1)
+	event.SetID(fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("gap-%s-%d.%d", source, to.T, to.I)))))
+	event.SetSource(source)
+	event.SetType(v1alpha1.MongoDbSourceGapEventType)
+	if err := event.SetData(cloudevents.ApplicationJSON, data); err != nil {
+		return fmt.Errorf("error setting gap event data: %w", err)
+	}
+	if result := a.ceClient.Send(ctx, event); cloudevents.IsUndelivered(result) {
+		return fmt.Errorf("error sending gap event: %w", result)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	testcloudclient "knative.dev/eventing/pkg/adapter/v2/test"
+	"knative.dev/pkg/logging"
+)
+
+func TestIsHistoryLost(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error"},
+		{name: "other error", err: errors.New("unavailable")},
+		{name: "other command error", err: mongo.CommandError{Code: 13, Name: "Unauthorized"}},
+		{name: "history lost", err: fmt.Errorf("error setting up changeStream: %w", mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost"}), want: true},
+		{name: "resume token not found", err: mongo.CommandError{Code: 280, Name: "ChangeStreamFatalError"}, want: true},
+		{name: "oplog rolled over", err: &oplogHistoryLostError{after: primitive.Timestamp{T: 1}, first: primitive.Timestamp{T: 2}}, want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isHistoryLost(test.err); got != test.want {
+				t.Errorf("isHistoryLost got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecoverHistoryLost(t *testing.T) {
+	lost := mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost", Message: "resume point may no longer be in the oplog"}
+	last := &primitive.Timestamp{T: 1596240000, I: 1}
+	now := primitive.Timestamp{T: 1596326400, I: 2}
+
+	tests := []struct {
+		policy     v1alpha1.HistoryLostPolicy
+		wantPos    position
+		wantErr    bool
+		wantEvents []string
+	}{
+		{
+			policy:  v1alpha1.HistoryLostFail,
+			wantPos: position{},
+			wantErr: true,
+		},
+		{
+			policy:     v1alpha1.HistoryLostRestart,
+			wantPos:    position{operationTime: &now},
+			wantEvents: []string{`{"from":"2020-08-01T00:00:00Z","to":"2020-08-02T00:00:00Z","collections":["coll"]}`},
+		},
+		{
+			policy:  v1alpha1.HistoryLostSnapshot,
+			wantPos: position{snapshot: &checkpoint.Snapshot{OperationTime: now}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			ctx := context.Background()
+			client, err := mongotesting.TestClientCreator(mongotesting.TestClientData{
+				DbData: mongotesting.TestDbData{RunCommandResult: bson.M{"operationTime": now}},
+			})()
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			ce := testcloudclient.NewTestClient()
+			store := &testStore{checkpoint: &checkpoint.Checkpoint{OperationTime: last}}
+			a := mongoDbAdapter{
+				ceSourcePrefix: "CEPrefix",
+				database:       db,
+				collection:     coll,
+				ceClient:       ce,
+				checkpointer:   newCheckpointer(store),
+				onHistoryLost:  test.policy,
+				logger:         logging.FromContext(ctx),
+			}
+			if _, err := a.checkpointer.load(ctx); err != nil {
+				t.Fatalf("load got error %v", err)
+			}
+
+			pos, err := a.recoverHistoryLost(ctx, client.Database(db), lost)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("recoverHistoryLost got error %v, want error %v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.wantPos, pos, cmp.AllowUnexported(position{})); diff != "" {
+				t.Errorf("recoverHistoryLost got unexpected position (-want +got) %s", diff)
+			}
+
+			var events []string
+			for _, event := range ce.Sent() {
+				if event.Type() != v1alpha1.MongoDbSourceGapEventType {
+					t.Errorf("Unexpected event type %q", event.Type())
+				}
+				events = append(events, string(event.Data()))
+			}
+			if diff := cmp.Diff(test.wantEvents, events); diff != "" {
+				t.Errorf("Unexpected gap events (-want +got) %s", diff)
+			}
+
+			got := store.checkpoint.HistoryLost
+			if got == nil || got.Policy != string(test.policy) || got.Message != lost.Error() {
+				t.Errorf("Expected the checkpoint to record the history loss with policy %q, got %+v", test.policy, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	kle "knative.dev/pkg/leaderelection"
+)
+
+// elector runs a function while holding a Lease shared by the replicas of the adapter. A nil
+// elector runs it right away.
+type elector struct {
+	lock   resourcelock.Interface
+	config kle.ComponentConfig
+}
+
+// run runs lead whenever the adapter is elected, until ctx is done or lead fails. The context
+// passed to lead is cancelled when the lease is lost, after which the adapter stands by again.
+func (e *elector) run(ctx context.Context, lead func(context.Context) error, logger *zap.SugaredLogger) error {
+	if e == nil {
+		return lead(ctx)
+	}
+	for {
+		lost, err := e.runTerm(ctx, lead, logger)
+		if err != nil || !lost || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// runTerm campaigns for the lease and runs lead once elected, releasing the lease when lead
+// returns. It reports whether the lease was lost before lead returned.
+func (e *elector) runTerm(ctx context.Context, lead func(context.Context) error, logger *zap.SugaredLogger) (bool, error) {
+	termCtx, cancelTerm := context.WithCancel(ctx)
+	defer cancelTerm()
+
+	elected := make(chan context.Context, 1)
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          e.lock,
+		LeaseDuration: e.config.LeaseDuration,
+		RenewDeadline: e.config.RenewDeadline,
+		RetryPeriod:   e.config.RetryPeriod,
+		// Let a standby take over right away on shutdown.
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				elected <- leaderCtx
+			},
+			OnStoppedLeading: func() {},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error creating leader elector: %w", err)
+	}
+
+	// Run does not wait for OnStartedLeading to return, so lead runs here instead.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		le.Run(termCtx)
+	}()
+
+	select {
+	case leaderCtx := <-elected:
+		logger.Desugar().Info("Elected leader", zap.String("lease", e.lock.Describe()), zap.String("identity", e.lock.Identity()))
+		err = lead(leaderCtx)
+		lost := leaderCtx.Err() != nil
+		// Release the lease only once the stream is closed and the checkpoint saved.
+		cancelTerm()
+		<-stopped
+		logger.Desugar().Info("Stopped leading", zap.String("lease", e.lock.Describe()))
+		return lost, err
+	case <-stopped:
+		// Never elected.
+		return true, nil
+	}
+}
+
+// newElector creates an elector campaigning for the Lease named in env, in the namespace of the
+// source.
+func newElector(kubeClient kubernetes.Interface, env *envConfig) (*elector, error) {
+	config, err := env.GetLeaderElectionConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing leader election config: %w", err)
+	}
+	id, err := kle.UniqueID()
+	if err != nil {
+		return nil, fmt.Errorf("error generating leader election identity: %w", err)
+	}
+	var labels map[string]string
+	if env.LeaseLabels != "" {
+		if err := json.Unmarshal([]byte(env.LeaseLabels), &labels); err != nil {
+			return nil, fmt.Errorf("error parsing lease labels: %w", err)
+		}
+	}
+	return &elector{
+		lock: &labeledLeaseLock{
+			LeaseLock: &resourcelock.LeaseLock{
+				LeaseMeta: metav1.ObjectMeta{
+					Namespace: env.Namespace,
+					Name:      env.LeaseName,
+				},
+				Client:     kubeClient.CoordinationV1(),
+				LockConfig: resourcelock.ResourceLockConfig{Identity: id},
+			},
+			labels: labels,
+		},
+		config: *config,
+	}, nil
+}
+
+// labeledLeaseLock is a LeaseLock creating its Lease with labels, by which the controller deletes
+// the Leases of a source once it is deleted, including the ones of its former partitions.
+type labeledLeaseLock struct {
+	*resourcelock.LeaseLock
+	labels map[string]string
+}
+
+// Create implements resourcelock.Interface.Create.
+func (l *labeledLeaseLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	_, err := l.Client.Leases(l.LeaseMeta.Namespace).Create(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: l.LeaseMeta.Namespace,
+			Name:      l.LeaseMeta.Name,
+			Labels:    l.labels,
+		},
+		Spec: resourcelock.LeaderElectionRecordToLeaseSpec(&ler),
+	})
+	if err != nil {
+		return err
+	}
+	// Load the created Lease, which the renewals update.
+	_, _, err = l.LeaseLock.Get()
+	return err
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	kle "knative.dev/pkg/leaderelection"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func newTestElector(kubeClient kubernetes.Interface, id string) *elector {
+	return &elector{
+		lock: &labeledLeaseLock{
+			LeaseLock: &resourcelock.LeaseLock{
+				LeaseMeta:  metav1.ObjectMeta{Namespace: "ns", Name: "lease"},
+				Client:     kubeClient.CoordinationV1(),
+				LockConfig: resourcelock.ResourceLockConfig{Identity: id},
+			},
+			labels: map[string]string{"google-source-name": "source"},
+		},
+		config: kle.ComponentConfig{
+			LeaseDuration: 2 * time.Second,
+			RenewDeadline: time.Second,
+			RetryPeriod:   50 * time.Millisecond,
+		},
+	}
+}
+
+// runElector runs e with a lead function signalling on leading until its context is done.
+func runElector(ctx context.Context, t *testing.T, e *elector, leading chan<- string, id string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- e.run(ctx, func(ctx context.Context) error {
+			leading <- id
+			<-ctx.Done()
+			return nil
+		}, logtesting.TestLogger(t))
+	}()
+	return done
+}
+
+func TestElectorNil(t *testing.T) {
+	var e *elector
+	called := false
+	if err := e.run(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	}, logtesting.TestLogger(t)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("lead was not called")
+	}
+}
+
+func TestElectorTakeover(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	leading := make(chan string, 2)
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	doneA := runElector(ctxA, t, newTestElector(kubeClient, "a"), leading, "a")
+	select {
+	case id := <-leading:
+		if id != "a" {
+			t.Fatalf("unexpected leader %q", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a was not elected")
+	}
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	doneB := runElector(ctxB, t, newTestElector(kubeClient, "b"), leading, "b")
+	select {
+	case id := <-leading:
+		t.Fatalf("%q elected while a holds the lease", id)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// a shuts down and releases the lease.
+	cancelA()
+	if err := <-doneA; err != nil {
+		t.Errorf("unexpected error from a: %v", err)
+	}
+	select {
+	case id := <-leading:
+		if id != "b" {
+			t.Fatalf("unexpected leader %q", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("b did not take over")
+	}
+
+	cancelB()
+	if err := <-doneB; err != nil {
+		t.Errorf("unexpected error from b: %v", err)
+	}
+
+	lease, err := kubeClient.CoordinationV1().Leases("ns").Get("lease", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting the lease: %v", err)
+	}
+	if got := lease.Labels["google-source-name"]; got != "source" {
+		t.Errorf("unexpected lease labels %v", lease.Labels)
+	}
+}
+
+func TestElectorLeadError(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	wantErr := errors.New("stream failed")
+
+	err := newTestElector(kubeClient, "a").run(context.Background(), func(context.Context) error {
+		return wantErr
+	}, logtesting.TestLogger(t))
+	if !errors.Is(err, wantErr) {
+		t.Errorf("run() = %v, want %v", err, wantErr)
+	}
+
+	lease, err := kubeClient.CoordinationV1().Leases("ns").Get("lease", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting lease: %v", err)
+	}
+	if holder := lease.Spec.HolderIdentity; holder != nil && *holder != "" {
+		t.Errorf("lease still held by %q", *holder)
+	}
+}
@@ -0,0 +1,510 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/eventing/pkg/adapter/v2"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/logging"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	sourcesinformers "github.com/googleinterns/knative-source-mongodb/pkg/client/informers/externalversions/sources/v1alpha1"
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+)
+
+const (
+	// minRestartDelay and maxRestartDelay bound the time the multi-tenant receive adapter waits
+	// before restarting the stream of a source that stopped.
+	minRestartDelay = time.Second
+	maxRestartDelay = 5 * time.Minute
+)
+
+type mtEnvConfig struct {
+	adapter.EnvConfig
+
+	// Scope is the scope of the sources watched: "namespace" for those of the namespace of the
+	// receive adapter, or "cluster" for all of them.
+	Scope               string        `envconfig:"MONGODB_SCOPE" default:"cluster"`
+	CheckpointInterval  time.Duration `envconfig:"MONGODB_CHECKPOINT_INTERVAL" default:"5s"`
+	ShutdownGracePeriod time.Duration `envconfig:"MONGODB_SHUTDOWN_GRACE_PERIOD" default:"20s"`
+}
+
+// multiTenantAdapter watches the MongoDbSources of its scope and runs the stream of each of them,
+// starting, restarting and stopping them as the sources change.
+type multiTenantAdapter struct {
+	// scope is the scope of the sources watched, in namespace if it is ScopeNamespace.
+	scope     string
+	namespace string
+	ceClient  cloudevents.Client
+	// sourceInformer notifies the changes of the sources, whose secrets are read from secretLister.
+	sourceInformer cache.SharedIndexInformer
+	secretLister   corev1listers.SecretLister
+	kubeClient     kubernetes.Interface
+	// checkpointInterval and shutdownGracePeriod configure the streams of all the sources, unless
+	// a source sets its own shutdown grace period.
+	checkpointInterval  time.Duration
+	shutdownGracePeriod time.Duration
+	// createClientFn creates the Mongo clients, it is replaced in unit tests.
+	createClientFn mongoclient.CreateFn
+	// run runs the stream of a source until ctx is done, it is replaced in unit tests.
+	run    func(ctx context.Context, src *v1alpha1.MongoDbSource)
+	logger *zap.SugaredLogger
+
+	// mu guards ctx, tenants and stopping.
+	mu sync.Mutex
+	// ctx is the context of the streams, set once the adapter starts.
+	ctx     context.Context
+	tenants map[types.NamespacedName]*tenant
+	// stopping holds the done channels of the streams stopped but still recording their final
+	// checkpoint, which the next stream of the same source waits for.
+	stopping map[types.NamespacedName]chan struct{}
+	// streams waits for the streams to stop.
+	streams sync.WaitGroup
+}
+
+// tenant is a source whose stream is run by the multi-tenant receive adapter.
+type tenant struct {
+	// config identifies the configuration the stream runs with.
+	config string
+	cancel context.CancelFunc
+	// done is closed once the stream stopped.
+	done chan struct{}
+}
+
+// NewMultiTenantEnvConfig creates an empty environment variables configuration of the
+// multi-tenant receive adapter.
+func NewMultiTenantEnvConfig() adapter.EnvConfigAccessor {
+	return &mtEnvConfig{}
+}
+
+// WatchedNamespace returns the namespace of the sources watched by the multi-tenant receive
+// adapter configured by env, or "" if it watches those of the whole cluster.
+func WatchedNamespace(env adapter.EnvConfigAccessor) string {
+	if mtEnv := env.(*mtEnvConfig); mtEnv.Scope == v1alpha1.ScopeNamespace {
+		return mtEnv.Namespace
+	}
+	return ""
+}
+
+// NewMultiTenantAdapter creates an adapter running the streams of the MongoDbSources notified by
+// sources, whose secrets are read from secrets.
+func NewMultiTenantAdapter(ctx context.Context, processed adapter.EnvConfigAccessor, ceClient cloudevents.Client, sources sourcesinformers.MongoDbSourceInformer, secrets corev1listers.SecretLister) adapter.Adapter {
+	logger := logging.FromContext(ctx)
+	env := processed.(*mtEnvConfig)
+
+	switch env.Scope {
+	case v1alpha1.ScopeNamespace, v1alpha1.ScopeCluster:
+	default:
+		logger.Fatalw("Invalid scope", zap.String("scope", env.Scope))
+	}
+
+	a := &multiTenantAdapter{
+		scope:               env.Scope,
+		namespace:           env.Namespace,
+		ceClient:            ceClient,
+		sourceInformer:      sources.Informer(),
+		secretLister:        secrets,
+		kubeClient:          kubeclient.Get(ctx),
+		checkpointInterval:  env.CheckpointInterval,
+		shutdownGracePeriod: env.ShutdownGracePeriod,
+		createClientFn:      mongoclient.NewClient,
+		logger:              logger,
+		tenants:             make(map[types.NamespacedName]*tenant),
+		stopping:            make(map[types.NamespacedName]chan struct{}),
+	}
+	a.run = a.runSource
+	return a
+}
+
+// Start runs the streams of the sources of the scope of the adapter until ctx is done, then waits
+// for them to stop.
+func (a *multiTenantAdapter) Start(ctx context.Context) error {
+	a.mu.Lock()
+	a.ctx = ctx
+	a.mu.Unlock()
+
+	// The sources leaving the scope are deleted from the handler.
+	a.sourceInformer.AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: a.watches,
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    a.update,
+			UpdateFunc: func(_, obj interface{}) { a.update(obj) },
+			DeleteFunc: a.remove,
+		},
+	})
+
+	<-ctx.Done()
+	a.logger.Info("Stopping the streams of the sources")
+	a.streams.Wait()
+	return nil
+}
+
+// watches returns whether obj is a source of the scope of the adapter.
+func (a *multiTenantAdapter) watches(obj interface{}) bool {
+	src, ok := obj.(*v1alpha1.MongoDbSource)
+	if !ok {
+		return false
+	}
+	if a.scope == v1alpha1.ScopeNamespace {
+		return src.Scope() == v1alpha1.ScopeNamespace && src.Namespace == a.namespace
+	}
+	return src.Scope() == v1alpha1.ScopeCluster
+}
+
+// runnable returns whether the stream of a source can run: the source is not deleted nor
+// suspended and the controller resolved its sink. The stream of a suspended source is stopped, and
+// continues from its final checkpoint when the source is resumed.
+func runnable(src *v1alpha1.MongoDbSource) bool {
+	return src.DeletionTimestamp == nil && !src.Spec.Suspend && src.Status.SinkURI != nil
+}
+
+// connected returns whether the controller established the connection of the source. A stream is
+// only started once it did, but a running stream is not stopped when a later check fails: it
+// reconnects with backoff on its own.
+func connected(src *v1alpha1.MongoDbSource) bool {
+	return src.Status.GetCondition(v1alpha1.MongoDbConditionConnectionEstablished).IsTrue()
+}
+
+// tenantConfig returns a hash of the configuration of the stream of a source, which is restarted
+// whenever it changes.
+func tenantConfig(src *v1alpha1.MongoDbSource) (string, error) {
+	b, err := json.Marshal(struct {
+		UID     types.UID                  `json:"uid"`
+		Spec    v1alpha1.MongoDbSourceSpec `json:"spec"`
+		SinkURI string                     `json:"sinkUri"`
+		Rewind  string                     `json:"rewind,omitempty"`
+	}{src.UID, src.Spec, src.Status.SinkURI.String(), src.Annotations[v1alpha1.RewindAnnotation]})
+	if err != nil {
+		return "", fmt.Errorf("failure to hash the source configuration: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// update starts the stream of a source once it is runnable and connected, restarts it when its
+// configuration changes, and stops it if it is no longer runnable.
+func (a *multiTenantAdapter) update(obj interface{}) {
+	src := obj.(*v1alpha1.MongoDbSource)
+	key := types.NamespacedName{Namespace: src.Namespace, Name: src.Name}
+	if !runnable(src) {
+		a.stop(key)
+		return
+	}
+	config, err := tenantConfig(src)
+	if err != nil {
+		a.logger.Errorw("Failed to update the stream of the source", zap.String("source", key.String()), zap.Error(err))
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ctx == nil || a.ctx.Err() != nil {
+		return
+	}
+	t, running := a.tenants[key]
+	if running && t.config == config || !running && !connected(src) {
+		return
+	}
+	// The new stream waits for the previous one to record its final checkpoint, whether it is
+	// restarted or was stopped.
+	previous := make(chan struct{})
+	close(previous)
+	if done, ok := a.stopping[key]; ok {
+		previous = done
+		delete(a.stopping, key)
+	}
+	if running {
+		a.logger.Infow("Restarting the stream of the updated source", zap.String("source", key.String()))
+		t.cancel()
+		previous = t.done
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	t = &tenant{config: config, cancel: cancel, done: make(chan struct{})}
+	a.tenants[key] = t
+	a.streams.Add(1)
+	go func() {
+		defer a.streams.Done()
+		defer a.stopped(key, t.done)
+		<-previous
+		if ctx.Err() == nil {
+			a.run(ctx, src.DeepCopy())
+		}
+	}()
+}
+
+// remove stops the stream of a deleted source, or of a source that left the scope of the adapter.
+func (a *multiTenantAdapter) remove(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		a.logger.Errorw("Failed to get the key of the deleted source", zap.Error(err))
+		return
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		a.logger.Errorw("Failed to split the key of the deleted source", zap.Error(err))
+		return
+	}
+	a.stop(types.NamespacedName{Namespace: namespace, Name: name})
+}
+
+// stop stops the stream of a source, if it runs. The stream is kept in stopping until it recorded
+// its final checkpoint.
+func (a *multiTenantAdapter) stop(key types.NamespacedName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if t, ok := a.tenants[key]; ok {
+		a.logger.Infow("Stopping the stream of the source", zap.String("source", key.String()))
+		t.cancel()
+		delete(a.tenants, key)
+		a.stopping[key] = t.done
+	}
+}
+
+// stopped closes done once the stream of a source stopped, and forgets it if it was stopping.
+func (a *multiTenantAdapter) stopped(key types.NamespacedName, done chan struct{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	close(done)
+	if a.stopping[key] == done {
+		delete(a.stopping, key)
+	}
+}
+
+// runSource runs the stream of a source until ctx is done, restarting it with backoff whenever it
+// stops.
+func (a *multiTenantAdapter) runSource(ctx context.Context, src *v1alpha1.MongoDbSource) {
+	logger := a.logger.With(zap.String("source", src.Namespace+"/"+src.Name))
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		sa, err := a.newSourceAdapter(src, logger)
+		if err == nil {
+			err = sa.Start(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("the stream ended")
+		}
+		// Restart quickly a stream that ran for a while.
+		if time.Since(started) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+		logger.Errorw("The stream of the source stopped, restarting", zap.Error(err), zap.Duration("delay", delay))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+}
+
+// newSourceAdapter creates the adapter of the stream of a source, configured from its spec as the
+// controller configures its own receive adapter.
+func (a *multiTenantAdapter) newSourceAdapter(src *v1alpha1.MongoDbSource, logger *zap.SugaredLogger) (*mongoDbAdapter, error) {
+	loadCredentials := func() (*credentials, error) {
+		return a.readSourceCredentials(src)
+	}
+	creds, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	clusterName, err := mongoclient.ClusterName(creds.data, src.Spec.Secret.Keys, src.Status.Topology)
+	if err != nil {
+		return nil, err
+	}
+	var sourceTemplate *template.Template
+	if src.Spec.SourceTemplate != "" {
+		if sourceTemplate, err = v1alpha1.ParseSourceTemplate(src.Spec.SourceTemplate); err != nil {
+			return nil, fmt.Errorf("invalid source template: %w", err)
+		}
+	}
+
+	startEnv := &envConfig{}
+	if startAt := src.Spec.StartAt; startAt != nil {
+		startEnv.StartAtResumeToken = startAt.ResumeToken
+		if startAt.Time != nil {
+			startEnv.StartAtTime = startAt.Time.UTC().Format(time.RFC3339)
+		}
+	}
+	startAt, err := parseStartAt(startEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	store := checkpoint.NewConfigMapStore(a.kubeClient, src.Namespace, checkpoint.ConfigMapName(src), checkpoint.DefaultKey)
+	checkpointer := newCheckpointer(store)
+	checkpointer.rewind = src.Annotations[v1alpha1.RewindAnnotation]
+
+	sa := &mongoDbAdapter{
+		name:      src.Name,
+		namespace: src.Namespace,
+		ceClient: &tenantClient{
+			Client:    a.ceClient,
+			target:    src.Status.SinkURI.String(),
+			overrides: src.Spec.CloudEventOverrides,
+			tag: &adapter.MetricTag{
+				Name:          src.Name,
+				Namespace:     src.Namespace,
+				ResourceGroup: "mongodbsources.sources.google.com",
+			},
+		},
+		ceSourcePrefix:      ceSourcePrefix(clusterName),
+		clusterName:         clusterName,
+		sourceTemplate:      sourceTemplate,
+		database:            src.Spec.Database,
+		collection:          src.Spec.Collection,
+		secretKeys:          src.Spec.Secret.Keys,
+		tls:                 src.Spec.TLS != nil,
+		connection:          src.Spec.Connection,
+		credentialsInterval: credentialsCheckInterval,
+		loadCredentials:     loadCredentials,
+		snapshotMode:        v1alpha1.SnapshotNever,
+		mode:                v1alpha1.SourceModeChangeStream,
+		pollField:           "_id",
+		pollInterval:        10 * time.Second,
+		onHistoryLost:       v1alpha1.HistoryLostFail,
+		startAt:             startAt,
+		createClientFn:      a.createClientFn,
+		traceParentField:    src.Spec.TraceParentField,
+		checkpointer:        checkpointer,
+		checkpointInterval:  a.checkpointInterval,
+		shutdownGracePeriod: a.shutdownGracePeriod,
+		logger:              logger,
+	}
+	if snapshot := src.Spec.Snapshot; snapshot != nil {
+		sa.snapshotMode = snapshot.Mode
+		if snapshot.BatchSize != nil {
+			sa.snapshotBatchSize = *snapshot.BatchSize
+		}
+	}
+	if src.Spec.Mode != "" {
+		sa.mode = src.Spec.Mode
+	}
+	if poll := src.Spec.Poll; poll != nil {
+		if poll.Field != "" {
+			sa.pollField = poll.Field
+		}
+		if poll.IntervalSeconds != nil {
+			sa.pollInterval = time.Duration(*poll.IntervalSeconds) * time.Second
+		}
+		sa.pollDetectDeletes = poll.DetectDeletes
+	}
+	if src.Spec.OnHistoryLost != "" {
+		sa.onHistoryLost = src.Spec.OnHistoryLost
+	}
+	if src.Spec.ShutdownGracePeriodSeconds != nil {
+		sa.shutdownGracePeriod = time.Duration(*src.Spec.ShutdownGracePeriodSeconds) * time.Second
+	}
+	return sa, nil
+}
+
+// readSourceCredentials reads the credentials and the TLS files of a source from its secrets.
+func (a *multiTenantAdapter) readSourceCredentials(src *v1alpha1.MongoDbSource) (*credentials, error) {
+	secret, err := a.secretLister.Secrets(src.Namespace).Get(src.Spec.Secret.Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read MongoDb credentials secret %q: %w", src.Spec.Secret.Name, err)
+	}
+	creds := &credentials{data: secret.Data}
+	tls := src.Spec.TLS
+	if tls == nil {
+		return creds, nil
+	}
+	for _, file := range []struct {
+		selector *corev1.SecretKeySelector
+		data     *[]byte
+	}{
+		{tls.CA, &creds.ca},
+		{tls.Certificate, &creds.certificate},
+		{tls.Key, &creds.key},
+	} {
+		if file.selector == nil {
+			continue
+		}
+		secret, err := a.secretLister.Secrets(src.Namespace).Get(file.selector.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read TLS secret %q: %w", file.selector.Name, err)
+		}
+		value, ok := secret.Data[file.selector.Key]
+		if !ok {
+			return nil, fmt.Errorf("TLS secret %q has no key %q", file.selector.Name, file.selector.Key)
+		}
+		*file.data = value
+	}
+	return creds, nil
+}
+
+// tenantClient sends the events of a source of the multi-tenant receive adapter to its sink, with
+// its CloudEvent overrides and metric tags.
+type tenantClient struct {
+	cloudevents.Client
+	target    string
+	overrides *duckv1.CloudEventOverrides
+	tag       *adapter.MetricTag
+}
+
+// Send implements cloudevents.Client.Send.
+func (c *tenantClient) Send(ctx context.Context, event cloudevents.Event) protocol.Result {
+	c.applyOverrides(&event)
+	return c.Client.Send(c.context(ctx), event)
+}
+
+// Request implements cloudevents.Client.Request.
+func (c *tenantClient) Request(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, protocol.Result) {
+	c.applyOverrides(&event)
+	return c.Client.Request(c.context(ctx), event)
+}
+
+// context returns ctx sending to the sink of the source and reporting its metrics.
+func (c *tenantClient) context(ctx context.Context) context.Context {
+	return adapter.ContextWithMetricTag(cloudevents.ContextWithTarget(ctx, c.target), c.tag)
+}
+
+// applyOverrides sets the extensions overridden by the source.
+func (c *tenantClient) applyOverrides(event *cloudevents.Event) {
+	if c.overrides == nil {
+		return
+	}
+	for name, value := range c.overrides.Extensions {
+		event.SetExtension(name, value)
+	}
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/eventing/pkg/adapter/v2"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/logging"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+)
+
+// makeTenantSource returns a source of the given scope whose connection was checked.
+func makeTenantSource(scope, database string) *v1alpha1.MongoDbSource {
+	src := &v1alpha1.MongoDbSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "source",
+			Namespace:   "namespace",
+			UID:         "uid",
+			Annotations: map[string]string{v1alpha1.ScopeAnnotation: scope},
+		},
+		Spec: v1alpha1.MongoDbSourceSpec{
+			Secret:   v1alpha1.MongoDbSourceSecret{LocalObjectReference: corev1.LocalObjectReference{Name: "secret"}},
+			Database: database,
+		},
+	}
+	src.Status.InitializeConditions()
+	src.Status.MarkSink(apis.HTTP("sink.namespace.svc.cluster.local"))
+	src.Status.MarkConnectionSuccess()
+	return src
+}
+
+func TestMultiTenantAdapterWatches(t *testing.T) {
+	cluster := &multiTenantAdapter{scope: v1alpha1.ScopeCluster}
+	namespaced := &multiTenantAdapter{scope: v1alpha1.ScopeNamespace, namespace: "namespace"}
+	other := makeTenantSource(v1alpha1.ScopeNamespace, "db")
+	other.Namespace = "other"
+
+	for _, test := range []struct {
+		name string
+		a    *multiTenantAdapter
+		obj  interface{}
+		want bool
+	}{
+		{"cluster source", cluster, makeTenantSource(v1alpha1.ScopeCluster, "db"), true},
+		{"resource source", cluster, makeTenantSource(v1alpha1.ScopeResource, "db"), false},
+		{"namespace source", namespaced, makeTenantSource(v1alpha1.ScopeNamespace, "db"), true},
+		{"namespace source of another namespace", namespaced, other, false},
+		{"cluster source in a namespace", namespaced, makeTenantSource(v1alpha1.ScopeCluster, "db"), false},
+		{"not a source", cluster, &corev1.Secret{}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.a.watches(test.obj); got != test.want {
+				t.Errorf("watches got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMultiTenantAdapterTenants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type run struct {
+		database string
+		ctx      context.Context
+	}
+	runs := make(chan run, 10)
+	// running counts the streams of the source, which never run concurrently.
+	var running int32
+	a := &multiTenantAdapter{
+		scope: v1alpha1.ScopeCluster,
+		run: func(ctx context.Context, src *v1alpha1.MongoDbSource) {
+			if atomic.AddInt32(&running, 1) > 1 {
+				t.Error("The stream started before the previous one recorded its final checkpoint")
+			}
+			defer atomic.AddInt32(&running, -1)
+			runs <- run{database: src.Spec.Database, ctx: ctx}
+			<-ctx.Done()
+			// The stream records its final checkpoint.
+			time.Sleep(10 * time.Millisecond)
+		},
+		logger:   logging.FromContext(ctx),
+		ctx:      ctx,
+		tenants:  make(map[types.NamespacedName]*tenant),
+		stopping: make(map[types.NamespacedName]chan struct{}),
+	}
+	next := func() run {
+		t.Helper()
+		select {
+		case r := <-runs:
+			return r
+		case <-time.After(10 * time.Second):
+			t.Fatal("The stream of the source did not start")
+			return run{}
+		}
+	}
+
+	// The stream starts once the connection of the source was checked.
+	pending := makeTenantSource(v1alpha1.ScopeCluster, "db")
+	pending.Status.MarkConnectionFailed("ServerUnreachable", "")
+	a.update(pending)
+	a.update(makeTenantSource(v1alpha1.ScopeCluster, "db"))
+	first := next()
+	if first.database != "db" {
+		t.Errorf("Started the stream of database %q, want %q", first.database, "db")
+	}
+
+	// A failed check of the connection does not stop the running stream.
+	a.update(pending)
+	if first.ctx.Err() != nil {
+		t.Error("The stream was stopped by a failed check of the connection")
+	}
+
+	// The stream is restarted when the source changes, once the previous one stopped.
+	a.update(makeTenantSource(v1alpha1.ScopeCluster, "db"))
+	a.update(makeTenantSource(v1alpha1.ScopeCluster, "otherDb"))
+	second := next()
+	if second.database != "otherDb" {
+		t.Errorf("Restarted the stream of database %q, want %q", second.database, "otherDb")
+	}
+	if first.ctx.Err() == nil {
+		t.Error("The previous stream was not stopped")
+	}
+
+	// The stream stops while the source is suspended, and starts again from its final checkpoint
+	// when it is resumed.
+	suspended := makeTenantSource(v1alpha1.ScopeCluster, "otherDb")
+	suspended.Spec.Suspend = true
+	a.update(suspended)
+	if second.ctx.Err() == nil {
+		t.Error("The stream of the suspended source was not stopped")
+	}
+	a.update(makeTenantSource(v1alpha1.ScopeCluster, "otherDb"))
+	third := next()
+	if third.database != "otherDb" {
+		t.Errorf("Resumed the stream of database %q, want %q", third.database, "otherDb")
+	}
+
+	// The stream stops with the source.
+	a.remove(makeTenantSource(v1alpha1.ScopeCluster, "otherDb"))
+	<-third.ctx.Done()
+	a.streams.Wait()
+	select {
+	case r := <-runs:
+		t.Errorf("Unexpected stream of database %q", r.database)
+	default:
+	}
+}
+
+func TestMultiTenantAdapterStopThenUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The streams record their final checkpoint once checkpointed is closed.
+	started := make(chan context.Context, 10)
+	checkpointed := make(chan struct{})
+	a := &multiTenantAdapter{
+		scope: v1alpha1.ScopeCluster,
+		run: func(ctx context.Context, src *v1alpha1.MongoDbSource) {
+			started <- ctx
+			<-ctx.Done()
+			<-checkpointed
+		},
+		logger:   logging.FromContext(ctx),
+		ctx:      ctx,
+		tenants:  make(map[types.NamespacedName]*tenant),
+		stopping: make(map[types.NamespacedName]chan struct{}),
+	}
+
+	a.update(makeTenantSource(v1alpha1.ScopeCluster, "db"))
+	var first context.Context
+	select {
+	case first = <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The stream of the source did not start")
+	}
+
+	// The stream of a source stopped then runnable again waits for the final checkpoint of the
+	// stopped one.
+	suspended := makeTenantSource(v1alpha1.ScopeCluster, "db")
+	suspended.Spec.Suspend = true
+	a.update(suspended)
+	<-first.Done()
+	a.update(makeTenantSource(v1alpha1.ScopeCluster, "db"))
+	select {
+	case <-started:
+		t.Fatal("The stream started before the stopped one recorded its final checkpoint")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(checkpointed)
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The stream of the source did not start again")
+	}
+	a.mu.Lock()
+	if len(a.stopping) != 0 {
+		t.Errorf("The stopped streams were not forgotten: %v", a.stopping)
+	}
+	a.mu.Unlock()
+
+	// The stopped streams are forgotten once they recorded their final checkpoint.
+	a.remove(makeTenantSource(v1alpha1.ScopeCluster, "db"))
+	a.streams.Wait()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.stopping) != 0 {
+		t.Errorf("The stopped streams were not forgotten: %v", a.stopping)
+	}
+}
+
+func TestNewSourceAdapter(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, secret := range []*corev1.Secret{{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "namespace"},
+		Data:       map[string][]byte{"hosts": []byte("mongo-0:27017,mongo-1:27017"), "password": []byte("password")},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "namespace"},
+		Data:       map[string][]byte{"ca.crt": []byte("ca")},
+	}} {
+		if err := indexer.Add(secret); err != nil {
+			t.Fatalf("Failed to add secret: %v", err)
+		}
+	}
+	a := &multiTenantAdapter{
+		secretLister:        corev1listers.NewSecretLister(indexer),
+		checkpointInterval:  time.Second,
+		shutdownGracePeriod: 20 * time.Second,
+		logger:              logging.FromContext(context.Background()),
+	}
+
+	src := makeTenantSource(v1alpha1.ScopeCluster, "db")
+	src.Spec.Collection = "coll"
+	src.Spec.TLS = &v1alpha1.MongoDbSourceTLS{
+		CA: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}, Key: "ca.crt"},
+	}
+	src.Spec.Mode = v1alpha1.SourceModePoll
+	interval := int64(30)
+	src.Spec.Poll = &v1alpha1.MongoDbSourcePoll{Field: "updatedAt", IntervalSeconds: &interval}
+	gracePeriod := int64(5)
+	src.Spec.ShutdownGracePeriodSeconds = &gracePeriod
+
+	sa, err := a.newSourceAdapter(src, a.logger)
+	if err != nil {
+		t.Fatalf("newSourceAdapter got error %v", err)
+	}
+	if sa.ceSourcePrefix != "mongodb://mongo-0,mongo-1" {
+		t.Errorf("ceSourcePrefix got %q, want %q", sa.ceSourcePrefix, "mongodb://mongo-0,mongo-1")
+	}
+	if sa.database != "db" || sa.collection != "coll" || sa.mode != v1alpha1.SourceModePoll ||
+		sa.pollField != "updatedAt" || sa.pollInterval != 30*time.Second || sa.shutdownGracePeriod != 5*time.Second ||
+		sa.onHistoryLost != v1alpha1.HistoryLostFail || sa.snapshotMode != v1alpha1.SnapshotNever {
+		t.Errorf("Unexpected source adapter %+v", sa)
+	}
+	creds, err := sa.readCredentials()
+	if err != nil {
+		t.Fatalf("readCredentials got error %v", err)
+	}
+	if string(creds.data["password"]) != "password" || string(creds.ca) != "ca" {
+		t.Errorf("Unexpected credentials %q, CA %q", creds.data, creds.ca)
+	}
+
+	// The source is named after the replica set once the controller found it.
+	src.Status.Topology = &v1alpha1.MongoDbSourceTopology{Type: v1alpha1.TopologyReplicaSet, ReplicaSet: "rs0"}
+	src.Spec.SourceTemplate = "{{.Cluster}}/{{.Collection}}"
+	if sa, err = a.newSourceAdapter(src, a.logger); err != nil {
+		t.Fatalf("newSourceAdapter got error %v", err)
+	}
+	if source, err := sa.eventSource("coll"); err != nil || source != "rs0/coll" {
+		t.Errorf("eventSource got %q, %v, want %q", source, err, "rs0/coll")
+	}
+
+	// The stream cannot start without its secret.
+	src.Spec.Secret.Name = "missing"
+	if _, err := a.newSourceAdapter(src, a.logger); err == nil {
+		t.Error("newSourceAdapter got no error for a missing secret")
+	}
+}
+
+// recordingClient records the context and the event of the last send.
+type recordingClient struct {
+	cloudevents.Client
+	ctx   context.Context
+	event cloudevents.Event
+}
+
+func (c *recordingClient) Send(ctx context.Context, event cloudevents.Event) protocol.Result {
+	c.ctx, c.event = ctx, event
+	return nil
+}
+
+func TestTenantClient(t *testing.T) {
+	inner := &recordingClient{}
+	tag := &adapter.MetricTag{Name: "source", Namespace: "namespace", ResourceGroup: "mongodbsources.sources.google.com"}
+	c := &tenantClient{
+		Client:    inner,
+		target:    "http://sink.namespace.svc.cluster.local",
+		overrides: &duckv1.CloudEventOverrides{Extensions: map[string]string{"team": "orders"}},
+		tag:       tag,
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID("id")
+	c.Send(context.Background(), event)
+
+	if got := cecontext.TargetFrom(inner.ctx); got == nil || got.String() != c.target {
+		t.Errorf("Send target got %v, want %s", got, c.target)
+	}
+	if diff := cmp.Diff(tag, adapter.MetricTagFromContext(inner.ctx)); diff != "" {
+		t.Errorf("Send metric tag (-want +got) %s", diff)
+	}
+	if got := inner.event.Extensions()["team"]; got != "orders" {
+		t.Errorf("Send extension got %v, want orders", got)
+	}
+}
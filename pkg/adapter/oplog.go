@@ -0,0 +1,434 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// oplogDatabase and oplogCollection hold the oplog of a replica set member.
+	oplogDatabase   = "local"
+	oplogCollection = "oplog.rs"
+
+	// oplogRetryInterval is the time waited before reopening a dead oplog cursor.
+	oplogRetryInterval = time.Second
+)
+
+// tailOplog sends the changes of the watched collections read from the oplog with a tailable
+// cursor, until ctx is done. It resumes after the operation time of the checkpoint, or starts
+// at the start position, or after the last entry of the oplog. When the oplog no longer covers that
+// time, it follows the history lost policy.
+func (a *mongoDbAdapter) tailOplog(ctx context.Context, client mongoclient.Client) error {
+	pos, err := a.checkpointer.load(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading checkpoint: %w", err)
+	}
+	if pos.isZero() {
+		pos = a.startAt
+	}
+
+	// Periodically persist the position of the adapter while processing, and once done.
+	defer a.startCheckpoints(ctx)()
+
+	oplog := client.Database(oplogDatabase).Collection(oplogCollection)
+	database := client.Database(a.database)
+	after := pos.operationTime
+	if pos.snapshot != nil {
+		// The history lost policy snapshots the collections again.
+		if after, err = a.sendOplogSnapshot(ctx, database, *pos.snapshot); err != nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	if after == nil {
+		if after, err = lastOplogTime(ctx, oplog); err != nil {
+			return fmt.Errorf("error reading the end of the oplog: %w", err)
+		}
+		a.checkpointer.acknowledge(position{operationTime: after})
+	}
+
+	for {
+		// The oplog may have rolled over the position while the adapter was down, or since the
+		// cursor died.
+		if err = checkOplogCovers(ctx, oplog, *after); isHistoryLost(err) {
+			if after, err = a.recoverOplogHistoryLost(ctx, database, err); err != nil || ctx.Err() != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("error reading the start of the oplog: %w", err)
+		}
+		if after, err = a.tailOplogOnce(ctx, oplog, database, after); err != nil {
+			return err
+		}
+		// The cursor dies when the oplog rolls over it, or if the oplog was empty.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(oplogRetryInterval):
+		}
+	}
+}
+
+// recoverOplogHistoryLost applies the history lost policy when the oplog no longer covers the
+// position it is tailed from, and returns the time to tail it from instead.
+func (a *mongoDbAdapter) recoverOplogHistoryLost(ctx context.Context, database mongoclient.Database, cause error) (*primitive.Timestamp, error) {
+	pos, err := a.recoverHistoryLost(ctx, database, cause)
+	if err != nil {
+		return nil, err
+	}
+	if pos.snapshot != nil {
+		return a.sendOplogSnapshot(ctx, database, *pos.snapshot)
+	}
+	return pos.operationTime, nil
+}
+
+// sendOplogSnapshot sends the rest of the snapshot in progress, and returns the time to tail the
+// oplog from once it is sent. It returns nil if ctx is done before.
+func (a *mongoDbAdapter) sendOplogSnapshot(ctx context.Context, database mongoclient.Database, progress checkpoint.Snapshot) (*primitive.Timestamp, error) {
+	if err := a.sendSnapshot(ctx, database, progress); err != nil || ctx.Err() != nil {
+		return nil, err
+	}
+	after := progress.OperationTime
+	a.checkpointer.acknowledge(position{operationTime: &after})
+	return &after, nil
+}
+
+// oplogHistoryLostError is returned when the oplog no longer covers the time it is tailed from.
+type oplogHistoryLostError struct {
+	after, first primitive.Timestamp
+}
+
+// Error implements error.Error.
+func (e *oplogHistoryLostError) Error() string {
+	return fmt.Sprintf("the oplog starts at %d.%d, after the time %d.%d it is tailed from", e.first.T, e.first.I, e.after.T, e.after.I)
+}
+
+// checkOplogCovers returns an oplogHistoryLostError if entries logged after the given time may have
+// been removed from the oplog, which is when its first entry is more recent.
+func checkOplogCovers(ctx context.Context, oplog mongoclient.Collection, after primitive.Timestamp) error {
+	first, err := oplogTime(ctx, oplog, 1)
+	if err != nil {
+		return err
+	}
+	if *first == (primitive.Timestamp{}) || primitive.CompareTimestamp(*first, after) <= 0 {
+		return nil
+	}
+	return &oplogHistoryLostError{after: after, first: *first}
+}
+
+// tailOplogOnce sends the changes read from the oplog after the given time until the cursor dies
+// or ctx is done. It returns the time of the last entry read.
+func (a *mongoDbAdapter) tailOplogOnce(ctx context.Context, oplog mongoclient.Collection, database mongoclient.Database, after *primitive.Timestamp) (*primitive.Timestamp, error) {
+	// The oplog replay flag lets the server start from the ts of the filter instead of scanning the
+	// oplog from its start.
+	opts := options.Find().SetCursorType(options.TailableAwait).SetOplogReplay(true)
+	opts.BatchSize, opts.MaxAwaitTime = mongoclient.BatchSize(a.connection), mongoclient.MaxAwaitTime(a.connection)
+	cursor, err := oplog.Find(ctx, a.oplogFilter(*after), opts)
+	if err != nil {
+		return after, fmt.Errorf("error tailing the oplog: %w", err)
+	}
+	// ctx is already cancelled on shutdown, use a fresh one to close the cursor.
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		cursor.Close(closeCtx)
+	}()
+
+	reader := &oplogReader{cursor: cursor, database: database, dbName: a.database, watches: a.watchesNamespace, last: after}
+	a.processChanges(ctx, reader)
+	if ctx.Err() != nil {
+		return reader.last, nil
+	}
+	if reader.err != nil {
+		return reader.last, fmt.Errorf("error reading the oplog: %w", reader.err)
+	}
+	if err := cursor.Err(); err != nil {
+		return reader.last, fmt.Errorf("error tailing the oplog: %w", err)
+	}
+	return reader.last, nil
+}
+
+// oplogFilter returns the filter of the oplog entries after the given time that insert, update or
+// delete documents of the watched collections, or apply the operations of a transaction touching
+// them.
+func (a *mongoDbAdapter) oplogFilter(after primitive.Timestamp) bson.D {
+	var ns interface{}
+	switch {
+	case a.collection != "":
+		ns = a.database + "." + a.collection
+	case a.partitionCollections != nil:
+		namespaces := make(bson.A, 0, len(a.partitionCollections))
+		for _, collection := range a.partitionCollections {
+			namespaces = append(namespaces, a.database+"."+collection)
+		}
+		ns = bson.D{{Key: "$in", Value: namespaces}}
+	default:
+		ns = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(a.database) + `\.(?!system\.)`}
+	}
+	return bson.D{
+		{Key: "ts", Value: bson.D{{Key: "$gt", Value: after}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{
+				{Key: "op", Value: bson.D{{Key: "$in", Value: bson.A{"i", "u", "d"}}}},
+				{Key: "ns", Value: ns},
+			},
+			// Since 4.0, the writes of a multi-document transaction are logged in one applyOps
+			// command.
+			bson.D{
+				{Key: "op", Value: "c"},
+				{Key: "o.applyOps.ns", Value: ns},
+			},
+		}},
+	}
+}
+
+// watchesNamespace returns whether the changes of the namespace ns, "database.collection", are
+// sent.
+func (a *mongoDbAdapter) watchesNamespace(ns string) bool {
+	if !strings.HasPrefix(ns, a.database+".") {
+		return false
+	}
+	collection := strings.TrimPrefix(ns, a.database+".")
+	switch {
+	case a.collection != "":
+		return collection == a.collection
+	case a.partitionCollections != nil:
+		for _, c := range a.partitionCollections {
+			if c == collection {
+				return true
+			}
+		}
+		return false
+	default:
+		return !strings.HasPrefix(collection, "system.")
+	}
+}
+
+// lastOplogTime returns the time of the last entry of the oplog, which is zero if it is empty.
+func lastOplogTime(ctx context.Context, oplog mongoclient.Collection) (*primitive.Timestamp, error) {
+	return oplogTime(ctx, oplog, -1)
+}
+
+// oplogTime returns the time of the first entry of the oplog in the given natural order, 1 for the
+// oldest or -1 for the last, which is zero if it is empty.
+func oplogTime(ctx context.Context, oplog mongoclient.Collection, order int) (*primitive.Timestamp, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "$natural", Value: order}}).SetLimit(1)
+	cursor, err := oplog.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	ts := &primitive.Timestamp{}
+	if cursor.Next(ctx) {
+		var entry bson.M
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+		if *ts, err = entryTime(entry); err != nil {
+			return nil, err
+		}
+	}
+	return ts, cursor.Err()
+}
+
+// oplogReader reads the changes of the oplog entries of a tailable cursor.
+type oplogReader struct {
+	cursor mongoclient.Cursor
+	// database is the watched database, where the updated documents are looked up.
+	database mongoclient.Database
+	dbName   string
+	// watches returns whether the changes of a namespace are sent.
+	watches func(ns string) bool
+	current bson.M
+	// pending are the changes of the last entry read that were not returned yet.
+	pending []bson.M
+	// last is the time of the last entry read, and previous the time of the entry before it.
+	last     *primitive.Timestamp
+	previous *primitive.Timestamp
+	err      error
+}
+
+// Next implements changeReader.Next.
+func (r *oplogReader) Next(ctx context.Context) bool {
+	for r.err == nil {
+		if len(r.pending) > 0 {
+			r.current, r.pending = r.pending[0], r.pending[1:]
+			return true
+		}
+		if !r.cursor.Next(ctx) {
+			return false
+		}
+		var entry bson.M
+		if r.err = r.cursor.Decode(&entry); r.err != nil {
+			return false
+		}
+		r.pending, r.err = r.changes(ctx, entry)
+	}
+	return false
+}
+
+// Decode implements changeReader.Decode.
+func (r *oplogReader) Decode(val interface{}) error {
+	data, ok := val.(*bson.M)
+	if !ok {
+		return fmt.Errorf("unsupported oplog change type %T", val)
+	}
+	*data = r.current
+	return nil
+}
+
+// position implements changeReader.position.
+func (r *oplogReader) position(data bson.M) position {
+	if len(r.pending) > 0 {
+		// The entry is only checkpointed once all its changes are sent, so that the rest of a
+		// transaction is not skipped when resuming.
+		return position{operationTime: r.previous}
+	}
+	clusterTime, _ := data["clusterTime"].(primitive.Timestamp)
+	return position{operationTime: &clusterTime}
+}
+
+// changes translates an oplog entry into the changes it sends, formatted like the change stream
+// ones. An applyOps entry sends a change for each of its operations on the watched collections.
+func (r *oplogReader) changes(ctx context.Context, entry bson.M) ([]bson.M, error) {
+	ts, err := entryTime(entry)
+	if err != nil {
+		return nil, err
+	}
+	r.previous, r.last = r.last, &ts
+	if op, _ := entry["op"].(string); op != "c" {
+		// The time of an oplog entry is unique.
+		change, err := r.change(ctx, entry, ts, fmt.Sprintf("oplog-%d.%d", ts.T, ts.I))
+		if err != nil || change == nil {
+			return nil, err
+		}
+		return []bson.M{change}, nil
+	}
+
+	o, _ := entry["o"].(bson.M)
+	ops, _ := o["applyOps"].(bson.A)
+	var changes []bson.M
+	for i, op := range ops {
+		opEntry, _ := op.(bson.M)
+		change, err := r.change(ctx, opEntry, ts, fmt.Sprintf("oplog-%d.%d.%d", ts.T, ts.I, i))
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// change translates an insert, update or delete oplog entry, or an operation of an applyOps
+// entry, logged at ts into a change identified by id, or nil if it does not send a change.
+func (r *oplogReader) change(ctx context.Context, entry bson.M, ts primitive.Timestamp, id string) (bson.M, error) {
+	op, _ := entry["op"].(string)
+	ns, _ := entry["ns"].(string)
+	o, _ := entry["o"].(bson.M)
+	if o == nil || !r.watches(ns) {
+		return nil, nil
+	}
+	collection := strings.TrimPrefix(ns, r.dbName+".")
+
+	data := bson.M{
+		"_id": bson.M{
+			"_data": id,
+		},
+		"ns": bson.M{
+			"db":   r.dbName,
+			"coll": collection,
+		},
+		"clusterTime": ts,
+	}
+	switch op {
+	case "i":
+		data["operationType"] = "insert"
+		data["documentKey"] = bson.M{"_id": o["_id"]}
+		data["fullDocument"] = o
+	case "d":
+		data["operationType"] = "delete"
+		data["documentKey"] = o
+	case "u":
+		o2, _ := entry["o2"].(bson.M)
+		if o2 == nil {
+			return nil, fmt.Errorf("oplog update at %d.%d has no o2 field", ts.T, ts.I)
+		}
+		doc := o
+		if isUpdateDocument(o) {
+			// The entry only holds the modifications, send the current version of the document.
+			var err error
+			if doc, err = r.lookup(ctx, collection, o2["_id"]); err != nil || doc == nil {
+				return nil, err
+			}
+		}
+		data["operationType"] = "replace"
+		data["documentKey"] = bson.M{"_id": o2["_id"]}
+		data["fullDocument"] = doc
+	default:
+		return nil, nil
+	}
+	return data, nil
+}
+
+// lookup returns the current version of a document of collection, or nil if it was deleted since.
+func (r *oplogReader) lookup(ctx context.Context, collection string, id interface{}) (bson.M, error) {
+	cursor, err := r.database.Collection(collection).Find(ctx, bson.D{{Key: "_id", Value: id}}, options.Find().SetLimit(1))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if !cursor.Next(ctx) {
+		return nil, cursor.Err()
+	}
+	var doc bson.M
+	if err := cursor.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// isUpdateDocument returns whether the o field of an oplog update holds update operators rather
+// than the replacement document.
+func isUpdateDocument(o bson.M) bool {
+	for key := range o {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// entryTime returns the time of an oplog entry.
+func entryTime(entry bson.M) (primitive.Timestamp, error) {
+	ts, ok := entry["ts"].(primitive.Timestamp)
+	if !ok {
+		return primitive.Timestamp{}, fmt.Errorf("oplog entry has no ts field: %v", entry)
+	}
+	return ts, nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	testcloudclient "knative.dev/eventing/pkg/adapter/v2/test"
+	"knative.dev/pkg/logging"
+)
+
+func TestTailOplogOnce(t *testing.T) {
+	ctx := context.Background()
+	client, err := mongotesting.TestClientCreator(mongotesting.TestClientData{
+		DbData: mongotesting.TestDbData{Documents: map[string][]bson.M{
+			oplogCollection: {
+				{"ts": primitive.Timestamp{T: 1, I: 1}, "op": "i", "ns": "db.coll", "o": bson.M{"_id": "z"}},
+				{"ts": primitive.Timestamp{T: 2, I: 1}, "op": "i", "ns": "db.coll", "o": bson.M{"_id": "a", "x": int32(1)}},
+				{"ts": primitive.Timestamp{T: 3, I: 1}, "op": "u", "ns": "db.coll", "o": bson.M{"$set": bson.M{"x": int32(2)}}, "o2": bson.M{"_id": "a"}},
+				{"ts": primitive.Timestamp{T: 4, I: 1}, "op": "i", "ns": "other.coll", "o": bson.M{"_id": "c"}},
+				{"ts": primitive.Timestamp{T: 5, I: 1}, "op": "u", "ns": "db.coll", "o": bson.M{"_id": "b", "y": int32(1)}, "o2": bson.M{"_id": "b"}},
+				{"ts": primitive.Timestamp{T: 6, I: 1}, "op": "d", "ns": "db.coll", "o": bson.M{"_id": "a"}},
+				// The writes of a transaction.
+				{"ts": primitive.Timestamp{T: 7, I: 1}, "op": "c", "ns": "admin.$cmd", "o": bson.M{"applyOps": bson.A{
+					bson.M{"op": "i", "ns": "db.coll", "o": bson.M{"_id": "d"}},
+					bson.M{"op": "i", "ns": "db.other", "o": bson.M{"_id": "e"}},
+					bson.M{"op": "d", "ns": "db.coll", "o": bson.M{"_id": "d"}},
+				}}},
+			},
+			// The current version of the updated document.
+			coll: {{"_id": "a", "x": int32(2)}},
+		}},
+	})()
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	oplog := client.Database(oplogDatabase).Collection(oplogCollection)
+
+	ce := testcloudclient.NewTestClient()
+	store := &testStore{}
+	a := mongoDbAdapter{
+		namespace:           "namespace",
+		ceSourcePrefix:      "CEPrefix",
+		database:            db,
+		collection:          coll,
+		ceClient:            ce,
+		checkpointer:        newCheckpointer(store),
+		shutdownGracePeriod: time.Minute,
+		logger:              logging.FromContext(ctx),
+	}
+
+	if ts, err := lastOplogTime(ctx, oplog); err != nil || *ts != (primitive.Timestamp{T: 7, I: 1}) {
+		t.Errorf("lastOplogTime = %v, %v, want {7 1}", ts, err)
+	}
+
+	last, err := a.tailOplogOnce(ctx, oplog, client.Database(db), &primitive.Timestamp{T: 1, I: 1})
+	if err != nil {
+		t.Fatalf("tailOplogOnce got error %v", err)
+	}
+	if want := (primitive.Timestamp{T: 7, I: 1}); *last != want {
+		t.Errorf("Expected last entry at %v, got %v", want, *last)
+	}
+
+	var got []string
+	for _, event := range ce.Sent() {
+		got = append(got, event.Type()+" "+string(event.Data()))
+	}
+	want := []string{
+		v1alpha1.MongoDbSourceInsertedEventType + ` {"_id":"a","x":1}`,
+		v1alpha1.MongoDbSourceUpdatedEventType + ` {"_id":"a","x":2}`,
+		v1alpha1.MongoDbSourceUpdatedEventType + ` {"_id":"b","y":1}`,
+		v1alpha1.MongoDbSourceDeletedEventType + ` {"_id":"a"}`,
+		v1alpha1.MongoDbSourceInsertedEventType + ` {"_id":"d"}`,
+		v1alpha1.MongoDbSourceDeletedEventType + ` {"_id":"d"}`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected events (-want +got) %s", diff)
+	}
+
+	if err := a.checkpointer.save(ctx); err != nil {
+		t.Fatalf("save got error %v", err)
+	}
+	if got := store.checkpoint.OperationTime; got == nil || *got != (primitive.Timestamp{T: 7, I: 1}) {
+		t.Errorf("Expected checkpoint at {7 1}, got %v", got)
+	}
+}
+
+func TestOplogReaderTransactionPosition(t *testing.T) {
+	before := primitive.Timestamp{T: 1, I: 1}
+	r := &oplogReader{
+		dbName:  db,
+		watches: func(ns string) bool { return ns == "db.coll" },
+		last:    &before,
+	}
+	entry := bson.M{"ts": primitive.Timestamp{T: 2, I: 1}, "op": "c", "ns": "admin.$cmd", "o": bson.M{"applyOps": bson.A{
+		bson.M{"op": "i", "ns": "db.coll", "o": bson.M{"_id": "a"}},
+		bson.M{"op": "i", "ns": "db.coll", "o": bson.M{"_id": "b"}},
+	}}}
+	changes, err := r.changes(context.Background(), entry)
+	if err != nil {
+		t.Fatalf("changes got error %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+
+	// Until its last change is sent, the transaction is resumed from its start.
+	r.current, r.pending = changes[0], changes[1:]
+	if got := r.position(r.current).operationTime; got == nil || *got != before {
+		t.Errorf("Expected the first change at %v, got %v", before, got)
+	}
+	r.current, r.pending = changes[1], nil
+	if got, want := r.position(r.current).operationTime, (primitive.Timestamp{T: 2, I: 1}); got == nil || *got != want {
+		t.Errorf("Expected the last change at %v, got %v", want, got)
+	}
+	if changes[0]["_id"].(bson.M)["_data"] == changes[1]["_id"].(bson.M)["_data"] {
+		t.Errorf("Expected distinct ids for the changes of a transaction, got %v", changes[0]["_id"])
+	}
+}
+
+func TestCheckOplogCovers(t *testing.T) {
+	entries := []bson.M{
+		{"ts": primitive.Timestamp{T: 3, I: 1}, "op": "i", "ns": "db.coll", "o": bson.M{"_id": "a"}},
+		{"ts": primitive.Timestamp{T: 5, I: 1}, "op": "i", "ns": "db.coll", "o": bson.M{"_id": "b"}},
+	}
+	tests := []struct {
+		name    string
+		entries []bson.M
+		after   primitive.Timestamp
+		want    bool
+	}{
+		{name: "rolled over", entries: entries, after: primitive.Timestamp{T: 2, I: 1}, want: true},
+		{name: "first entry", entries: entries, after: primitive.Timestamp{T: 3, I: 1}},
+		{name: "later entry", entries: entries, after: primitive.Timestamp{T: 5, I: 1}},
+		{name: "empty oplog", after: primitive.Timestamp{T: 2, I: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			client, err := mongotesting.TestClientCreator(mongotesting.TestClientData{
+				DbData: mongotesting.TestDbData{Documents: map[string][]bson.M{oplogCollection: test.entries}},
+			})()
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			err = checkOplogCovers(ctx, client.Database(oplogDatabase).Collection(oplogCollection), test.after)
+			if got := isHistoryLost(err); got != test.want {
+				t.Errorf("checkOplogCovers got error %v, want history lost %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRecoverOplogHistoryLost(t *testing.T) {
+	ctx := context.Background()
+	now := primitive.Timestamp{T: 1596326400, I: 2}
+	client, err := mongotesting.TestClientCreator(mongotesting.TestClientData{
+		DbData: mongotesting.TestDbData{RunCommandResult: bson.M{"operationTime": now}},
+	})()
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ce := testcloudclient.NewTestClient()
+	store := &testStore{}
+	a := mongoDbAdapter{
+		ceSourcePrefix: "CEPrefix",
+		database:       db,
+		collection:     coll,
+		ceClient:       ce,
+		checkpointer:   newCheckpointer(store),
+		onHistoryLost:  v1alpha1.HistoryLostRestart,
+		logger:         logging.FromContext(ctx),
+	}
+
+	lost := &oplogHistoryLostError{after: primitive.Timestamp{T: 2, I: 1}, first: primitive.Timestamp{T: 3, I: 1}}
+	after, err := a.recoverOplogHistoryLost(ctx, client.Database(db), lost)
+	if err != nil {
+		t.Fatalf("recoverOplogHistoryLost got error %v", err)
+	}
+	if after == nil || *after != now {
+		t.Errorf("Expected the oplog to be tailed from %v, got %v", now, after)
+	}
+	if got := len(ce.Sent()); got != 1 || ce.Sent()[0].Type() != v1alpha1.MongoDbSourceGapEventType {
+		t.Errorf("Expected a gap event, got %v", ce.Sent())
+	}
+	if store.checkpoint == nil || store.checkpoint.HistoryLost == nil {
+		t.Errorf("Expected the checkpoint to record the history loss, got %+v", store.checkpoint)
+	}
+}
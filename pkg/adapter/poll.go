@@ -0,0 +1,422 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultPollBatchSize is the number of documents read from a collection at once when the batch
+// size of the connection is not set.
+const defaultPollBatchSize = 100
+
+// poll queries the watched collections every pollInterval for the documents whose poll field
+// increased, until ctx is done. It resumes from the high-water marks of the checkpoint, or only
+// sends the documents written from now on if there is none.
+func (a *mongoDbAdapter) poll(ctx context.Context, database mongoclient.Database) error {
+	pos, err := a.checkpointer.load(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading checkpoint: %w", err)
+	}
+
+	// Periodically persist the position of the adapter while processing, and once done.
+	defer a.startCheckpoints(ctx)()
+
+	marks := pos.poll
+	if marks == nil {
+		if marks, err = a.currentMarks(ctx, database); err != nil {
+			return fmt.Errorf("error getting the high-water marks: %w", err)
+		}
+		a.checkpointer.acknowledge(position{poll: copyMarks(marks)})
+	}
+	state := &pollState{marks: marks, keys: make(map[string]map[string]bool)}
+
+	for {
+		if err := a.pollOnce(ctx, database, state); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(a.pollInterval):
+		}
+	}
+}
+
+// pollOnce polls the watched collections once and sends the changes found.
+func (a *mongoDbAdapter) pollOnce(ctx context.Context, database mongoclient.Database, state *pollState) error {
+	collections, err := a.watchedCollections(ctx, database)
+	if err != nil {
+		return fmt.Errorf("error listing the polled collections: %w", err)
+	}
+	batchSize := int32(defaultPollBatchSize)
+	if size := mongoclient.BatchSize(a.connection); size != nil {
+		batchSize = *size
+	}
+	reader := &pollReader{
+		database:      database,
+		dbName:        a.database,
+		field:         a.pollField,
+		detectDeletes: a.pollDetectDeletes,
+		batchSize:     batchSize,
+		state:         state,
+		collections:   collections,
+		time:          time.Now(),
+	}
+	a.processChanges(ctx, reader)
+	if reader.err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error polling: %w", reader.err)
+	}
+	return nil
+}
+
+// currentMarks returns the current high-water marks of the watched collections.
+func (a *mongoDbAdapter) currentMarks(ctx context.Context, database mongoclient.Database) (map[string]string, error) {
+	collections, err := a.watchedCollections(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	marks := make(map[string]string, len(collections))
+	for _, collection := range collections {
+		opts := options.Find().SetSort(markSort(a.pollField, -1)).SetLimit(1)
+		cursor, err := database.Collection(collection).Find(ctx, bson.D{}, opts)
+		if err != nil {
+			return nil, err
+		}
+		marks[collection] = ""
+		if cursor.Next(ctx) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				cursor.Close(ctx)
+				return nil, err
+			}
+			if value, ok := doc[a.pollField]; ok {
+				if marks[collection], err = encodeMark(a.pollField, value, doc["_id"]); err != nil {
+					cursor.Close(ctx)
+					return nil, err
+				}
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return marks, nil
+}
+
+// pollState is what is known of the polled collections between polls.
+type pollState struct {
+	// marks are the high-water marks of the documents read from each collection.
+	marks map[string]string
+	// keys are the _id of the documents of each collection, as canonical extended JSON. They are
+	// only tracked to detect deletions, which marks the keys still found in the collection, and
+	// then also tell insertions from updates. They are not checkpointed.
+	keys map[string]map[string]bool
+}
+
+// pollReader reads the changes found by one poll of the collections.
+type pollReader struct {
+	database      mongoclient.Database
+	dbName        string
+	field         string
+	detectDeletes bool
+	// batchSize is the number of documents read from a collection at once.
+	batchSize int32
+	state     *pollState
+	// collections are the collections left to poll, starting with the one being polled.
+	collections []string
+	// time is when the poll started.
+	time time.Time
+	// pending are the changes found in the last batch but not read yet.
+	pending []pollChange
+	current pollChange
+	err     error
+}
+
+// pollChange is a change found by a poll along with its position.
+type pollChange struct {
+	data     bson.M
+	position position
+}
+
+// Next implements changeReader.Next.
+func (r *pollReader) Next(ctx context.Context) bool {
+	for len(r.pending) == 0 {
+		if r.err != nil || len(r.collections) == 0 || ctx.Err() != nil {
+			return false
+		}
+		var done bool
+		if done, r.err = r.pollBatch(ctx, r.collections[0]); done {
+			r.collections = r.collections[1:]
+		}
+	}
+	r.current, r.pending = r.pending[0], r.pending[1:]
+	return true
+}
+
+// Decode implements changeReader.Decode.
+func (r *pollReader) Decode(val interface{}) error {
+	data, ok := val.(*bson.M)
+	if !ok {
+		return fmt.Errorf("unsupported poll change type %T", val)
+	}
+	*data = r.current.data
+	return nil
+}
+
+// position implements changeReader.position.
+func (r *pollReader) position(bson.M) position {
+	return r.current.position
+}
+
+// tracksKeys returns whether the keys of the documents are tracked, which is only done to detect
+// deletions since they take memory for every document of the collection.
+func (r *pollReader) tracksKeys() bool {
+	return r.detectDeletes
+}
+
+// pollBatch queues the changes of the next batch of documents of collection after its high-water
+// mark, and returns whether the collection is done. The next batch starts from the new mark, so that
+// only one batch of changes is held in memory.
+func (r *pollReader) pollBatch(ctx context.Context, collection string) (bool, error) {
+	mark := r.state.marks[collection]
+	keys := r.state.keys[collection]
+	if keys == nil && r.tracksKeys() {
+		var err error
+		if keys, err = r.loadKeys(ctx, collection, mark); err != nil {
+			return false, err
+		}
+		r.state.keys[collection] = keys
+	}
+
+	filter, err := r.after(mark)
+	if err != nil {
+		return false, err
+	}
+	opts := options.Find().SetSort(markSort(r.field, 1)).SetLimit(int64(r.batchSize)).SetBatchSize(r.batchSize)
+	cursor, err := r.database.Collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return false, err
+	}
+	defer cursor.Close(ctx)
+	var read int32
+	for cursor.Next(ctx) {
+		read++
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return false, err
+		}
+		value, ok := doc[r.field]
+		if !ok {
+			continue
+		}
+		id, err := documentID(doc["_id"])
+		if err != nil {
+			return false, err
+		}
+		if mark, err = encodeMark(r.field, value, doc["_id"]); err != nil {
+			return false, err
+		}
+		r.state.marks[collection] = mark
+
+		operationType := "insert"
+		switch {
+		case keys != nil:
+			if _, found := keys[id]; found {
+				// A document polled again carries its full new version, like a replacement.
+				operationType = "replace"
+			}
+			keys[id] = true
+		case r.field != "_id":
+			// Without the keys, a document polled on another field may have been seen before.
+			operationType = "replace"
+		}
+		r.queue(collection, operationType, id, mark, doc)
+	}
+	if err := cursor.Err(); err != nil {
+		return false, err
+	}
+	// A full batch that moved the mark may be followed by more documents.
+	if read == r.batchSize && len(r.pending) > 0 {
+		return false, nil
+	}
+
+	if r.detectDeletes {
+		return true, r.pollDeletes(ctx, collection, keys)
+	}
+	return true, nil
+}
+
+// pollDeletes queues the deletions of the documents of collection whose keys are no longer found.
+func (r *pollReader) pollDeletes(ctx context.Context, collection string, keys map[string]bool) error {
+	for id := range keys {
+		keys[id] = false
+	}
+	err := r.scanKeys(ctx, collection, bson.D{}, func(id string) {
+		if _, ok := keys[id]; ok {
+			keys[id] = true
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for id, found := range keys {
+		if found {
+			continue
+		}
+		var key bson.M
+		if err := bson.UnmarshalExtJSON([]byte(id), true, &key); err != nil {
+			return fmt.Errorf("invalid document key %q: %w", id, err)
+		}
+		delete(keys, id)
+		r.queue(collection, "delete", id, fmt.Sprint(r.time.UnixNano()), key)
+	}
+	return nil
+}
+
+// loadKeys returns the keys of the documents of collection up to the value of the given mark, or
+// of all the documents if the mark is empty.
+func (r *pollReader) loadKeys(ctx context.Context, collection, mark string) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	filter := bson.D{}
+	if mark != "" {
+		value, _, err := decodeMark(mark)
+		if err != nil {
+			return nil, err
+		}
+		filter = bson.D{{Key: r.field, Value: bson.D{{Key: "$lte", Value: value}}}}
+	}
+	err := r.scanKeys(ctx, collection, filter, func(id string) {
+		keys[id] = true
+	})
+	return keys, err
+}
+
+// scanKeys calls f with the key of each document of collection matching filter.
+func (r *pollReader) scanKeys(ctx context.Context, collection string, filter bson.D, f func(id string)) error {
+	opts := options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(r.batchSize)
+	cursor, err := r.database.Collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		id, err := documentID(doc["_id"])
+		if err != nil {
+			return err
+		}
+		f(id)
+	}
+	return cursor.Err()
+}
+
+// after returns the filter of the documents after the mark, or of the documents with the poll
+// field if the mark is empty. The documents sharing the value of the mark are told apart by their
+// _id, so that the ones written after a poll are not skipped.
+func (r *pollReader) after(mark string) (bson.D, error) {
+	if mark == "" {
+		return bson.D{{Key: r.field, Value: bson.D{{Key: "$exists", Value: true}}}}, nil
+	}
+	value, id, err := decodeMark(mark)
+	if err != nil {
+		return nil, err
+	}
+	if id.Type == 0 {
+		// The _id poll field, or a mark saved before the _id was recorded.
+		return bson.D{{Key: r.field, Value: bson.D{{Key: "$gt", Value: value}}}}, nil
+	}
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: r.field, Value: bson.D{{Key: "$gt", Value: value}}}},
+		bson.D{{Key: r.field, Value: value}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: id}}}},
+	}}}, nil
+}
+
+// queue queues a change of collection, identified by the key of the document and the given
+// version of the change. Its position includes the current high-water marks.
+func (r *pollReader) queue(collection, operationType, id, version string, doc bson.M) {
+	data := bson.M{
+		// Polling the same version of a document again gives the same id.
+		"_id": bson.M{
+			"_data": fmt.Sprintf("poll-%s.%s-%s-%s-%s", r.dbName, collection, operationType, id, version),
+		},
+		"operationType": operationType,
+		"ns": bson.M{
+			"db":   r.dbName,
+			"coll": collection,
+		},
+	}
+	if operationType == "delete" {
+		data["documentKey"] = doc
+	} else {
+		data["documentKey"] = bson.M{"_id": doc["_id"]}
+		data["fullDocument"] = doc
+	}
+	r.pending = append(r.pending, pollChange{data: data, position: position{poll: copyMarks(r.state.marks)}})
+}
+
+// markSort returns the sort of the documents by their mark, in the given order.
+func markSort(field string, order int) bson.D {
+	if field == "_id" {
+		return bson.D{{Key: field, Value: order}}
+	}
+	return bson.D{{Key: field, Value: order}, {Key: "_id", Value: order}}
+}
+
+// encodeMark returns the high-water mark of a document with the given _id and value of the poll
+// field.
+func encodeMark(field string, value, id interface{}) (string, error) {
+	mark := bson.D{{Key: "v", Value: value}}
+	if field != "_id" {
+		mark = append(mark, bson.E{Key: "id", Value: id})
+	}
+	raw, err := bson.MarshalExtJSON(mark, true, false)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling high-water mark: %w", err)
+	}
+	return string(raw), nil
+}
+
+// decodeMark returns the value of the poll field and the _id of a high-water mark. The _id is
+// empty if the mark does not have one.
+func decodeMark(mark string) (bson.RawValue, bson.RawValue, error) {
+	var raw bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(mark), true, &raw); err != nil {
+		return bson.RawValue{}, bson.RawValue{}, fmt.Errorf("invalid high-water mark %q: %w", mark, err)
+	}
+	return raw.Lookup("v"), raw.Lookup("id"), nil
+}
+
+// copyMarks returns a copy of the high-water marks.
+func copyMarks(marks map[string]string) map[string]string {
+	copied := make(map[string]string, len(marks))
+	for collection, mark := range marks {
+		copied[collection] = mark
+	}
+	return copied
+}
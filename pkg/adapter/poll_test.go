@@ -0,0 +1,178 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/bson"
+	testcloudclient "knative.dev/eventing/pkg/adapter/v2/test"
+	"knative.dev/pkg/logging"
+)
+
+func TestPollOnce(t *testing.T) {
+	ctx := context.Background()
+	documents := map[string][]bson.M{
+		coll: {{"_id": "a", "updatedAt": int32(1)}, {"_id": "b", "updatedAt": int32(2)}},
+	}
+	client, err := mongotesting.TestClientCreator(mongotesting.TestClientData{
+		DbData: mongotesting.TestDbData{Documents: documents},
+	})()
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	database := client.Database(db)
+
+	ce := testcloudclient.NewTestClient()
+	store := &testStore{}
+	// Read a document at a time, to poll several batches.
+	batchSize := int32(1)
+	a := mongoDbAdapter{
+		namespace:           "namespace",
+		ceSourcePrefix:      "CEPrefix",
+		database:            db,
+		collection:          coll,
+		ceClient:            ce,
+		checkpointer:        newCheckpointer(store),
+		shutdownGracePeriod: time.Minute,
+		pollField:           "updatedAt",
+		pollDetectDeletes:   true,
+		connection:          &v1alpha1.MongoDbSourceConnection{BatchSize: &batchSize},
+		logger:              logging.FromContext(ctx),
+	}
+	mark := func(v int32, id string) string {
+		m, err := encodeMark("updatedAt", v, id)
+		if err != nil {
+			t.Fatalf("encodeMark got error %v", err)
+		}
+		return m
+	}
+	state := &pollState{marks: map[string]string{coll: mark(1, "a")}, keys: make(map[string]map[string]bool)}
+
+	tests := []struct {
+		name      string
+		documents []bson.M
+		want      []string
+		wantMark  string
+	}{
+		{
+			name:      "insertion",
+			documents: documents[coll],
+			want:      []string{v1alpha1.MongoDbSourceInsertedEventType + ` {"_id":"b","updatedAt":2}`},
+			wantMark:  mark(2, "b"),
+		},
+		{
+			name:      "update",
+			documents: []bson.M{{"_id": "b", "updatedAt": int32(2)}, {"_id": "a", "updatedAt": int32(3)}},
+			want:      []string{v1alpha1.MongoDbSourceUpdatedEventType + ` {"_id":"a","updatedAt":3}`},
+			wantMark:  mark(3, "a"),
+		},
+		{
+			name: "insertions sharing the value of the mark, in several batches",
+			documents: []bson.M{
+				{"_id": "b", "updatedAt": int32(2)},
+				{"_id": "a", "updatedAt": int32(3)},
+				{"_id": "c", "updatedAt": int32(3)},
+				{"_id": "d", "updatedAt": int32(4)},
+			},
+			want: []string{
+				v1alpha1.MongoDbSourceInsertedEventType + ` {"_id":"c","updatedAt":3}`,
+				v1alpha1.MongoDbSourceInsertedEventType + ` {"_id":"d","updatedAt":4}`,
+			},
+			wantMark: mark(4, "d"),
+		},
+		{
+			name:      "deletion",
+			documents: []bson.M{{"_id": "a", "updatedAt": int32(3)}, {"_id": "c", "updatedAt": int32(3)}, {"_id": "d", "updatedAt": int32(4)}},
+			want:      []string{v1alpha1.MongoDbSourceDeletedEventType + ` {"_id":"b"}`},
+			wantMark:  mark(4, "d"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			documents[coll] = test.documents
+			ce.Reset()
+			if err := a.pollOnce(ctx, database, state); err != nil {
+				t.Fatalf("pollOnce got error %v", err)
+			}
+			if err := a.checkpointer.save(ctx); err != nil {
+				t.Fatalf("save got error %v", err)
+			}
+
+			var got []string
+			for _, event := range ce.Sent() {
+				got = append(got, event.Type()+" "+string(event.Data()))
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Unexpected events (-want +got) %s", diff)
+			}
+			if got := store.checkpoint.Poll[coll]; got != test.wantMark {
+				t.Errorf("Expected high-water mark %s, got %s", test.wantMark, got)
+			}
+		})
+	}
+}
+
+func TestPollOnceWithoutDeletes(t *testing.T) {
+	ctx := context.Background()
+	client, err := mongotesting.TestClientCreator(mongotesting.TestClientData{
+		DbData: mongotesting.TestDbData{Documents: map[string][]bson.M{
+			coll: {{"_id": "a", "updatedAt": int32(1)}, {"_id": "b", "updatedAt": int32(2)}},
+		}},
+	})()
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ce := testcloudclient.NewTestClient()
+	a := mongoDbAdapter{
+		namespace:           "namespace",
+		ceSourcePrefix:      "CEPrefix",
+		database:            db,
+		collection:          coll,
+		ceClient:            ce,
+		checkpointer:        newCheckpointer(&testStore{}),
+		shutdownGracePeriod: time.Minute,
+		pollField:           "updatedAt",
+		logger:              logging.FromContext(ctx),
+	}
+	mark, err := encodeMark("updatedAt", int32(1), "a")
+	if err != nil {
+		t.Fatalf("encodeMark got error %v", err)
+	}
+	state := &pollState{marks: map[string]string{coll: mark}, keys: make(map[string]map[string]bool)}
+	if err := a.pollOnce(ctx, client.Database(db), state); err != nil {
+		t.Fatalf("pollOnce got error %v", err)
+	}
+
+	var got []string
+	for _, event := range ce.Sent() {
+		got = append(got, event.Type()+" "+string(event.Data()))
+	}
+	want := []string{v1alpha1.MongoDbSourceUpdatedEventType + ` {"_id":"b","updatedAt":2}`}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected events (-want +got) %s", diff)
+	}
+	if len(state.keys) != 0 {
+		t.Errorf("Expected no keys to be tracked without detectDeletes, got %v", state.keys)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// startsSnapshot returns whether a new snapshot must be sent from the given position.
+func (a *mongoDbAdapter) startsSnapshot(p position) bool {
+	switch a.snapshotMode {
+	case v1alpha1.SnapshotAlways:
+		return p.snapshot == nil
+	case v1alpha1.SnapshotInitial:
+		return p.isZero()
+	}
+	return false
+}
+
+// clusterTime returns the operation time of the cluster, from which the changes made after the
+// start of a snapshot are streamed.
+func clusterTime(ctx context.Context, database mongoclient.Database) (primitive.Timestamp, error) {
+	res, err := database.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}})
+	if err != nil {
+		return primitive.Timestamp{}, err
+	}
+	t, i, ok := res.Lookup("operationTime").TimestampOK()
+	if !ok {
+		return primitive.Timestamp{}, errors.New("no operation time returned, snapshots require a replica set")
+	}
+	return primitive.Timestamp{T: t, I: i}, nil
+}
+
+// startSnapshot starts a new snapshot at the current cluster time, and returns its position once
+// checkpointed.
+func (a *mongoDbAdapter) startSnapshot(ctx context.Context, database mongoclient.Database) (position, error) {
+	operationTime, err := clusterTime(ctx, database)
+	if err != nil {
+		return position{}, fmt.Errorf("error getting the cluster time of the snapshot: %w", err)
+	}
+	a.logger.Desugar().Info("Starting snapshot", zap.Uint32("t", operationTime.T), zap.Uint32("i", operationTime.I))
+	// Record when the snapshot started so that the changes made since are not missed.
+	pos := position{snapshot: &checkpoint.Snapshot{OperationTime: operationTime}}
+	a.checkpointer.acknowledge(pos)
+	if err := a.checkpointer.save(ctx); err != nil {
+		return position{}, fmt.Errorf("error saving checkpoint: %w", err)
+	}
+	return pos, nil
+}
+
+// sendSnapshot sends the existing documents of the watched collections, from the given progress of
+// the snapshot, until they are all sent or ctx is done.
+func (a *mongoDbAdapter) sendSnapshot(ctx context.Context, database mongoclient.Database, progress checkpoint.Snapshot) error {
+	collections, err := a.watchedCollections(ctx, database)
+	if err != nil {
+		return fmt.Errorf("error listing the collections of the snapshot: %w", err)
+	}
+	// Skip the collections already sent.
+	collections = collections[sort.SearchStrings(collections, progress.Collection):]
+
+	reader := &snapshotReader{
+		database:    database,
+		dbName:      a.database,
+		progress:    progress,
+		collections: collections,
+		batchSize:   a.snapshotBatchSize,
+	}
+	defer reader.close(ctx)
+
+	a.processChanges(ctx, reader)
+
+	if err := reader.err; err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+	if ctx.Err() == nil {
+		a.logger.Desugar().Info("Snapshot sent")
+	}
+	return nil
+}
+
+// watchedCollections returns the sorted names of the watched collections.
+func (a *mongoDbAdapter) watchedCollections(ctx context.Context, database mongoclient.Database) ([]string, error) {
+	if a.collection != "" {
+		return []string{a.collection}, nil
+	}
+	var collections []string
+	if a.partitionCollections != nil {
+		collections = append(collections, a.partitionCollections...)
+	} else {
+		names, err := database.ListCollectionNames(ctx, bson.D{})
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !strings.HasPrefix(name, "system.") {
+				collections = append(collections, name)
+			}
+		}
+	}
+	sort.Strings(collections)
+	return collections, nil
+}
+
+// snapshotReader reads the existing documents of collections, in _id order, as snapshot changes.
+type snapshotReader struct {
+	database mongoclient.Database
+	dbName   string
+	// progress is where a previous snapshot stopped.
+	progress checkpoint.Snapshot
+	// collections are the collections left to read, starting with the one being read.
+	collections []string
+	batchSize   int32
+	cursor      mongoclient.Cursor
+	err         error
+}
+
+// Next implements changeReader.Next.
+func (r *snapshotReader) Next(ctx context.Context) bool {
+	for r.err == nil {
+		if r.cursor == nil {
+			if len(r.collections) == 0 {
+				return false
+			}
+			if r.err = r.open(ctx); r.err != nil {
+				return false
+			}
+		}
+		if r.cursor.Next(ctx) {
+			return true
+		}
+		if r.err = r.cursor.Err(); r.err != nil {
+			return false
+		}
+		r.close(ctx)
+		r.collections = r.collections[1:]
+	}
+	return false
+}
+
+// open opens the cursor over the documents of the current collection not sent yet.
+func (r *snapshotReader) open(ctx context.Context) error {
+	collection := r.collections[0]
+	filter := bson.D{}
+	if collection == r.progress.Collection && r.progress.LastID != "" {
+		var last bson.Raw
+		if err := bson.UnmarshalExtJSON([]byte(r.progress.LastID), true, &last); err != nil {
+			return fmt.Errorf("invalid snapshot last id %q: %w", r.progress.LastID, err)
+		}
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: last.Lookup("_id")}}}}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if r.batchSize > 0 {
+		opts.SetBatchSize(r.batchSize)
+	}
+	cursor, err := r.database.Collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	r.cursor = cursor
+	return nil
+}
+
+// close closes the cursor of the current collection, if any.
+func (r *snapshotReader) close(ctx context.Context) {
+	if r.cursor != nil {
+		r.cursor.Close(ctx)
+		r.cursor = nil
+	}
+}
+
+// Decode implements changeReader.Decode, the current document is decoded as a snapshot change.
+func (r *snapshotReader) Decode(val interface{}) error {
+	data, ok := val.(*bson.M)
+	if !ok {
+		return fmt.Errorf("unsupported snapshot change type %T", val)
+	}
+	var doc bson.M
+	if err := r.cursor.Decode(&doc); err != nil {
+		return err
+	}
+	lastID, err := documentID(doc["_id"])
+	if err != nil {
+		return err
+	}
+	collection := r.collections[0]
+	*data = bson.M{
+		// The snapshot of a document always gets the same id, so that duplicates can be detected.
+		"_id": bson.M{
+			"_data": fmt.Sprintf("snapshot-%d.%d-%s.%s-%s", r.progress.OperationTime.T, r.progress.OperationTime.I, r.dbName, collection, lastID),
+		},
+		"operationType": "snapshot",
+		"ns": bson.M{
+			"db":   r.dbName,
+			"coll": collection,
+		},
+		"documentKey":  bson.M{"_id": doc["_id"]},
+		"fullDocument": doc,
+	}
+	return nil
+}
+
+// position implements changeReader.position.
+func (r *snapshotReader) position(data bson.M) position {
+	ns, _ := data["ns"].(bson.M)
+	key, _ := data["documentKey"].(bson.M)
+	lastID, err := documentID(key["_id"])
+	if err != nil {
+		return position{}
+	}
+	collection, _ := ns["coll"].(string)
+	return position{snapshot: &checkpoint.Snapshot{
+		OperationTime: r.progress.OperationTime,
+		Collection:    collection,
+		LastID:        lastID,
+	}}
+}
+
+// documentID returns the canonical extended JSON of {"_id": id}.
+func documentID(id interface{}) (string, error) {
+	raw, err := bson.MarshalExtJSON(bson.D{{Key: "_id", Value: id}}, true, false)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling document id: %w", err)
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	testcloudclient "knative.dev/eventing/pkg/adapter/v2/test"
+	"knative.dev/pkg/logging"
+)
+
+func TestStartsSnapshot(t *testing.T) {
+	inProgress := position{snapshot: &checkpoint.Snapshot{}}
+	resumed := position{operationTime: &primitive.Timestamp{T: 1}}
+	tests := []struct {
+		name string
+		mode v1alpha1.SnapshotMode
+		pos  position
+		want bool
+	}{
+		{name: "never", mode: v1alpha1.SnapshotNever},
+		{name: "initial without checkpoint", mode: v1alpha1.SnapshotInitial, want: true},
+		{name: "initial with checkpoint", mode: v1alpha1.SnapshotInitial, pos: resumed},
+		{name: "always with checkpoint", mode: v1alpha1.SnapshotAlways, pos: resumed, want: true},
+		{name: "always with snapshot in progress", mode: v1alpha1.SnapshotAlways, pos: inProgress},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := mongoDbAdapter{snapshotMode: test.mode}
+			if got := a.startsSnapshot(test.pos); got != test.want {
+				t.Errorf("startsSnapshot got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSendSnapshot(t *testing.T) {
+	ctx := context.Background()
+	client, err := mongotesting.TestClientCreator(mongotesting.TestClientData{
+		DbData: mongotesting.TestDbData{
+			Collections: []string{"b", "a", "system.views"},
+			Documents: map[string][]bson.M{
+				"a": {{"_id": int32(1)}, {"_id": int32(2)}},
+				"b": {{"_id": int32(3)}},
+			},
+		},
+	})()
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ce := testcloudclient.NewTestClient()
+	store := &testStore{}
+	a := mongoDbAdapter{
+		namespace:           "namespace",
+		ceSourcePrefix:      "CEPrefix",
+		database:            db,
+		ceClient:            ce,
+		checkpointer:        newCheckpointer(store),
+		shutdownGracePeriod: time.Minute,
+		logger:              logging.FromContext(ctx),
+	}
+
+	// The snapshot was interrupted after sending the first document of a.
+	operationTime := primitive.Timestamp{T: 1597, I: 3}
+	progress := checkpoint.Snapshot{OperationTime: operationTime, Collection: "a", LastID: `{"_id":{"$numberInt":"1"}}`}
+	if err := a.sendSnapshot(ctx, client.Database(db), progress); err != nil {
+		t.Fatalf("sendSnapshot got error %v", err)
+	}
+	if err := a.checkpointer.save(ctx); err != nil {
+		t.Fatalf("save got error %v", err)
+	}
+
+	var got []string
+	for _, event := range ce.Sent() {
+		if event.Type() != v1alpha1.MongoDbSourceSnapshotEventType {
+			t.Errorf("Unexpected event type %q", event.Type())
+		}
+		got = append(got, string(event.Data()))
+	}
+	if diff := cmp.Diff([]string{`{"_id":2}`, `{"_id":3}`}, got); diff != "" {
+		t.Errorf("Unexpected snapshot events (-want +got) %s", diff)
+	}
+
+	want := &checkpoint.Checkpoint{Snapshot: &checkpoint.Snapshot{OperationTime: operationTime, Collection: "b", LastID: `{"_id":{"$numberInt":"3"}}`}}
+	if diff := cmp.Diff(want, store.checkpoint); diff != "" {
+		t.Errorf("Unexpected checkpoint (-want +got) %s", diff)
+	}
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 )
@@ -33,14 +36,34 @@ const (
 	// was able to successfully connect to the correct database or collection.
 	MongoDbConditionConnectionEstablished apis.ConditionType = "ConnectionEstablished"
 
+	// MongoDbConditionChangeStreamSupported has status True when the MongoDb server and user meet the
+	// prerequisites of the mode of the MongoDbSource: a server version supporting its change
+	// stream, and the privileges to read the changes.
+	MongoDbConditionChangeStreamSupported apis.ConditionType = "ChangeStreamSupported"
+
 	// MongoDbConditionDeployed has status True when the MongoDbSource has had it's deployment created.
 	MongoDbConditionDeployed apis.ConditionType = "Deployed"
+
+	// MongoDbConditionHistoryLost has status True when a receive adapter could not resume the change
+	// stream because the oplog no longer covered its checkpoint. It only affects readiness when the
+	// receive adapter fails because of it.
+	MongoDbConditionHistoryLost apis.ConditionType = "HistoryLost"
+
+	// MongoDbConditionNamespacePending has status True when the database or collection of a
+	// MongoDbSource not requiring an existing namespace does not exist yet. It does not affect
+	// readiness, the receive adapter watches the namespace until it is created.
+	MongoDbConditionNamespacePending apis.ConditionType = "NamespacePending"
+
+	// MongoDbConditionSuspended has status True when the MongoDbSource is suspended. Its receive
+	// adapters are stopped, so the source is not ready until it is resumed.
+	MongoDbConditionSuspended apis.ConditionType = "Suspended"
 )
 
 // MongoDbCondSet holds NewLivingConditionSet.
 var MongoDbCondSet = apis.NewLivingConditionSet(
 	MongoDbConditionSinkProvided,
 	MongoDbConditionConnectionEstablished,
+	MongoDbConditionChangeStreamSupported,
 	MongoDbConditionDeployed,
 )
 
@@ -84,9 +107,23 @@ func (m *MongoDbSourceStatus) MarkConnectionSuccess() {
 	MongoDbCondSet.Manage(m).MarkTrue(MongoDbConditionConnectionEstablished)
 }
 
-// MarkConnectionFailed sets the condition that the source has incorrect credentials or that the specified database or collection is not found.
-func (m *MongoDbSourceStatus) MarkConnectionFailed(err error) {
-	MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionConnectionEstablished, "Connection failed: incorrect credentials or database or collection not found", err.Error())
+// MarkConnectionFailed sets the condition that the source could not connect to the database, with
+// the reason of the failure: for instance incorrect credentials, an unreachable server, or a
+// database or collection not found.
+func (m *MongoDbSourceStatus) MarkConnectionFailed(reason, messageFormat string, messageA ...interface{}) {
+	MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionConnectionEstablished, reason, messageFormat, messageA...)
+}
+
+// MarkChangeStreamSupported sets the condition that the server and user meet the prerequisites of
+// the source.
+func (m *MongoDbSourceStatus) MarkChangeStreamSupported() {
+	MongoDbCondSet.Manage(m).MarkTrue(MongoDbConditionChangeStreamSupported)
+}
+
+// MarkChangeStreamNotSupported sets the condition that the server or user miss prerequisites of
+// the source.
+func (m *MongoDbSourceStatus) MarkChangeStreamNotSupported(reason, messageFormat string, messageA ...interface{}) {
+	MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionChangeStreamSupported, reason, messageFormat, messageA...)
 }
 
 // deploymentIsAvailable determines if the provided deployment is available. Note that if it cannot
@@ -101,16 +138,92 @@ func deploymentIsAvailable(d *appsv1.DeploymentStatus, def bool) bool {
 	return def
 }
 
-// PropagateDeploymentAvailability uses the availability of the provided Deployment to determine if
-// MongoDbConditionDeployed should be marked as true or false.
-func (m *MongoDbSourceStatus) PropagateDeploymentAvailability(d *appsv1.Deployment) {
-	if deploymentIsAvailable(&d.Status, false) {
-		MongoDbCondSet.Manage(m).MarkTrue(MongoDbConditionDeployed)
-	} else {
-		// I don't know how to propagate the status well, so just give the name of the Deployment
-		// for now.
-		MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionDeployed, "DeploymentUnavailable", "The Deployment '%s' is unavailable.", d.Name)
+// PropagateDeploymentAvailability uses the availability of the provided Deployments, one per
+// partition, to determine if MongoDbConditionDeployed should be marked as true or false.
+func (m *MongoDbSourceStatus) PropagateDeploymentAvailability(ds ...*appsv1.Deployment) {
+	for _, d := range ds {
+		if !deploymentIsAvailable(&d.Status, false) {
+			// I don't know how to propagate the status well, so just give the name of the Deployment
+			// for now.
+			MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionDeployed, "DeploymentUnavailable", "The Deployment '%s' is unavailable.", d.Name)
+			return
+		}
+	}
+	MongoDbCondSet.Manage(m).MarkTrue(MongoDbConditionDeployed)
+}
+
+// MarkNoDeployment sets the condition that the receive adapter of the source is not deployed.
+func (m *MongoDbSourceStatus) MarkNoDeployment(reason, messageFormat string, messageA ...interface{}) {
+	MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionDeployed, reason, messageFormat, messageA...)
+}
+
+// MarkHistoryLost sets the condition that a receive adapter lost the history of the change stream
+// and applied the given policy. The source is no longer deployed if the policy is to fail.
+func (m *MongoDbSourceStatus) MarkHistoryLost(policy HistoryLostPolicy, messageFormat string, messageA ...interface{}) {
+	reason := "HistoryLost"
+	switch policy {
+	case HistoryLostRestart:
+		reason = "RestartedFromNow"
+	case HistoryLostSnapshot:
+		reason = "Resnapshotted"
+	}
+	MongoDbCondSet.Manage(m).SetCondition(apis.Condition{
+		Type:     MongoDbConditionHistoryLost,
+		Status:   corev1.ConditionTrue,
+		Severity: apis.ConditionSeverityWarning,
+		Reason:   reason,
+		Message:  fmt.Sprintf(messageFormat, messageA...),
+	})
+	if policy != HistoryLostRestart && policy != HistoryLostSnapshot {
+		MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionDeployed, reason, messageFormat, messageA...)
+	}
+}
+
+// MarkHistoryRetained clears the condition that a receive adapter lost the history of the change
+// stream.
+func (m *MongoDbSourceStatus) MarkHistoryRetained() {
+	MongoDbCondSet.Manage(m).ClearCondition(MongoDbConditionHistoryLost)
+}
+
+// MarkNamespacePending sets the condition that the database or collection of the source does not
+// exist yet.
+func (m *MongoDbSourceStatus) MarkNamespacePending(reason, messageFormat string, messageA ...interface{}) {
+	MongoDbCondSet.Manage(m).SetCondition(apis.Condition{
+		Type:     MongoDbConditionNamespacePending,
+		Status:   corev1.ConditionTrue,
+		Severity: apis.ConditionSeverityInfo,
+		Reason:   reason,
+		Message:  fmt.Sprintf(messageFormat, messageA...),
+	})
+}
+
+// MarkNamespaceExists clears the condition that the database or collection of the source does not
+// exist yet.
+func (m *MongoDbSourceStatus) MarkNamespaceExists() {
+	MongoDbCondSet.Manage(m).ClearCondition(MongoDbConditionNamespacePending)
+}
+
+// MarkSuspended sets the condition that the source is suspended, and that its receive adapters
+// are stopped.
+func (m *MongoDbSourceStatus) MarkSuspended() {
+	MongoDbCondSet.Manage(m).SetCondition(apis.Condition{
+		Type:     MongoDbConditionSuspended,
+		Status:   corev1.ConditionTrue,
+		Severity: apis.ConditionSeverityInfo,
+		Reason:   "Suspended",
+		Message:  "The source is suspended",
+	})
+	MongoDbCondSet.Manage(m).MarkFalse(MongoDbConditionDeployed, "Suspended", "The receive adapters are stopped while the source is suspended")
+}
+
+// MarkResumed clears the condition that the source is suspended. The receive adapters are not
+// deployed until their availability is propagated again.
+func (m *MongoDbSourceStatus) MarkResumed() {
+	if cond := m.GetCondition(MongoDbConditionSuspended); cond == nil {
+		return
 	}
+	MongoDbCondSet.Manage(m).ClearCondition(MongoDbConditionSuspended)
+	MongoDbCondSet.Manage(m).MarkUnknown(MongoDbConditionDeployed, "Resuming", "The receive adapters are starting again")
 }
 
 // IsReady returns true if the resource is ready overall.
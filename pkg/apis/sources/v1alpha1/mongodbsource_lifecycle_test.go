@@ -17,13 +17,13 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 )
@@ -105,7 +105,7 @@ func TestMongoDbGetCondition(t *testing.T) {
 			m := &MongoDbSourceStatus{}
 			m.InitializeConditions()
 			m.MarkSink(apis.HTTP("example"))
-			m.MarkConnectionFailed(errors.New(""))
+			m.MarkConnectionFailed("ServerUnreachable", "")
 			m.PropagateDeploymentAvailability(availableDeployment)
 			return m
 		}(),
@@ -113,7 +113,24 @@ func TestMongoDbGetCondition(t *testing.T) {
 		want: &apis.Condition{
 			Type:   MongoDbConditionReady,
 			Status: corev1.ConditionFalse,
-			Reason: "Connection failed: incorrect credentials or database or collection not found",
+			Reason: "ServerUnreachable",
+		},
+	}, {
+		name: "mark sink, deployed, connection established and change stream not supported",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSink(apis.HTTP("example"))
+			m.MarkConnectionSuccess()
+			m.MarkChangeStreamNotSupported("PrerequisitesMissing", "")
+			m.PropagateDeploymentAvailability(availableDeployment)
+			return m
+		}(),
+		condQuery: MongoDbConditionReady,
+		want: &apis.Condition{
+			Type:   MongoDbConditionReady,
+			Status: corev1.ConditionFalse,
+			Reason: "PrerequisitesMissing",
 		},
 	}, {
 		name: "mark sink, deployed and connection established",
@@ -122,6 +139,141 @@ func TestMongoDbGetCondition(t *testing.T) {
 			m.InitializeConditions()
 			m.MarkSink(apis.HTTP("example"))
 			m.MarkConnectionSuccess()
+			m.MarkChangeStreamSupported()
+			m.PropagateDeploymentAvailability(availableDeployment)
+			return m
+		}(),
+		condQuery: MongoDbConditionReady,
+		want: &apis.Condition{
+			Type:   MongoDbConditionReady,
+			Status: corev1.ConditionTrue,
+		},
+	}, {
+		name: "mark sink, connection established and a partition unavailable",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSink(apis.HTTP("example"))
+			m.MarkConnectionSuccess()
+			m.MarkChangeStreamSupported()
+			m.PropagateDeploymentAvailability(availableDeployment, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "partition-1"}})
+			return m
+		}(),
+		condQuery: MongoDbConditionReady,
+		want: &apis.Condition{
+			Type:    MongoDbConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "DeploymentUnavailable",
+			Message: "The Deployment 'partition-1' is unavailable.",
+		},
+	}, {
+		name: "mark sink, deployed, connection established and history lost",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSink(apis.HTTP("example"))
+			m.MarkConnectionSuccess()
+			m.MarkChangeStreamSupported()
+			m.PropagateDeploymentAvailability(availableDeployment)
+			m.MarkHistoryLost(HistoryLostFail, "lost")
+			return m
+		}(),
+		condQuery: MongoDbConditionReady,
+		want: &apis.Condition{
+			Type:    MongoDbConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "HistoryLost",
+			Message: "lost",
+		},
+	}, {
+		name: "mark sink, deployed, connection established and history lost but restarted",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSink(apis.HTTP("example"))
+			m.MarkConnectionSuccess()
+			m.MarkChangeStreamSupported()
+			m.PropagateDeploymentAvailability(availableDeployment)
+			m.MarkHistoryLost(HistoryLostRestart, "lost")
+			return m
+		}(),
+		condQuery: MongoDbConditionReady,
+		want: &apis.Condition{
+			Type:   MongoDbConditionReady,
+			Status: corev1.ConditionTrue,
+		},
+	}, {
+		name: "mark sink, deployed, connection established and namespace pending",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSink(apis.HTTP("example"))
+			m.MarkConnectionSuccess()
+			m.MarkChangeStreamSupported()
+			m.MarkNamespacePending("CollectionNotFound", "pending")
+			m.PropagateDeploymentAvailability(availableDeployment)
+			return m
+		}(),
+		condQuery: MongoDbConditionReady,
+		want: &apis.Condition{
+			Type:   MongoDbConditionReady,
+			Status: corev1.ConditionTrue,
+		},
+	}, {
+		name: "mark namespace pending",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkNamespacePending("CollectionNotFound", "pending")
+			return m
+		}(),
+		condQuery: MongoDbConditionNamespacePending,
+		want: &apis.Condition{
+			Type:    MongoDbConditionNamespacePending,
+			Status:  corev1.ConditionTrue,
+			Reason:  "CollectionNotFound",
+			Message: "pending",
+		},
+	}, {
+		name: "mark namespace pending then existing",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkNamespacePending("CollectionNotFound", "pending")
+			m.MarkNamespaceExists()
+			return m
+		}(),
+		condQuery: MongoDbConditionNamespacePending,
+		want:      nil,
+	}, {
+		name: "mark sink, connection established and suspended",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSink(apis.HTTP("example"))
+			m.MarkConnectionSuccess()
+			m.MarkChangeStreamSupported()
+			m.PropagateDeploymentAvailability(availableDeployment)
+			m.MarkSuspended()
+			return m
+		}(),
+		condQuery: MongoDbConditionReady,
+		want: &apis.Condition{
+			Type:    MongoDbConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Suspended",
+			Message: "The receive adapters are stopped while the source is suspended",
+		},
+	}, {
+		name: "mark suspended then resumed",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSink(apis.HTTP("example"))
+			m.MarkConnectionSuccess()
+			m.MarkChangeStreamSupported()
+			m.MarkSuspended()
+			m.MarkResumed()
 			m.PropagateDeploymentAvailability(availableDeployment)
 			return m
 		}(),
@@ -130,6 +282,21 @@ func TestMongoDbGetCondition(t *testing.T) {
 			Type:   MongoDbConditionReady,
 			Status: corev1.ConditionTrue,
 		},
+	}, {
+		name: "mark suspended",
+		ms: func() *MongoDbSourceStatus {
+			m := &MongoDbSourceStatus{}
+			m.InitializeConditions()
+			m.MarkSuspended()
+			return m
+		}(),
+		condQuery: MongoDbConditionSuspended,
+		want: &apis.Condition{
+			Type:    MongoDbConditionSuspended,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Suspended",
+			Message: "The source is suspended",
+		},
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -155,6 +322,9 @@ func TestMongoDbInitializeConditions(t *testing.T) {
 			SourceStatus: duckv1.SourceStatus{
 				Status: duckv1.Status{
 					Conditions: []apis.Condition{{
+						Type:   MongoDbConditionChangeStreamSupported,
+						Status: corev1.ConditionUnknown,
+					}, {
 						Type:   MongoDbConditionConnectionEstablished,
 						Status: corev1.ConditionUnknown,
 					}, {
@@ -186,6 +356,9 @@ func TestMongoDbInitializeConditions(t *testing.T) {
 			SourceStatus: duckv1.SourceStatus{
 				Status: duckv1.Status{
 					Conditions: []apis.Condition{{
+						Type:   MongoDbConditionChangeStreamSupported,
+						Status: corev1.ConditionUnknown,
+					}, {
 						Type:   MongoDbConditionConnectionEstablished,
 						Status: corev1.ConditionUnknown,
 					}, {
@@ -217,6 +390,9 @@ func TestMongoDbInitializeConditions(t *testing.T) {
 			SourceStatus: duckv1.SourceStatus{
 				Status: duckv1.Status{
 					Conditions: []apis.Condition{{
+						Type:   MongoDbConditionChangeStreamSupported,
+						Status: corev1.ConditionUnknown,
+					}, {
 						Type:   MongoDbConditionConnectionEstablished,
 						Status: corev1.ConditionUnknown,
 					}, {
@@ -243,6 +419,9 @@ func TestMongoDbInitializeConditions(t *testing.T) {
 			SourceStatus: duckv1.SourceStatus{
 				Status: duckv1.Status{
 					Conditions: []apis.Condition{{
+						Type:   MongoDbConditionChangeStreamSupported,
+						Status: corev1.ConditionUnknown,
+					}, {
 						Type:   MongoDbConditionConnectionEstablished,
 						Status: corev1.ConditionUnknown,
 					}, {
@@ -270,6 +449,9 @@ func TestMongoDbInitializeConditions(t *testing.T) {
 			SourceStatus: duckv1.SourceStatus{
 				Status: duckv1.Status{
 					Conditions: []apis.Condition{{
+						Type:   MongoDbConditionChangeStreamSupported,
+						Status: corev1.ConditionUnknown,
+					}, {
 						Type:   MongoDbConditionConnectionEstablished,
 						Status: corev1.ConditionUnknown,
 					}, {
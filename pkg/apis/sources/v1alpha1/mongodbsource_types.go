@@ -17,6 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"bytes"
+	"errors"
+	"text/template"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -44,9 +48,10 @@ type MongoDbSource struct {
 
 // MongoDbSourceEventTypes are the different types of event the source produces.
 var MongoDbSourceEventTypes = map[string]string{
-	"insert":  MongoDbSourceInsertedEventType,
-	"delete":  MongoDbSourceDeletedEventType,
-	"replace": MongoDbSourceUpdatedEventType,
+	"insert":   MongoDbSourceInsertedEventType,
+	"delete":   MongoDbSourceDeletedEventType,
+	"replace":  MongoDbSourceUpdatedEventType,
+	"snapshot": MongoDbSourceSnapshotEventType,
 }
 
 const (
@@ -58,8 +63,45 @@ const (
 
 	// MongoDbSourceUpdatedEventType is the MongoDbSource CloudEvent type for an update.
 	MongoDbSourceUpdatedEventType = "google.com.mongodb.collection.v1.updated"
+
+	// MongoDbSourceSnapshotEventType is the MongoDbSource CloudEvent type for an existing
+	// document sent by a snapshot.
+	MongoDbSourceSnapshotEventType = "google.com.mongodb.collection.v1.snapshot"
+
+	// MongoDbSourceGapEventType is the MongoDbSource CloudEvent type sent when the changes made
+	// during a period were lost.
+	MongoDbSourceGapEventType = "google.com.mongodb.collection.v1.gap"
+)
+
+// RewindAnnotation is the annotation of a MongoDbSource rewinding its change stream to the RFC3339
+// time it holds. The checkpoints are reset and the receive adapters restarted whenever it changes.
+const RewindAnnotation = "sources.google.com/rewind"
+
+// ScopeAnnotation is the annotation of a MongoDbSource selecting the receive adapter watching its
+// changes: its own Deployment for ScopeResource, the default, or the multi-tenant receive adapter
+// of its namespace or of the cluster.
+const ScopeAnnotation = "sources.google.com/scope"
+
+const (
+	// ScopeResource gives the source its own receive adapter Deployment.
+	ScopeResource = "resource"
+
+	// ScopeNamespace watches the source from the multi-tenant receive adapter of its namespace. It
+	// is rejected by the validation until the controller deploys these receive adapters.
+	ScopeNamespace = "namespace"
+
+	// ScopeCluster watches the source from the multi-tenant receive adapter of the cluster.
+	ScopeCluster = "cluster"
 )
 
+// Scope returns the scope of the receive adapter of the source.
+func (m *MongoDbSource) Scope() string {
+	if scope, ok := m.Annotations[ScopeAnnotation]; ok {
+		return scope
+	}
+	return ScopeResource
+}
+
 // GetGroupVersionKind returns the GroupVersionKind.
 func (m *MongoDbSource) GetGroupVersionKind() schema.GroupVersionKind {
 	return SchemeGroupVersion.WithKind("MongoDbSource")
@@ -92,7 +134,16 @@ type MongoDbSourceSpec struct {
 
 	// MongoDbCredentials is the credential to use to access MongoDb.
 	// Must be a secret. Only Name and Namespace are used.
-	Secret corev1.LocalObjectReference `json:"secret"`
+	Secret MongoDbSourceSecret `json:"secret"`
+
+	// TLS enables TLS for the connections to MongoDb, with the CA bundle and client certificate
+	// of the given secrets. The client certificate also allows MONGODB-X509 authentication.
+	// +optional
+	TLS *MongoDbSourceTLS `json:"tls,omitempty"`
+
+	// Connection tunes the connections to MongoDb and the reads of the changes.
+	// +optional
+	Connection *MongoDbSourceConnection `json:"connection,omitempty"`
 
 	// Database is the database to watch for changes.
 	Database string `json:"database"`
@@ -101,6 +152,85 @@ type MongoDbSourceSpec struct {
 	// +optional
 	Collection string `json:"collection,omitempty"`
 
+	// TraceParentField is the name of a field of the changed documents holding a W3C
+	// traceparent written by the application. When present, it is used as the parent
+	// of the span created for the change. Nested fields are separated by dots.
+	// +optional
+	TraceParentField string `json:"traceParentField,omitempty"`
+
+	// ShutdownGracePeriodSeconds is the time the receive adapter is given on shutdown to send the
+	// changes it already read before it records its final checkpoint. Defaults to 20 seconds.
+	// +optional
+	ShutdownGracePeriodSeconds *int64 `json:"shutdownGracePeriodSeconds,omitempty"`
+
+	// Replicas is the number of receive adapter replicas. Replicas elect a leader through a
+	// Lease, only the leader watches the change stream while the others stand by to take
+	// over from the last checkpoint. When partitioning, it is the number of partitions instead.
+	// Defaults to 1.
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Partitioning shards the collections of the database across Replicas receive adapters,
+	// each watching its own subset of collections from its own checkpoint. Either "collections"
+	// or "hash". Collection must be empty.
+	// +optional
+	Partitioning PartitioningStrategy `json:"partitioning,omitempty"`
+
+	// Snapshot configures the snapshot of the existing documents sent before the changes.
+	// +optional
+	Snapshot *MongoDbSourceSnapshot `json:"snapshot,omitempty"`
+
+	// StartAt is where the change stream starts when the receive adapter has no checkpoint yet.
+	// Defaults to the time the receive adapter starts.
+	// +optional
+	StartAt *MongoDbSourceStartAt `json:"startAt,omitempty"`
+
+	// Mode is how the changes are read: "changeStream" (the default), which requires a replica
+	// set or a sharded cluster, "oplog" to tail the oplog of a replica set when change streams
+	// are not permitted, or "poll" for standalone servers.
+	// +optional
+	Mode SourceMode `json:"mode,omitempty"`
+
+	// Poll configures how the collections are polled when Mode is "poll".
+	// +optional
+	Poll *MongoDbSourcePoll `json:"poll,omitempty"`
+
+	// OnHistoryLost is what the receive adapter does when the oplog no longer covers its
+	// checkpoint: "fail" (the default), "restart" from now after sending a gap event, or
+	// "snapshot" the watched collections again.
+	// +optional
+	OnHistoryLost HistoryLostPolicy `json:"onHistoryLost,omitempty"`
+
+	// RequireExistingNamespace fails the source when its database or collection does not exist.
+	// When false, the receive adapter is deployed anyway to watch the namespace before it is
+	// created, and the NamespacePending condition is set until it exists. Defaults to true.
+	// +optional
+	RequireExistingNamespace *bool `json:"requireExistingNamespace,omitempty"`
+
+	// Adapter sets the resources, scheduling and security of the receive adapter pods, on top
+	// of the defaults of the controller ConfigMap.
+	// +optional
+	Adapter *MongoDbSourceAdapter `json:"adapter,omitempty"`
+
+	// Suspend stops the receive adapters of the source without deleting it, for instance during
+	// a maintenance window of the database. The checkpoints are kept, and the change stream
+	// continues from the stored resume token when the source is resumed, if the oplog still
+	// covers it.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// RetainCheckpointOnDelete keeps the checkpoints of the source when it is deleted, so that
+	// the source recreated with the same name in the same namespace resumes from them. By default
+	// the checkpoints are deleted along with the source.
+	// +optional
+	RetainCheckpointOnDelete bool `json:"retainCheckpointOnDelete,omitempty"`
+
+	// SourceTemplate overrides the CloudEvents source of the events, by default
+	// "mongodb://<cluster>/databases/<database>/collections/<collection>". It is a Go template
+	// given the .Cluster, .Database and .Collection of the event, see SourceTemplateData.
+	// +optional
+	SourceTemplate string `json:"sourceTemplate,omitempty"`
+
 	// SourceSpec
 	// inherits duck/v1 SourceSpec, which currently provides:
 	// * Sink - a reference to an object that will resolve to a domain name or
@@ -110,6 +240,260 @@ type MongoDbSourceSpec struct {
 	duckv1.SourceSpec `json:",inline"`
 }
 
+// SnapshotMode is when the existing documents are sent.
+type SnapshotMode string
+
+const (
+	// SnapshotNever only sends the changes. This is the default.
+	SnapshotNever SnapshotMode = "never"
+
+	// SnapshotInitial sends the existing documents when the receive adapter first starts.
+	SnapshotInitial SnapshotMode = "initial"
+
+	// SnapshotAlways sends the existing documents whenever the receive adapter starts.
+	SnapshotAlways SnapshotMode = "always"
+)
+
+// MongoDbSourceSnapshot configures the snapshot of the existing documents of the watched
+// collections. Each document is sent as a snapshot event, then the changes made since the
+// snapshot started are sent.
+type MongoDbSourceSnapshot struct {
+	// Mode is when the snapshot is taken, either "never", "initial" or "always".
+	Mode SnapshotMode `json:"mode"`
+
+	// BatchSize is the number of documents read at once. Defaults to the server default.
+	// +optional
+	BatchSize *int32 `json:"batchSize,omitempty"`
+}
+
+// MongoDbSourceSecret references the secret holding how to connect to MongoDb: either a
+// connection string, or the hosts and the credentials in separate keys.
+type MongoDbSourceSecret struct {
+	corev1.LocalObjectReference `json:",inline"`
+
+	// Keys are the names of the keys of the secret, which default to the names of their fields
+	// except for "URI".
+	// +optional
+	Keys *MongoDbSourceSecretKeys `json:"keys,omitempty"`
+}
+
+// MongoDbSourceSecretKeys are the names of the keys of the MongoDb secret. The connection string
+// is used if the secret has it, else the client is configured from the other keys.
+type MongoDbSourceSecretKeys struct {
+	// URI is the key of the connection string.
+	// +optional
+	URI string `json:"uri,omitempty"`
+
+	// Host is the key of the host, as "host" or "host:port".
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Hosts is the key of the comma-separated list of hosts.
+	// +optional
+	Hosts string `json:"hosts,omitempty"`
+
+	// Username is the key of the user name.
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Password is the key of the password.
+	// +optional
+	Password string `json:"password,omitempty"`
+
+	// AuthSource is the key of the database holding the user.
+	// +optional
+	AuthSource string `json:"authSource,omitempty"`
+
+	// AuthMechanism is the key of the authentication mechanism, such as "SCRAM-SHA-256".
+	// +optional
+	AuthMechanism string `json:"authMechanism,omitempty"`
+
+	// ReplicaSet is the key of the name of the replica set.
+	// +optional
+	ReplicaSet string `json:"replicaSet,omitempty"`
+
+	// Options is the key of other connection string options, as a query string such as
+	// "retryWrites=true&w=majority".
+	// +optional
+	Options string `json:"options,omitempty"`
+}
+
+// MongoDbSourceTLS configures TLS for the connections to MongoDb. The system CA bundle is used
+// if CA is not set.
+type MongoDbSourceTLS struct {
+	// CA selects the key of the secret holding the PEM CA bundle that signed the server
+	// certificates.
+	// +optional
+	CA *corev1.SecretKeySelector `json:"ca,omitempty"`
+
+	// Certificate selects the key of the secret holding the PEM client certificate.
+	// +optional
+	Certificate *corev1.SecretKeySelector `json:"certificate,omitempty"`
+
+	// Key selects the key of the secret holding the PEM private key of the client certificate.
+	// +optional
+	Key *corev1.SecretKeySelector `json:"key,omitempty"`
+}
+
+// MongoDbSourceConnection tunes the connections to MongoDb and the reads of the changes. The
+// driver defaults are used for the fields that are not set.
+type MongoDbSourceConnection struct {
+	// ReadPreference selects the members of the replica set the changes are read from.
+	// +optional
+	ReadPreference *MongoDbSourceReadPreference `json:"readPreference,omitempty"`
+
+	// ReadConcern is the level of the read concern: "local", "available", "majority",
+	// "linearizable" or "snapshot".
+	// +optional
+	ReadConcern string `json:"readConcern,omitempty"`
+
+	// BatchSize is the number of changes fetched at once.
+	// +optional
+	BatchSize *int32 `json:"batchSize,omitempty"`
+
+	// MaxAwaitTimeMilliseconds is how long the server waits for new changes before answering a
+	// fetch.
+	// +optional
+	MaxAwaitTimeMilliseconds *int64 `json:"maxAwaitTimeMilliseconds,omitempty"`
+
+	// AppName is the name of the client in the server logs and diagnostics. Defaults to the name
+	// of the source.
+	// +optional
+	AppName string `json:"appName,omitempty"`
+
+	// Compressors are the wire compressors to use in order of preference: "snappy" or "zlib".
+	// +optional
+	Compressors []string `json:"compressors,omitempty"`
+
+	// ConnectTimeoutSeconds bounds the time to open a connection.
+	// +optional
+	ConnectTimeoutSeconds *int64 `json:"connectTimeoutSeconds,omitempty"`
+
+	// ServerSelectionTimeoutSeconds bounds the time to find a server to run an operation on.
+	// +optional
+	ServerSelectionTimeoutSeconds *int64 `json:"serverSelectionTimeoutSeconds,omitempty"`
+
+	// SocketTimeoutSeconds bounds the time to wait for a read or write on a connection.
+	// +optional
+	SocketTimeoutSeconds *int64 `json:"socketTimeoutSeconds,omitempty"`
+}
+
+// MongoDbSourceReadPreference selects the members of the replica set to read from.
+type MongoDbSourceReadPreference struct {
+	// Mode is "primary", "primaryPreferred", "secondary", "secondaryPreferred" or "nearest".
+	Mode string `json:"mode"`
+
+	// TagSets are the tags of the members to read from, in order of preference. They cannot be
+	// used with the "primary" mode.
+	// +optional
+	TagSets []map[string]string `json:"tagSets,omitempty"`
+
+	// MaxStalenessSeconds is how far behind the primary a secondary may be to be read from. It
+	// cannot be used with the "primary" mode.
+	// +optional
+	MaxStalenessSeconds *int64 `json:"maxStalenessSeconds,omitempty"`
+}
+
+// SourceMode is how the changes of the watched collections are read.
+type SourceMode string
+
+const (
+	// SourceModeChangeStream watches the changes with a change stream. This is the default.
+	SourceModeChangeStream SourceMode = "changeStream"
+
+	// SourceModePoll periodically queries the watched collections for the documents whose
+	// poll field increased.
+	SourceModePoll SourceMode = "poll"
+
+	// SourceModeOplog tails the oplog of the replica set, for users who may read local.oplog.rs
+	// but not open change streams.
+	SourceModeOplog SourceMode = "oplog"
+)
+
+// MongoDbSourcePoll configures the polling of the watched collections.
+type MongoDbSourcePoll struct {
+	// Field is the field of the documents that increases whenever a document is inserted or
+	// updated, such as "updatedAt". Defaults to "_id", which only detects insertions.
+	// +optional
+	Field string `json:"field,omitempty"`
+
+	// IntervalSeconds is the time between two polls. Defaults to 10 seconds.
+	// +optional
+	IntervalSeconds *int64 `json:"intervalSeconds,omitempty"`
+
+	// DetectDeletes compares the keys of the documents between polls to send deletions. The keys
+	// of all the documents are then kept in the memory of the receive adapter, and are not
+	// checkpointed: the deletions made while the receive adapter is down are not sent.
+	// +optional
+	DetectDeletes bool `json:"detectDeletes,omitempty"`
+}
+
+// HistoryLostPolicy is what the receive adapter does when the history of the change stream is lost.
+type HistoryLostPolicy string
+
+const (
+	// HistoryLostFail stops the receive adapter, which fails until it is rewound. This is the
+	// default.
+	HistoryLostFail HistoryLostPolicy = "fail"
+
+	// HistoryLostRestart sends a gap event and restarts the change stream from now.
+	HistoryLostRestart HistoryLostPolicy = "restart"
+
+	// HistoryLostSnapshot sends a snapshot of the watched collections, then restarts the change
+	// stream from the start of the snapshot.
+	HistoryLostSnapshot HistoryLostPolicy = "snapshot"
+)
+
+// MongoDbSourceStartAt is where the change stream starts. Exactly one field must be set.
+type MongoDbSourceStartAt struct {
+	// Time starts the change stream at the changes made from this time on, which must still be
+	// in the oplog.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+
+	// ResumeToken starts the change stream right after the change with this resume token, the
+	// _data field of the _id of the change.
+	// +optional
+	ResumeToken string `json:"resumeToken,omitempty"`
+}
+
+// MongoDbSourceAdapter sets fields of the pod template of the receive adapters. The fields that
+// are set replace the cluster-wide defaults, but the node selector and the resource quantities
+// are merged by key with them.
+type MongoDbSourceAdapter struct {
+	// Resources are the compute resources of the receive adapter container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector selects the nodes the receive adapter pods run on.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are the tolerations of the receive adapter pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity is the scheduling affinity of the receive adapter pods.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PriorityClassName is the priority class of the receive adapter pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// PodSecurityContext is the security context of the receive adapter pods.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is the security context of the receive adapter container.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// ImagePullSecrets are the secrets used to pull the receive adapter image.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
 // MongoDbSourceStatus defines the observed state of MongoDbSource.
 type MongoDbSourceStatus struct {
 	// inherits duck/v1 SourceStatus, which currently provides:
@@ -120,6 +504,110 @@ type MongoDbSourceStatus struct {
 	// * SinkURI - the current active sink URI that has been configured for the
 	//   Source.
 	duckv1.SourceStatus `json:",inline"`
+
+	// Partitions lists the collections watched by each receive adapter when partitioning.
+	// +optional
+	Partitions []MongoDbSourcePartition `json:"partitions,omitempty"`
+
+	// Topology describes the MongoDb deployment found by the last connection check.
+	// +optional
+	Topology *MongoDbSourceTopology `json:"topology,omitempty"`
+}
+
+// TopologyType is the type of a MongoDb deployment.
+type TopologyType string
+
+const (
+	// TopologyReplicaSet is a replica set.
+	TopologyReplicaSet TopologyType = "ReplicaSet"
+
+	// TopologySharded is a sharded cluster, reached through mongos routers.
+	TopologySharded TopologyType = "Sharded"
+
+	// TopologyStandalone is a standalone server, which has no change streams.
+	TopologyStandalone TopologyType = "Standalone"
+)
+
+// MongoDbSourceTopology describes the MongoDb deployment a source connects to.
+type MongoDbSourceTopology struct {
+	// Type is the type of the deployment.
+	// +optional
+	Type TopologyType `json:"type,omitempty"`
+
+	// ReplicaSet is the name of the replica set, empty unless Type is ReplicaSet.
+	// +optional
+	ReplicaSet string `json:"replicaSet,omitempty"`
+
+	// ServerVersion is the version of the MongoDb server.
+	// +optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// Hosts are the members of the replica set, or the mongos routers of a sharded cluster.
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+
+	// DatabaseExists is whether the database of the source exists.
+	DatabaseExists bool `json:"databaseExists"`
+
+	// CollectionExists is whether the collection of the source exists, unset when the source
+	// watches a whole database.
+	// +optional
+	CollectionExists *bool `json:"collectionExists,omitempty"`
+}
+
+// SourceTemplateData is the data given to the SourceTemplate of a MongoDbSource.
+// +k8s:deepcopy-gen=false
+type SourceTemplateData struct {
+	// Cluster identifies the MongoDb deployment whatever its failovers: the name of its replica
+	// set, else the domain of its mongodb+srv connection string, else its sorted hosts.
+	Cluster string
+	// Database is the database of the source.
+	Database string
+	// Collection is the collection of the change, empty in the events about the whole database.
+	Collection string
+}
+
+// ParseSourceTemplate parses the SourceTemplate of a MongoDbSource, and checks that it renders a
+// source.
+func ParseSourceTemplate(text string) (*template.Template, error) {
+	t, err := template.New("sourceTemplate").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, SourceTemplateData{Cluster: "rs0", Database: "db", Collection: "coll"}); err != nil {
+		return nil, err
+	}
+	if b.Len() == 0 {
+		return nil, errors.New("the template renders an empty source")
+	}
+	return t, nil
+}
+
+// PartitioningStrategy is the way the collections of a database are assigned to partitions.
+type PartitioningStrategy string
+
+const (
+	// PartitionByCollections balances the collections across the partitions. Creating a
+	// collection may reassign others.
+	PartitionByCollections PartitioningStrategy = "collections"
+
+	// PartitionByHash assigns each collection to a partition after the hash of its namespace.
+	// Collections are only reassigned when the number of partitions changes.
+	PartitionByHash PartitioningStrategy = "hash"
+)
+
+// MongoDbSourcePartition is a subset of the collections of a database watched by a receive adapter.
+type MongoDbSourcePartition struct {
+	// Index is the index of the partition.
+	Index int32 `json:"index"`
+
+	// Deployment is the name of the receive adapter Deployment watching the partition.
+	Deployment string `json:"deployment"`
+
+	// Collections are the collections assigned to the partition.
+	// +optional
+	Collections []string `json:"collections,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -18,9 +18,13 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 )
@@ -32,6 +36,38 @@ func (m *MongoDbSource) Validate(ctx context.Context) *apis.FieldError {
 	//validation for "spec" field.
 	errs = errs.Also(m.Spec.ValidateSpecs(ctx).ViaField("spec"))
 
+	//Validation for the rewind annotation.
+	if rewind, ok := m.Annotations[RewindAnnotation]; ok {
+		if _, err := time.Parse(time.RFC3339, rewind); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(rewind, RewindAnnotation).ViaField("metadata", "annotations"))
+		}
+	}
+
+	//Validation for the scope annotation.
+	switch scope := m.Scope(); scope {
+	case ScopeResource:
+	case ScopeNamespace:
+		// No multi-tenant receive adapter is deployed in the namespaces of the sources yet.
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("scope %q is not supported yet", scope),
+			Paths:   []string{"metadata.annotations." + ScopeAnnotation},
+		})
+	case ScopeCluster:
+		// The multi-tenant receive adapter runs the streams of many sources in a single shared
+		// Deployment, so per-source pod settings do not apply.
+		if m.Spec.Replicas != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("spec.replicas"))
+		}
+		if m.Spec.Partitioning != "" {
+			errs = errs.Also(apis.ErrDisallowedFields("spec.partitioning"))
+		}
+		if m.Spec.Adapter != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("spec.adapter"))
+		}
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(scope, ScopeAnnotation).ViaField("metadata", "annotations"))
+	}
+
 	//errs is nil if everything is fine.
 	return errs
 }
@@ -58,9 +94,312 @@ func (ms *MongoDbSourceSpec) ValidateSpecs(ctx context.Context) *apis.FieldError
 	}
 
 	//Validation for secret field.
-	if equality.Semantic.DeepEqual(ms.Secret, corev1.LocalObjectReference{}) {
+	if equality.Semantic.DeepEqual(ms.Secret.LocalObjectReference, corev1.LocalObjectReference{}) {
 		errs = errs.Also(apis.ErrMissingField("secret"))
 	}
+	if keys := ms.Secret.Keys; keys != nil {
+		for field, key := range map[string]string{
+			"uri":           keys.URI,
+			"host":          keys.Host,
+			"hosts":         keys.Hosts,
+			"username":      keys.Username,
+			"password":      keys.Password,
+			"authSource":    keys.AuthSource,
+			"authMechanism": keys.AuthMechanism,
+			"replicaSet":    keys.ReplicaSet,
+			"options":       keys.Options,
+		} {
+			if key != "" && len(validation.IsConfigMapKey(key)) > 0 {
+				errs = errs.Also(apis.ErrInvalidValue(key, "secret.keys."+field))
+			}
+		}
+	}
+
+	//Validation for tls field.
+	if ms.TLS != nil {
+		for field, selector := range map[string]*corev1.SecretKeySelector{
+			"tls.ca":          ms.TLS.CA,
+			"tls.certificate": ms.TLS.Certificate,
+			"tls.key":         ms.TLS.Key,
+		} {
+			if selector == nil {
+				continue
+			}
+			if selector.Name == "" {
+				errs = errs.Also(apis.ErrMissingField(field + ".name"))
+			}
+			if selector.Key == "" {
+				errs = errs.Also(apis.ErrMissingField(field + ".key"))
+			}
+		}
+		// The client certificate comes with its key.
+		if ms.TLS.Certificate != nil && ms.TLS.Key == nil {
+			errs = errs.Also(apis.ErrMissingField("tls.key"))
+		}
+		if ms.TLS.Key != nil && ms.TLS.Certificate == nil {
+			errs = errs.Also(apis.ErrMissingField("tls.certificate"))
+		}
+	}
+
+	//Validation for connection field.
+	if ms.Connection != nil {
+		errs = errs.Also(ms.Connection.validate().ViaField("connection"))
+	}
+
+	//Validation for shutdownGracePeriodSeconds field.
+	if ms.ShutdownGracePeriodSeconds != nil && *ms.ShutdownGracePeriodSeconds < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*ms.ShutdownGracePeriodSeconds, "shutdownGracePeriodSeconds"))
+	}
+
+	//Validation for replicas field.
+	if ms.Replicas != nil && *ms.Replicas < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*ms.Replicas, "replicas"))
+	}
+
+	//Validation for partitioning field.
+	switch ms.Partitioning {
+	case "":
+	case PartitionByCollections, PartitionByHash:
+		if ms.Collection != "" {
+			errs = errs.Also(apis.ErrMultipleOneOf("collection", "partitioning"))
+		}
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(ms.Partitioning, "partitioning"))
+	}
+
+	//Validation for snapshot field.
+	if ms.Snapshot != nil {
+		switch ms.Snapshot.Mode {
+		case SnapshotNever, SnapshotInitial, SnapshotAlways:
+		default:
+			errs = errs.Also(apis.ErrInvalidValue(ms.Snapshot.Mode, "snapshot.mode"))
+		}
+		if ms.Snapshot.BatchSize != nil && *ms.Snapshot.BatchSize < 1 {
+			errs = errs.Also(apis.ErrInvalidValue(*ms.Snapshot.BatchSize, "snapshot.batchSize"))
+		}
+	}
+
+	//Validation for mode field.
+	switch ms.Mode {
+	case "", SourceModeChangeStream:
+		if ms.Poll != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("poll"))
+		}
+	case SourceModePoll:
+		if ms.Snapshot != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("snapshot"))
+		}
+		if ms.StartAt != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("startAt"))
+		}
+		if ms.OnHistoryLost != "" {
+			errs = errs.Also(apis.ErrDisallowedFields("onHistoryLost"))
+		}
+		if ms.Poll != nil && ms.Poll.IntervalSeconds != nil && *ms.Poll.IntervalSeconds < 1 {
+			errs = errs.Also(apis.ErrInvalidValue(*ms.Poll.IntervalSeconds, "poll.intervalSeconds"))
+		}
+	case SourceModeOplog:
+		if ms.Poll != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("poll"))
+		}
+		if ms.Snapshot != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("snapshot"))
+		}
+		if ms.StartAt != nil && ms.StartAt.ResumeToken != "" {
+			errs = errs.Also(apis.ErrDisallowedFields("startAt.resumeToken"))
+		}
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(ms.Mode, "mode"))
+	}
+
+	//Validation for onHistoryLost field.
+	switch ms.OnHistoryLost {
+	case "", HistoryLostFail, HistoryLostRestart, HistoryLostSnapshot:
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(ms.OnHistoryLost, "onHistoryLost"))
+	}
+
+	//Validation for startAt field.
+	if ms.StartAt != nil {
+		switch {
+		case ms.StartAt.Time == nil && ms.StartAt.ResumeToken == "":
+			errs = errs.Also(apis.ErrMissingOneOf("startAt.time", "startAt.resumeToken"))
+		case ms.StartAt.Time != nil && ms.StartAt.ResumeToken != "":
+			errs = errs.Also(apis.ErrMultipleOneOf("startAt.time", "startAt.resumeToken"))
+		}
+	}
+
+	//Validation for adapter field.
+	if ms.Adapter != nil {
+		errs = errs.Also(ms.Adapter.Validate(ctx).ViaField("adapter"))
+	}
+
+	//Validation for sourceTemplate field.
+	if ms.SourceTemplate != "" {
+		if _, err := ParseSourceTemplate(ms.SourceTemplate); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("invalid value: %s", ms.SourceTemplate),
+				Paths:   []string{"sourceTemplate"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	return errs
+}
+
+// Validate validates MongoDbSourceAdapter. It also validates the cluster-wide defaults of the
+// controller ConfigMap.
+func (a *MongoDbSourceAdapter) Validate(ctx context.Context) *apis.FieldError {
+	var errs *apis.FieldError
+
+	for name, limit := range a.Resources.Limits {
+		if limit.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(limit.String(), "resources.limits."+string(name)))
+		}
+	}
+	for name, request := range a.Resources.Requests {
+		if request.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(request.String(), "resources.requests."+string(name)))
+		}
+		if limit, ok := a.Resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("request %s must be less than or equal to limit %s", request.String(), limit.String()),
+				Paths:   []string{"resources.requests." + string(name)},
+			})
+		}
+	}
+
+	for key, value := range a.NodeSelector {
+		if len(validation.IsQualifiedName(key)) > 0 {
+			errs = errs.Also(apis.ErrInvalidKeyName(key, "nodeSelector"))
+		}
+		if len(validation.IsValidLabelValue(value)) > 0 {
+			errs = errs.Also(apis.ErrInvalidValue(value, "nodeSelector."+key))
+		}
+	}
+
+	for i, toleration := range a.Tolerations {
+		errs = errs.Also(validateToleration(toleration).ViaFieldIndex("tolerations", i))
+	}
+
+	if a.PriorityClassName != "" && len(validation.IsDNS1123Subdomain(a.PriorityClassName)) > 0 {
+		errs = errs.Also(apis.ErrInvalidValue(a.PriorityClassName, "priorityClassName"))
+	}
+
+	if psc := a.PodSecurityContext; psc != nil {
+		errs = errs.Also(validateIDs(psc.RunAsUser, psc.RunAsGroup).ViaField("podSecurityContext"))
+		if psc.FSGroup != nil && *psc.FSGroup < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(*psc.FSGroup, "podSecurityContext.fsGroup"))
+		}
+	}
+	if sc := a.SecurityContext; sc != nil {
+		errs = errs.Also(validateIDs(sc.RunAsUser, sc.RunAsGroup).ViaField("securityContext"))
+		if sc.Privileged != nil && *sc.Privileged && sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation {
+			errs = errs.Also(apis.ErrMultipleOneOf("securityContext.privileged", "securityContext.allowPrivilegeEscalation"))
+		}
+	}
+
+	for i, secret := range a.ImagePullSecrets {
+		if secret.Name == "" {
+			errs = errs.Also(apis.ErrMissingField("name").ViaFieldIndex("imagePullSecrets", i))
+		}
+	}
+
+	return errs
+}
+
+// validateToleration validates a toleration of the receive adapter pods.
+func validateToleration(toleration corev1.Toleration) *apis.FieldError {
+	var errs *apis.FieldError
+
+	if toleration.Key != "" && len(validation.IsQualifiedName(toleration.Key)) > 0 {
+		errs = errs.Also(apis.ErrInvalidValue(toleration.Key, "key"))
+	}
+	switch toleration.Operator {
+	case "", corev1.TolerationOpEqual:
+		// An empty key matches all the taints, which only makes sense with Exists.
+		if toleration.Key == "" {
+			errs = errs.Also(apis.ErrMissingField("key"))
+		}
+	case corev1.TolerationOpExists:
+		if toleration.Value != "" {
+			errs = errs.Also(apis.ErrDisallowedFields("value"))
+		}
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(toleration.Operator, "operator"))
+	}
+	switch toleration.Effect {
+	case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule:
+		if toleration.TolerationSeconds != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("tolerationSeconds"))
+		}
+	case corev1.TaintEffectNoExecute:
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(toleration.Effect, "effect"))
+	}
+	return errs
+}
+
+// validateIDs validates the user and group IDs of a security context.
+func validateIDs(runAsUser, runAsGroup *int64) *apis.FieldError {
+	var errs *apis.FieldError
+	if runAsUser != nil && *runAsUser < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*runAsUser, "runAsUser"))
+	}
+	if runAsGroup != nil && *runAsGroup < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*runAsGroup, "runAsGroup"))
+	}
+	return errs
+}
+
+// validate validates MongoDbSourceConnection.
+func (c *MongoDbSourceConnection) validate() *apis.FieldError {
+	var errs *apis.FieldError
+
+	if rp := c.ReadPreference; rp != nil {
+		mode, err := readpref.ModeFromString(rp.Mode)
+		if err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(rp.Mode, "readPreference.mode"))
+		}
+		if mode == readpref.PrimaryMode && len(rp.TagSets) > 0 {
+			errs = errs.Also(apis.ErrDisallowedFields("readPreference.tagSets"))
+		}
+		if mode == readpref.PrimaryMode && rp.MaxStalenessSeconds != nil {
+			errs = errs.Also(apis.ErrDisallowedFields("readPreference.maxStalenessSeconds"))
+		}
+		if rp.MaxStalenessSeconds != nil && *rp.MaxStalenessSeconds < 1 {
+			errs = errs.Also(apis.ErrInvalidValue(*rp.MaxStalenessSeconds, "readPreference.maxStalenessSeconds"))
+		}
+	}
+
+	switch c.ReadConcern {
+	case "", "local", "available", "majority", "linearizable", "snapshot":
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(c.ReadConcern, "readConcern"))
+	}
+
+	for i, compressor := range c.Compressors {
+		switch compressor {
+		case "snappy", "zlib":
+		default:
+			errs = errs.Also(apis.ErrInvalidArrayValue(compressor, "compressors", i))
+		}
+	}
+
+	if c.BatchSize != nil && *c.BatchSize < 1 {
+		errs = errs.Also(apis.ErrInvalidValue(*c.BatchSize, "batchSize"))
+	}
+	for field, value := range map[string]*int64{
+		"maxAwaitTimeMilliseconds":      c.MaxAwaitTimeMilliseconds,
+		"connectTimeoutSeconds":         c.ConnectTimeoutSeconds,
+		"serverSelectionTimeoutSeconds": c.ServerSelectionTimeoutSeconds,
+		"socketTimeoutSeconds":          c.SocketTimeoutSeconds,
+	} {
+		if value != nil && *value < 1 {
+			errs = errs.Also(apis.ErrInvalidValue(*value, field))
+		}
+	}
 
 	return errs
 }
@@ -22,6 +22,8 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"knative.dev/pkg/webhook/resourcesemantics"
 
@@ -29,6 +31,7 @@ import (
 )
 
 func TestMongoDbSourceValidation(t *testing.T) {
+	two := int32(2)
 	testCases := map[string]struct {
 		cr   resourcesemantics.GenericCRD
 		want *apis.FieldError
@@ -48,8 +51,8 @@ func TestMongoDbSourceValidation(t *testing.T) {
 			cr: &MongoDbSource{
 				Spec: MongoDbSourceSpec{
 					ServiceAccountName: "google",
-					Secret: corev1.LocalObjectReference{
-						Name: "pwd",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
 					},
 					Database:   "db",
 					Collection: "col1",
@@ -76,8 +79,8 @@ func TestMongoDbSourceValidation(t *testing.T) {
 			cr: &MongoDbSource{
 				Spec: MongoDbSourceSpec{
 					ServiceAccountName: "google",
-					Secret: corev1.LocalObjectReference{
-						Name: "",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: ""},
 					},
 					Database:   "db",
 					Collection: "col1",
@@ -100,12 +103,624 @@ func TestMongoDbSourceValidation(t *testing.T) {
 				return errs
 			}(),
 		},
+		"Negative shutdown grace period": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:                   "db",
+					ShutdownGracePeriodSeconds: func() *int64 { i := int64(-1); return &i }(),
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrInvalidValue(-1, "spec.shutdownGracePeriodSeconds")
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"No replicas": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					Replicas: func() *int32 { i := int32(0); return &i }(),
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrInvalidValue(0, "spec.replicas")
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Unknown partitioning": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:     "db",
+					Partitioning: "range",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrInvalidValue("range", "spec.partitioning")
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Partitioning a collection": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:     "db",
+					Collection:   "col1",
+					Partitioning: "hash",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrMultipleOneOf("spec.collection", "spec.partitioning")
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Unknown snapshot mode": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					Snapshot: &MongoDbSourceSnapshot{
+						Mode:      "sometimes",
+						BatchSize: func() *int32 { i := int32(0); return &i }(),
+					},
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				errs = errs.Also(apis.ErrInvalidValue("sometimes", "spec.snapshot.mode"))
+				errs = errs.Also(apis.ErrInvalidValue(0, "spec.snapshot.batchSize"))
+				return errs
+			}(),
+		},
+		"Poll with a snapshot": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					Mode:     SourceModePoll,
+					Poll: &MongoDbSourcePoll{
+						Field:           "updatedAt",
+						IntervalSeconds: func() *int64 { i := int64(0); return &i }(),
+					},
+					Snapshot: &MongoDbSourceSnapshot{Mode: SnapshotInitial},
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				errs = errs.Also(apis.ErrDisallowedFields("spec.snapshot"))
+				errs = errs.Also(apis.ErrInvalidValue(0, "spec.poll.intervalSeconds"))
+				return errs
+			}(),
+		},
+		"Oplog restarted when its history is lost": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:      "db",
+					Mode:          SourceModeOplog,
+					OnHistoryLost: HistoryLostRestart,
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: nil,
+		},
+		"Oplog from a resume token": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					Mode:     SourceModeOplog,
+					StartAt:  &MongoDbSourceStartAt{ResumeToken: `{"_data":"825F3A"}`},
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: apis.ErrDisallowedFields("spec.startAt.resumeToken"),
+		},
+		"Invalid secret key": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+						Keys:                 &MongoDbSourceSecretKeys{Hosts: "mongo-hosts", Password: "pass word"},
+					},
+					Database: "db",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: apis.ErrInvalidValue("pass word", "spec.secret.keys.password"),
+		},
+		"TLS certificate without key": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					TLS: &MongoDbSourceTLS{
+						CA: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "tls"}},
+						Certificate: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "tls"},
+							Key:                  "tls.crt",
+						},
+					},
+					Database: "db",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: apis.ErrMissingField("spec.tls.ca.key", "spec.tls.key"),
+		},
+		"Primary read preference with tags": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Connection: &MongoDbSourceConnection{
+						ReadPreference: &MongoDbSourceReadPreference{
+							Mode:    "primary",
+							TagSets: []map[string]string{{"region": "east"}},
+						},
+						Compressors: []string{"zstd"},
+					},
+					Database: "db",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: apis.ErrDisallowedFields("spec.connection.readPreference.tagSets").Also(
+				apis.ErrInvalidArrayValue("zstd", "spec.connection.compressors", 0)),
+		},
+		"Unknown history lost policy": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:      "db",
+					OnHistoryLost: "ignore",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrInvalidValue("ignore", "spec.onHistoryLost")
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Both startAt time and resume token": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					StartAt: &MongoDbSourceStartAt{
+						Time:        &metav1.Time{},
+						ResumeToken: "8263",
+					},
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrMultipleOneOf("spec.startAt.time", "spec.startAt.resumeToken")
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Invalid rewind annotation": {
+			cr: &MongoDbSource{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{RewindAnnotation: "yesterday"},
+				},
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrInvalidValue("yesterday", "metadata.annotations."+RewindAnnotation)
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Invalid scope annotation": {
+			cr: &MongoDbSource{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ScopeAnnotation: "everywhere"},
+				},
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrInvalidValue("everywhere", "metadata.annotations."+ScopeAnnotation)
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Namespace scope": {
+			cr: &MongoDbSource{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ScopeAnnotation: ScopeNamespace},
+				},
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := &apis.FieldError{
+					Message: `scope "namespace" is not supported yet`,
+					Paths:   []string{"metadata.annotations." + ScopeAnnotation},
+				}
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Partitioning a cluster scoped source": {
+			cr: &MongoDbSource{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ScopeAnnotation: ScopeCluster},
+				},
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:     "db",
+					Replicas:     &two,
+					Partitioning: PartitionByHash,
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				fe := apis.ErrDisallowedFields("spec.replicas", "spec.partitioning")
+				errs = errs.Also(fe)
+				return errs
+			}(),
+		},
+		"Invalid adapter": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database: "db",
+					Adapter: &MongoDbSourceAdapter{
+						Resources: corev1.ResourceRequirements{
+							Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+							Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+						},
+						Tolerations: []corev1.Toleration{{
+							Key:      "dedicated",
+							Operator: "Maybe",
+						}},
+						PriorityClassName: "High_Priority",
+						ImagePullSecrets:  []corev1.LocalObjectReference{{}},
+					},
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: func() *apis.FieldError {
+				var errs *apis.FieldError
+				errs = errs.Also(&apis.FieldError{
+					Message: "request 128Mi must be less than or equal to limit 64Mi",
+					Paths:   []string{"spec.adapter.resources.requests.memory"},
+				})
+				errs = errs.Also(apis.ErrInvalidValue("Maybe", "spec.adapter.tolerations[0].operator"))
+				errs = errs.Also(apis.ErrInvalidValue("High_Priority", "spec.adapter.priorityClassName"))
+				errs = errs.Also(apis.ErrMissingField("spec.adapter.imagePullSecrets[0].name"))
+				return errs
+			}(),
+		},
+		"Valid source template": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:       "db",
+					SourceTemplate: "mongodb://{{.Cluster}}/{{.Database}}/{{.Collection}}",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: nil,
+		},
+		"Invalid source template": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:       "db",
+					SourceTemplate: "mongodb://{{.Host}}",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: &apis.FieldError{
+				Message: "invalid value: mongodb://{{.Host}}",
+				Paths:   []string{"spec.sourceTemplate"},
+				Details: `template: sourceTemplate:1:12: executing "sourceTemplate" at <.Host>: can't evaluate field Host in type v1alpha1.SourceTemplateData`,
+			},
+		},
+		"Empty source template": {
+			cr: &MongoDbSource{
+				Spec: MongoDbSourceSpec{
+					ServiceAccountName: "google",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
+					},
+					Database:       "db",
+					SourceTemplate: "{{if false}}source{{end}}",
+					SourceSpec: duckv1.SourceSpec{
+						Sink: duckv1.Destination{
+							Ref: &duckv1.KReference{
+								APIVersion: "foo",
+								Kind:       "bar",
+								Namespace:  "baz",
+								Name:       "qux",
+							},
+						},
+					},
+				},
+			},
+			want: &apis.FieldError{
+				Message: "invalid value: {{if false}}source{{end}}",
+				Paths:   []string{"spec.sourceTemplate"},
+				Details: "the template renders an empty source",
+			},
+		},
 		"All fields present": {
 			cr: &MongoDbSource{
 				Spec: MongoDbSourceSpec{
 					ServiceAccountName: "google",
-					Secret: corev1.LocalObjectReference{
-						Name: "pwd",
+					Secret: MongoDbSourceSecret{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pwd"},
 					},
 					Database:   "db",
 					Collection: "col1",
@@ -134,3 +749,73 @@ func TestMongoDbSourceValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestMongoDbSourceAdapterValidation(t *testing.T) {
+	seconds := int64(60)
+	negative := int64(-1)
+	testCases := map[string]struct {
+		adapter *MongoDbSourceAdapter
+		want    *apis.FieldError
+	}{
+		"valid": {
+			adapter: &MongoDbSourceAdapter{
+				Resources: corev1.ResourceRequirements{
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				},
+				NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+				Tolerations: []corev1.Toleration{{
+					Operator: corev1.TolerationOpExists,
+				}, {
+					Key:               "dedicated",
+					Value:             "sources",
+					Effect:            corev1.TaintEffectNoExecute,
+					TolerationSeconds: &seconds,
+				}},
+				PriorityClassName: "high-priority",
+				ImagePullSecrets:  []corev1.LocalObjectReference{{Name: "registry"}},
+			},
+		},
+		"invalid node selector": {
+			adapter: &MongoDbSourceAdapter{
+				NodeSelector: map[string]string{"-zone": "a b"},
+			},
+			want: apis.ErrInvalidKeyName("-zone", "nodeSelector").Also(apis.ErrInvalidValue("a b", "nodeSelector.-zone")),
+		},
+		"invalid tolerations": {
+			adapter: &MongoDbSourceAdapter{
+				Tolerations: []corev1.Toleration{{
+					Value: "sources",
+				}, {
+					Key:      "dedicated",
+					Operator: corev1.TolerationOpExists,
+					Value:    "sources",
+				}, {
+					Key:               "dedicated",
+					Effect:            corev1.TaintEffectNoSchedule,
+					TolerationSeconds: &seconds,
+				}},
+			},
+			want: apis.ErrMissingField("tolerations[0].key").
+				Also(apis.ErrDisallowedFields("tolerations[1].value")).
+				Also(apis.ErrDisallowedFields("tolerations[2].tolerationSeconds")),
+		},
+		"negative user": {
+			adapter: &MongoDbSourceAdapter{
+				PodSecurityContext: &corev1.PodSecurityContext{RunAsUser: &negative},
+				SecurityContext:    &corev1.SecurityContext{RunAsGroup: &negative},
+			},
+			want: apis.ErrInvalidValue(negative, "podSecurityContext.runAsUser").
+				Also(apis.ErrInvalidValue(negative, "securityContext.runAsGroup")),
+		},
+	}
+
+	for n, test := range testCases {
+		t.Run(n, func(t *testing.T) {
+			got := test.adapter.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Errorf("%s: validate (-want, +got) = %v", n, diff)
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -52,6 +53,108 @@ func (in *MongoDbSource) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceAdapter) DeepCopyInto(out *MongoDbSourceAdapter) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceAdapter.
+func (in *MongoDbSourceAdapter) DeepCopy() *MongoDbSourceAdapter {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceAdapter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceConnection) DeepCopyInto(out *MongoDbSourceConnection) {
+	*out = *in
+	if in.ReadPreference != nil {
+		in, out := &in.ReadPreference, &out.ReadPreference
+		*out = new(MongoDbSourceReadPreference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BatchSize != nil {
+		in, out := &in.BatchSize, &out.BatchSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxAwaitTimeMilliseconds != nil {
+		in, out := &in.MaxAwaitTimeMilliseconds, &out.MaxAwaitTimeMilliseconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Compressors != nil {
+		in, out := &in.Compressors, &out.Compressors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConnectTimeoutSeconds != nil {
+		in, out := &in.ConnectTimeoutSeconds, &out.ConnectTimeoutSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ServerSelectionTimeoutSeconds != nil {
+		in, out := &in.ServerSelectionTimeoutSeconds, &out.ServerSelectionTimeoutSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SocketTimeoutSeconds != nil {
+		in, out := &in.SocketTimeoutSeconds, &out.SocketTimeoutSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceConnection.
+func (in *MongoDbSourceConnection) DeepCopy() *MongoDbSourceConnection {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceConnection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MongoDbSourceList) DeepCopyInto(out *MongoDbSourceList) {
 	*out = *in
@@ -85,10 +188,190 @@ func (in *MongoDbSourceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourcePartition) DeepCopyInto(out *MongoDbSourcePartition) {
+	*out = *in
+	if in.Collections != nil {
+		in, out := &in.Collections, &out.Collections
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourcePartition.
+func (in *MongoDbSourcePartition) DeepCopy() *MongoDbSourcePartition {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourcePartition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourcePoll) DeepCopyInto(out *MongoDbSourcePoll) {
+	*out = *in
+	if in.IntervalSeconds != nil {
+		in, out := &in.IntervalSeconds, &out.IntervalSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourcePoll.
+func (in *MongoDbSourcePoll) DeepCopy() *MongoDbSourcePoll {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourcePoll)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceReadPreference) DeepCopyInto(out *MongoDbSourceReadPreference) {
+	*out = *in
+	if in.TagSets != nil {
+		in, out := &in.TagSets, &out.TagSets
+		*out = make([]map[string]string, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+		}
+	}
+	if in.MaxStalenessSeconds != nil {
+		in, out := &in.MaxStalenessSeconds, &out.MaxStalenessSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceReadPreference.
+func (in *MongoDbSourceReadPreference) DeepCopy() *MongoDbSourceReadPreference {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceReadPreference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceSecret) DeepCopyInto(out *MongoDbSourceSecret) {
+	*out = *in
+	out.LocalObjectReference = in.LocalObjectReference
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = new(MongoDbSourceSecretKeys)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceSecret.
+func (in *MongoDbSourceSecret) DeepCopy() *MongoDbSourceSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceSecretKeys) DeepCopyInto(out *MongoDbSourceSecretKeys) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceSecretKeys.
+func (in *MongoDbSourceSecretKeys) DeepCopy() *MongoDbSourceSecretKeys {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceSecretKeys)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceSnapshot) DeepCopyInto(out *MongoDbSourceSnapshot) {
+	*out = *in
+	if in.BatchSize != nil {
+		in, out := &in.BatchSize, &out.BatchSize
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceSnapshot.
+func (in *MongoDbSourceSnapshot) DeepCopy() *MongoDbSourceSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MongoDbSourceSpec) DeepCopyInto(out *MongoDbSourceSpec) {
 	*out = *in
-	out.Secret = in.Secret
+	in.Secret.DeepCopyInto(&out.Secret)
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(MongoDbSourceTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Connection != nil {
+		in, out := &in.Connection, &out.Connection
+		*out = new(MongoDbSourceConnection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ShutdownGracePeriodSeconds != nil {
+		in, out := &in.ShutdownGracePeriodSeconds, &out.ShutdownGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Poll != nil {
+		in, out := &in.Poll, &out.Poll
+		*out = new(MongoDbSourcePoll)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Snapshot != nil {
+		in, out := &in.Snapshot, &out.Snapshot
+		*out = new(MongoDbSourceSnapshot)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StartAt != nil {
+		in, out := &in.StartAt, &out.StartAt
+		*out = new(MongoDbSourceStartAt)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequireExistingNamespace != nil {
+		in, out := &in.RequireExistingNamespace, &out.RequireExistingNamespace
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Adapter != nil {
+		in, out := &in.Adapter, &out.Adapter
+		*out = new(MongoDbSourceAdapter)
+		(*in).DeepCopyInto(*out)
+	}
 	in.SourceSpec.DeepCopyInto(&out.SourceSpec)
 	return
 }
@@ -103,10 +386,99 @@ func (in *MongoDbSourceSpec) DeepCopy() *MongoDbSourceSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceStartAt) DeepCopyInto(out *MongoDbSourceStartAt) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceStartAt.
+func (in *MongoDbSourceStartAt) DeepCopy() *MongoDbSourceStartAt {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceStartAt)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceTLS) DeepCopyInto(out *MongoDbSourceTLS) {
+	*out = *in
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Key != nil {
+		in, out := &in.Key, &out.Key
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceTLS.
+func (in *MongoDbSourceTLS) DeepCopy() *MongoDbSourceTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MongoDbSourceTopology) DeepCopyInto(out *MongoDbSourceTopology) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CollectionExists != nil {
+		in, out := &in.CollectionExists, &out.CollectionExists
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MongoDbSourceTopology.
+func (in *MongoDbSourceTopology) DeepCopy() *MongoDbSourceTopology {
+	if in == nil {
+		return nil
+	}
+	out := new(MongoDbSourceTopology)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MongoDbSourceStatus) DeepCopyInto(out *MongoDbSourceStatus) {
 	*out = *in
 	in.SourceStatus.DeepCopyInto(&out.SourceStatus)
+	if in.Partitions != nil {
+		in, out := &in.Partitions, &out.Partitions
+		*out = make([]MongoDbSourcePartition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Topology != nil {
+		in, out := &in.Topology, &out.Topology
+		*out = new(MongoDbSourceTopology)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
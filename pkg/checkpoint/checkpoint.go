@@ -0,0 +1,261 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package checkpoint persists the position of a receive adapter in the MongoDb change stream so
+// that it can resume where it left off after a restart.
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"knative.dev/pkg/kmeta"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+)
+
+const (
+	// DefaultKey is the ConfigMap key holding the checkpoint of a receive adapter.
+	DefaultKey = "checkpoint"
+
+	// LayoutKey is the ConfigMap key holding the Layout of the checkpoints of a partitioned source.
+	LayoutKey = "partitions"
+)
+
+// ConfigMapName returns the name of the ConfigMap holding the checkpoints of the receive adapter
+// of a MongoDbSource, which the controller creates and the receive adapters update.
+func ConfigMapName(src *v1alpha1.MongoDbSource) string {
+	return kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", src.Name), string(src.GetUID())+"-checkpoint")
+}
+
+// Checkpoint is the position of a receive adapter in the change stream.
+type Checkpoint struct {
+	// ResumeToken is the resume token of the last change acknowledged by the sink, as canonical
+	// extended JSON.
+	ResumeToken string `json:"resumeToken,omitempty"`
+
+	// OperationTime is the cluster time of the last change acknowledged by the sink. It is used
+	// to start the change stream when there is no resume token.
+	OperationTime *primitive.Timestamp `json:"operationTime,omitempty"`
+
+	// Snapshot is the progress of the snapshot being sent, if any. The change stream starts once
+	// it is done.
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+
+	// Poll are the high-water marks of the poll field in each polled collection, as canonical
+	// extended JSON documents {"v": <value>, "id": <_id>} of the last document read. The _id is
+	// left out when polling on _id. An empty mark polls the collection from the start.
+	Poll map[string]string `json:"poll,omitempty"`
+
+	// Rewind is the value of the rewind annotation of the source the checkpoint was made after,
+	// if any. Checkpoints made before a rewind are reset to the time of the rewind.
+	Rewind string `json:"rewind,omitempty"`
+
+	// HistoryLost records the last time the change stream could not be resumed, if any.
+	HistoryLost *HistoryLost `json:"historyLost,omitempty"`
+}
+
+// HistoryLost records that the change stream could not be resumed from a checkpoint because the
+// oplog no longer covered it.
+type HistoryLost struct {
+	// Time is when the loss was detected.
+	Time metav1.Time `json:"time"`
+
+	// Policy is the v1alpha1.HistoryLostPolicy that was applied.
+	Policy string `json:"policy"`
+
+	// Message is the error returned by MongoDb.
+	Message string `json:"message,omitempty"`
+}
+
+// Snapshot is the progress of a snapshot of the existing documents. Collections are sent in order
+// of their names and documents in order of their _id.
+type Snapshot struct {
+	// OperationTime is the cluster time at which the snapshot started, from which the change
+	// stream starts once the snapshot is done.
+	OperationTime primitive.Timestamp `json:"operationTime"`
+
+	// Collection is the collection being sent.
+	Collection string `json:"collection,omitempty"`
+
+	// LastID is the _id of the last document of Collection acknowledged by the sink, as a
+	// canonical extended JSON document.
+	LastID string `json:"lastId,omitempty"`
+}
+
+// NewResumeToken creates a Checkpoint out of the resume token of a change stream.
+func NewResumeToken(token bson.Raw) (*Checkpoint, error) {
+	ext, err := bson.MarshalExtJSON(token, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling resume token: %w", err)
+	}
+	return &Checkpoint{ResumeToken: string(ext)}, nil
+}
+
+// NewRewind creates the Checkpoint starting the change stream at the time of the given value of
+// the rewind annotation, an RFC3339 time.
+func NewRewind(rewind string) (*Checkpoint, error) {
+	t, err := time.Parse(time.RFC3339, rewind)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rewind time: %w", err)
+	}
+	return &Checkpoint{
+		OperationTime: &primitive.Timestamp{T: uint32(t.Unix())},
+		Rewind:        rewind,
+	}, nil
+}
+
+// Earliest returns a checkpoint from which all the given checkpoints can be resumed: the only one
+// given, or one at the earliest of their operation times. Resume tokens cannot be compared, so
+// checkpoints without an operation time are ignored. It returns nil if there is no such time. The
+// checkpoint returned follows the rewind the given checkpoints followed, if any.
+func Earliest(checkpoints []*Checkpoint) *Checkpoint {
+	if len(checkpoints) == 1 {
+		return checkpoints[0]
+	}
+	var earliest *primitive.Timestamp
+	var rewind string
+	for _, c := range checkpoints {
+		if c == nil {
+			continue
+		}
+		if c.Rewind != "" {
+			rewind = c.Rewind
+		}
+		operationTime := c.OperationTime
+		if c.Snapshot != nil {
+			// The rest of the snapshot is not sent again.
+			operationTime = &c.Snapshot.OperationTime
+		}
+		if operationTime == nil {
+			continue
+		}
+		if earliest == nil || primitive.CompareTimestamp(*operationTime, *earliest) < 0 {
+			earliest = operationTime
+		}
+	}
+	if earliest == nil {
+		return nil
+	}
+	return &Checkpoint{OperationTime: earliest, Rewind: rewind}
+}
+
+// Layout records the assignment of collections to partitions the checkpoints were made for.
+type Layout struct {
+	// Epoch is incremented whenever the collections are reassigned.
+	Epoch int `json:"epoch"`
+	// Collections are the collections of each partition.
+	Collections [][]string `json:"collections"`
+}
+
+// PartitionKey returns the ConfigMap key holding the checkpoint of a partition.
+func (l *Layout) PartitionKey(partition int) string {
+	return fmt.Sprintf("%s-%d-%d", DefaultKey, l.Epoch, partition)
+}
+
+// GetResumeToken returns the resume token of the checkpoint, or nil if there is none.
+func (c *Checkpoint) GetResumeToken() (bson.Raw, error) {
+	if c == nil || c.ResumeToken == "" {
+		return nil, nil
+	}
+	var token bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(c.ResumeToken), true, &token); err != nil {
+		return nil, fmt.Errorf("error unmarshalling resume token: %w", err)
+	}
+	return token, nil
+}
+
+// Store loads and saves checkpoints.
+type Store interface {
+	// Load returns the stored checkpoint, or nil if none was saved yet.
+	Load(ctx context.Context) (*Checkpoint, error)
+	// Save stores the checkpoint, replacing the previous one.
+	Save(ctx context.Context, checkpoint *Checkpoint) error
+}
+
+// configMapStore stores a checkpoint as JSON under a key of an existing ConfigMap.
+type configMapStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+	key        string
+}
+
+// Verify that it satisfies the Store interface.
+var _ Store = (*configMapStore)(nil)
+
+// NewConfigMapStore creates a Store backed by the key of the given ConfigMap. The ConfigMap is
+// expected to be created by the controller.
+func NewConfigMapStore(kubeClient kubernetes.Interface, namespace, name, key string) Store {
+	return &configMapStore{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		name:       name,
+		key:        key,
+	}
+}
+
+// Load implements Store.Load. The client calls take no context, so ctx is only checked before
+// getting the ConfigMap.
+func (s *configMapStore) Load(ctx context.Context) (*Checkpoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error loading checkpoint %q: %w", s.key, err)
+	}
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting checkpoint ConfigMap %s/%s: %w", s.namespace, s.name, err)
+	}
+	raw, found := cm.Data[s.key]
+	if !found || raw == "" {
+		return nil, nil
+	}
+	var checkpoint Checkpoint
+	if err := json.Unmarshal([]byte(raw), &checkpoint); err != nil {
+		return nil, fmt.Errorf("error unmarshalling checkpoint %q: %w", s.key, err)
+	}
+	return &checkpoint, nil
+}
+
+// Save implements Store.Save. The client calls take no context, so ctx is checked before each
+// attempt instead, and a cancelled ctx stops the retries on conflict.
+func (s *configMapStore) Save(ctx context.Context, checkpoint *Checkpoint) error {
+	raw, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("error marshalling checkpoint: %w", err)
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("error saving checkpoint %q: %w", s.key, err)
+		}
+		cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(s.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = make(map[string]string, 1)
+		}
+		cm.Data[s.key] = string(raw)
+		_, err = s.kubeClient.CoreV1().ConfigMaps(s.namespace).Update(cm)
+		return err
+	})
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+)
+
+const (
+	testNS = "testnamespace"
+	cmName = "checkpoint"
+)
+
+func TestResumeToken(t *testing.T) {
+	token, err := bson.Marshal(bson.M{"_data": "825F3A"})
+	if err != nil {
+		t.Fatalf("Failed to marshal token: %v", err)
+	}
+	checkpoint, err := NewResumeToken(token)
+	if err != nil {
+		t.Fatalf("NewResumeToken got error %v", err)
+	}
+	got, err := checkpoint.GetResumeToken()
+	if err != nil {
+		t.Fatalf("GetResumeToken got error %v", err)
+	}
+	if diff := cmp.Diff(bson.Raw(token), got); diff != "" {
+		t.Errorf("GetResumeToken got unexpected token (-want +got) %s", diff)
+	}
+
+	var empty *Checkpoint
+	if got, err := empty.GetResumeToken(); got != nil || err != nil {
+		t.Errorf("GetResumeToken of nil checkpoint got (%v, %v), want (nil, nil)", got, err)
+	}
+}
+
+func TestConfigMapStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    *Checkpoint
+		wantErr bool
+	}{
+		{
+			name:    "ConfigMap not found",
+			wantErr: true,
+		},
+		{
+			name: "no checkpoint saved",
+			objects: []runtime.Object{
+				newConfigMap(nil),
+			},
+		},
+		{
+			name: "invalid checkpoint",
+			objects: []runtime.Object{
+				newConfigMap(map[string]string{DefaultKey: "{"}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "checkpoint saved",
+			objects: []runtime.Object{
+				newConfigMap(map[string]string{DefaultKey: `{"resumeToken":"{\"_data\":\"825F3A\"}"}`}),
+			},
+			want: &Checkpoint{ResumeToken: `{"_data":"825F3A"}`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewConfigMapStore(fakekubeclientset.NewSimpleClientset(test.objects...), testNS, cmName, DefaultKey)
+			got, err := store.Load(context.Background())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load got error %v, want error=%v", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Load got unexpected checkpoint (-want +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigMapStoreSave(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := fakekubeclientset.NewSimpleClientset(newConfigMap(map[string]string{"other": "value"}))
+	store := NewConfigMapStore(kubeClient, testNS, cmName, DefaultKey)
+
+	want := &Checkpoint{ResumeToken: `{"_data":"825F3A"}`}
+	if err := store.Save(ctx, want); err != nil {
+		t.Fatalf("Save got error %v", err)
+	}
+	got, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load got error %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Load got unexpected checkpoint (-want +got) %s", diff)
+	}
+
+	cm, err := kubeClient.CoreV1().ConfigMaps(testNS).Get(cmName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get ConfigMap: %v", err)
+	}
+	if cm.Data["other"] != "value" {
+		t.Errorf("Save overwrote unrelated key, got %v", cm.Data)
+	}
+
+	missing := NewConfigMapStore(fakekubeclientset.NewSimpleClientset(), testNS, cmName, DefaultKey)
+	if err := missing.Save(ctx, want); err == nil {
+		t.Error("Save expected error when the ConfigMap does not exist")
+	}
+}
+
+func TestConfigMapStoreContext(t *testing.T) {
+	kubeClient := fakekubeclientset.NewSimpleClientset(newConfigMap(nil))
+	store := NewConfigMapStore(kubeClient, testNS, cmName, DefaultKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := 0
+	kubeClient.PrependReactor("update", "configmaps", func(clientgotesting.Action) (bool, runtime.Object, error) {
+		updates++
+		cancel()
+		return true, nil, apierrors.NewConflict(corev1.Resource("configmaps"), cmName, errors.New("conflict"))
+	})
+
+	if err := store.Save(ctx, &Checkpoint{ResumeToken: `{"_data":"825F3A"}`}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Save got error %v, want %v", err, context.Canceled)
+	}
+	if updates != 1 {
+		t.Errorf("Save got %d updates after the context was cancelled, want 1", updates)
+	}
+	if _, err := store.Load(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Load got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func newConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cmName,
+			Namespace: testNS,
+		},
+		Data: data,
+	}
+}
+
+func TestEarliest(t *testing.T) {
+	early := &primitive.Timestamp{T: 10, I: 2}
+	late := &primitive.Timestamp{T: 12, I: 1}
+	token := &Checkpoint{ResumeToken: `{"_data":"825F3A"}`}
+
+	tests := []struct {
+		name        string
+		checkpoints []*Checkpoint
+		want        *Checkpoint
+	}{
+		{
+			name: "no checkpoint",
+		},
+		{
+			name:        "single checkpoint",
+			checkpoints: []*Checkpoint{token},
+			want:        token,
+		},
+		{
+			name:        "earliest operation time",
+			checkpoints: []*Checkpoint{{OperationTime: late}, {ResumeToken: token.ResumeToken, OperationTime: early}},
+			want:        &Checkpoint{OperationTime: early},
+		},
+		{
+			name:        "checkpoints without operation time",
+			checkpoints: []*Checkpoint{token, {OperationTime: late}, nil},
+			want:        &Checkpoint{OperationTime: late},
+		},
+		{
+			name:        "snapshot in progress",
+			checkpoints: []*Checkpoint{{OperationTime: late}, {Snapshot: &Snapshot{OperationTime: *early, Collection: "coll"}}},
+			want:        &Checkpoint{OperationTime: early},
+		},
+		{
+			name:        "rewound checkpoints",
+			checkpoints: []*Checkpoint{{OperationTime: late, Rewind: "2020-08-01T00:00:00Z"}, {OperationTime: early, Rewind: "2020-08-01T00:00:00Z"}},
+			want:        &Checkpoint{OperationTime: early, Rewind: "2020-08-01T00:00:00Z"},
+		},
+		{
+			name:        "no operation time",
+			checkpoints: []*Checkpoint{token, token},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, Earliest(test.checkpoints)); diff != "" {
+				t.Errorf("Earliest got unexpected checkpoint (-want +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestNewRewind(t *testing.T) {
+	got, err := NewRewind("2020-08-01T10:00:00+02:00")
+	if err != nil {
+		t.Fatalf("NewRewind got error %v", err)
+	}
+	want := &Checkpoint{OperationTime: &primitive.Timestamp{T: 1596268800}, Rewind: "2020-08-01T10:00:00+02:00"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("NewRewind got unexpected checkpoint (-want +got) %s", diff)
+	}
+
+	if _, err := NewRewind("yesterday"); err == nil {
+		t.Error("NewRewind expected error for an invalid time")
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collection wraps mongo.Collection. It is the client that will be used everywhere except unit tests.
+type collection struct {
+	collection *mongo.Collection
+}
+
+// Verify that it satisfies the mongo.Collection interface.
+var _ Collection = &collection{}
+
+// Find implements mongo.Client.Database.Collection.Find.
+func (c *collection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (Cursor, error) {
+	cursor, err := c.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// Watch implements mongo.Client.Database.Collection.Watch.
+func (c *collection) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (ChangeStream, error) {
+	stream, err := c.collection.Watch(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// SetConnection applies the connection options of the source to the client options. The
+// application name defaults to appName when neither the source nor the connection string set it.
+func SetConnection(opts *options.ClientOptions, conn *v1alpha1.MongoDbSourceConnection, appName string) error {
+	if conn != nil && conn.AppName != "" {
+		opts.SetAppName(conn.AppName)
+	} else if opts.AppName == nil && appName != "" {
+		opts.SetAppName(appName)
+	}
+	if conn == nil {
+		return nil
+	}
+
+	if conn.ReadPreference != nil {
+		rp, err := ReadPreference(conn.ReadPreference)
+		if err != nil {
+			return err
+		}
+		opts.SetReadPreference(rp)
+	}
+	if conn.ReadConcern != "" {
+		opts.SetReadConcern(readconcern.New(readconcern.Level(conn.ReadConcern)))
+	}
+	if len(conn.Compressors) > 0 {
+		opts.SetCompressors(conn.Compressors)
+	}
+	if conn.ConnectTimeoutSeconds != nil {
+		opts.SetConnectTimeout(time.Duration(*conn.ConnectTimeoutSeconds) * time.Second)
+	}
+	if conn.ServerSelectionTimeoutSeconds != nil {
+		opts.SetServerSelectionTimeout(time.Duration(*conn.ServerSelectionTimeoutSeconds) * time.Second)
+	}
+	if conn.SocketTimeoutSeconds != nil {
+		opts.SetSocketTimeout(time.Duration(*conn.SocketTimeoutSeconds) * time.Second)
+	}
+	return nil
+}
+
+// ReadPreference returns the read preference of the source.
+func ReadPreference(rp *v1alpha1.MongoDbSourceReadPreference) (*readpref.ReadPref, error) {
+	mode, err := readpref.ModeFromString(rp.Mode)
+	if err != nil {
+		return nil, err
+	}
+	var rpOpts []readpref.Option
+	if len(rp.TagSets) > 0 {
+		rpOpts = append(rpOpts, readpref.WithTagSets(tag.NewTagSetsFromMaps(rp.TagSets)...))
+	}
+	if rp.MaxStalenessSeconds != nil {
+		rpOpts = append(rpOpts, readpref.WithMaxStaleness(time.Duration(*rp.MaxStalenessSeconds)*time.Second))
+	}
+	pref, err := readpref.New(mode, rpOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read preference: %w", err)
+	}
+	return pref, nil
+}
+
+// MaxAwaitTime returns how long the server waits for new changes before answering a fetch, or nil
+// to use the server default.
+func MaxAwaitTime(conn *v1alpha1.MongoDbSourceConnection) *time.Duration {
+	if conn == nil || conn.MaxAwaitTimeMilliseconds == nil {
+		return nil
+	}
+	d := time.Duration(*conn.MaxAwaitTimeMilliseconds) * time.Millisecond
+	return &d
+}
+
+// BatchSize returns the number of changes fetched at once, or nil to use the server default.
+func BatchSize(conn *v1alpha1.MongoDbSourceConnection) *int32 {
+	if conn == nil {
+		return nil
+	}
+	return conn.BatchSize
+}
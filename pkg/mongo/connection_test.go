@@ -0,0 +1,122 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestSetConnection(t *testing.T) {
+	batchSize := int32(10)
+	timeout := int64(5)
+	staleness := int64(120)
+
+	tests := []struct {
+		name        string
+		uri         string
+		conn        *v1alpha1.MongoDbSourceConnection
+		wantAppName string
+		wantMode    readpref.Mode
+		wantErr     bool
+	}{
+		{
+			name:        "default application name",
+			uri:         "mongodb://localhost",
+			wantAppName: "source",
+		},
+		{
+			name:        "application name of the connection string",
+			uri:         "mongodb://localhost/?appName=app",
+			wantAppName: "app",
+		},
+		{
+			name: "application name of the source",
+			uri:  "mongodb://localhost/?appName=app",
+			conn: &v1alpha1.MongoDbSourceConnection{
+				AppName:   "custom",
+				BatchSize: &batchSize,
+			},
+			wantAppName: "custom",
+		},
+		{
+			name: "all options",
+			uri:  "mongodb://localhost",
+			conn: &v1alpha1.MongoDbSourceConnection{
+				ReadPreference: &v1alpha1.MongoDbSourceReadPreference{
+					Mode:                "secondaryPreferred",
+					TagSets:             []map[string]string{{"region": "east"}},
+					MaxStalenessSeconds: &staleness,
+				},
+				ReadConcern:                   "majority",
+				Compressors:                   []string{"snappy", "zlib"},
+				ConnectTimeoutSeconds:         &timeout,
+				ServerSelectionTimeoutSeconds: &timeout,
+				SocketTimeoutSeconds:          &timeout,
+			},
+			wantAppName: "source",
+			wantMode:    readpref.SecondaryPreferredMode,
+		},
+		{
+			name: "primary read preference with tags",
+			uri:  "mongodb://localhost",
+			conn: &v1alpha1.MongoDbSourceConnection{
+				ReadPreference: &v1alpha1.MongoDbSourceReadPreference{
+					Mode:    "primary",
+					TagSets: []map[string]string{{"region": "east"}},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := options.Client().ApplyURI(test.uri)
+			err := SetConnection(opts, test.conn, "source")
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("SetConnection got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetConnection got error %v", err)
+			}
+			if opts.AppName == nil || *opts.AppName != test.wantAppName {
+				t.Errorf("Expected application name %q, got %v", test.wantAppName, opts.AppName)
+			}
+			if test.wantMode != 0 {
+				if opts.ReadPreference == nil || opts.ReadPreference.Mode() != test.wantMode {
+					t.Errorf("Expected read preference %v, got %v", test.wantMode, opts.ReadPreference)
+				}
+			}
+			if test.conn != nil && test.conn.ConnectTimeoutSeconds != nil {
+				want := time.Duration(*test.conn.ConnectTimeoutSeconds) * time.Second
+				if opts.ConnectTimeout == nil || *opts.ConnectTimeout != want {
+					t.Errorf("Expected connect timeout %v, got %v", want, opts.ConnectTimeout)
+				}
+			}
+			if err := opts.Validate(); err != nil {
+				t.Errorf("Invalid client options: %v", err)
+			}
+		})
+	}
+}
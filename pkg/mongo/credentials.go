@@ -0,0 +1,168 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultSecretKeys are the names of the keys of the MongoDb secret that are not configured.
+var DefaultSecretKeys = v1alpha1.MongoDbSourceSecretKeys{
+	URI:           "URI",
+	Host:          "host",
+	Hosts:         "hosts",
+	Username:      "username",
+	Password:      "password",
+	AuthSource:    "authSource",
+	AuthMechanism: "authMechanism",
+	ReplicaSet:    "replicaSet",
+	Options:       "options",
+}
+
+// SecretKeys returns the names of the keys of the MongoDb secret, with the defaults for the keys
+// that are not configured.
+func SecretKeys(keys *v1alpha1.MongoDbSourceSecretKeys) v1alpha1.MongoDbSourceSecretKeys {
+	k := DefaultSecretKeys
+	if keys == nil {
+		return k
+	}
+	for _, key := range []struct{ configured, name *string }{
+		{&keys.URI, &k.URI},
+		{&keys.Host, &k.Host},
+		{&keys.Hosts, &k.Hosts},
+		{&keys.Username, &k.Username},
+		{&keys.Password, &k.Password},
+		{&keys.AuthSource, &k.AuthSource},
+		{&keys.AuthMechanism, &k.AuthMechanism},
+		{&keys.ReplicaSet, &k.ReplicaSet},
+		{&keys.Options, &k.Options},
+	} {
+		if *key.configured != "" {
+			*key.name = *key.configured
+		}
+	}
+	return k
+}
+
+// ClientOptions returns the client options configured by the data of the MongoDb secret: its
+// connection string if it has one, else its hosts, credentials and other options.
+func ClientOptions(data map[string][]byte, keys *v1alpha1.MongoDbSourceSecretKeys) (*options.ClientOptions, error) {
+	k := SecretKeys(keys)
+	if uri, ok := data[k.URI]; ok {
+		return options.Client().ApplyURI(string(uri)), nil
+	}
+
+	var hosts []string
+	if host := secretValue(data, k.Host); host != "" {
+		hosts = append(hosts, host)
+	}
+	for _, host := range strings.Split(secretValue(data, k.Hosts), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("Unable to get MongoDb %s, %s or %s field", k.URI, k.Host, k.Hosts)
+	}
+	uri := "mongodb://" + strings.Join(hosts, ",") + "/"
+	if query := strings.TrimPrefix(secretValue(data, k.Options), "?"); query != "" {
+		uri += "?" + query
+	}
+	opts := options.Client().ApplyURI(uri)
+
+	if replicaSet := secretValue(data, k.ReplicaSet); replicaSet != "" {
+		opts.SetReplicaSet(replicaSet)
+	}
+	username, hasUsername := data[k.Username]
+	mechanism := secretValue(data, k.AuthMechanism)
+	if hasUsername || mechanism != "" {
+		password, hasPassword := data[k.Password]
+		opts.SetAuth(options.Credential{
+			AuthMechanism: mechanism,
+			AuthSource:    secretValue(data, k.AuthSource),
+			Username:      strings.TrimRight(string(username), "\r\n"),
+			Password:      strings.TrimRight(string(password), "\r\n"),
+			PasswordSet:   hasPassword,
+		})
+	}
+	return opts, nil
+}
+
+// ClusterName returns the name identifying the MongoDb deployment of the secret, which stays the
+// same across failovers and whatever the order of its hosts: the name of its replica set, known
+// from its topology or the secret, else the domain of its mongodb+srv connection string, else its
+// sorted hosts.
+func ClusterName(data map[string][]byte, keys *v1alpha1.MongoDbSourceSecretKeys, topology *v1alpha1.MongoDbSourceTopology) (string, error) {
+	if topology != nil && topology.ReplicaSet != "" {
+		return topology.ReplicaSet, nil
+	}
+	k := SecretKeys(keys)
+	if uri, ok := data[k.URI]; ok {
+		// The SRV record is not resolved, it names the cluster already.
+		if domain, ok := srvDomain(strings.TrimSpace(string(uri))); ok {
+			return domain, nil
+		}
+	}
+	opts, err := ClientOptions(data, keys)
+	if err != nil {
+		return "", err
+	}
+	if err := opts.Validate(); err != nil {
+		return "", fmt.Errorf("invalid MongoDb connection string: %w", err)
+	}
+	if opts.ReplicaSet != nil && *opts.ReplicaSet != "" {
+		return *opts.ReplicaSet, nil
+	}
+	if len(opts.Hosts) == 0 {
+		return "", errors.New("MongoDb credentials have no host")
+	}
+	hosts := make([]string, 0, len(opts.Hosts))
+	for _, host := range opts.Hosts {
+		hosts = append(hosts, strings.TrimSuffix(strings.ToLower(host), ":27017"))
+	}
+	sort.Strings(hosts)
+	return strings.Join(hosts, ","), nil
+}
+
+// srvDomain returns the domain of a mongodb+srv connection string, and whether it is one.
+func srvDomain(uri string) (string, bool) {
+	const scheme = "mongodb+srv://"
+	if len(uri) < len(scheme) || !strings.EqualFold(uri[:len(scheme)], scheme) {
+		return "", false
+	}
+	domain := uri[len(scheme):]
+	if i := strings.IndexAny(domain, "/?"); i >= 0 {
+		domain = domain[:i]
+	}
+	if i := strings.LastIndex(domain, "@"); i >= 0 {
+		domain = domain[i+1:]
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	return domain, domain != ""
+}
+
+// secretValue returns the value of a key of the secret without surrounding whitespace, which
+// secrets created from files often end with.
+func secretValue(data map[string][]byte, key string) string {
+	return strings.TrimSpace(string(data[key]))
+}
@@ -19,6 +19,7 @@ package mongodb
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -35,3 +36,24 @@ var _ Database = &database{}
 func (db *database) ListCollectionNames(ctx context.Context, filter interface{}, opts ...*options.ListCollectionsOptions) ([]string, error) {
 	return db.database.ListCollectionNames(ctx, filter, opts...)
 }
+
+// Collection implements mongo.Client.Database.Collection.
+func (db *database) Collection(name string, opts ...*options.CollectionOptions) Collection {
+	return &collection{
+		collection: db.database.Collection(name, opts...),
+	}
+}
+
+// RunCommand implements mongo.Client.Database.RunCommand, returning the raw result.
+func (db *database) RunCommand(ctx context.Context, runCommand interface{}, opts ...*options.RunCmdOptions) (bson.Raw, error) {
+	return db.database.RunCommand(ctx, runCommand, opts...).DecodeBytes()
+}
+
+// Watch implements mongo.Client.Database.Watch.
+func (db *database) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (ChangeStream, error) {
+	stream, err := db.database.Watch(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/auth"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Codes of the server errors.
+const (
+	unauthorizedCode         = 13
+	authenticationFailedCode = 18
+)
+
+// IsAuthenticationError returns whether err is a failure to authenticate to the server.
+func IsAuthenticationError(err error) bool {
+	var authErr *auth.Error
+	if errors.As(err, &authErr) {
+		return true
+	}
+	// Connection errors do not unwrap the errors of the handshake.
+	var connErr topology.ConnectionError
+	if errors.As(err, &connErr) && errors.As(connErr.Wrapped, &authErr) {
+		return true
+	}
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == authenticationFailedCode || cmdErr.Name == "AuthenticationFailed")
+}
+
+// IsUnauthorizedError returns whether err is a command refused because the user lacks the
+// privileges to run it.
+func IsUnauthorizedError(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == unauthorizedCode || cmdErr.Name == "Unauthorized")
+}
+
+// IsUnreachableError returns whether err is a failure to reach a server: a connection that could
+// not be opened, or no suitable server found in time.
+func IsUnreachableError(err error) bool {
+	if err == nil || IsAuthenticationError(err) {
+		return false
+	}
+	var connErr topology.ConnectionError
+	if errors.As(err, &connErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, topology.ErrServerSelectionTimeout) {
+		return true
+	}
+	// Server selection errors are not wrapped.
+	return strings.HasPrefix(err.Error(), "server selection error")
+}
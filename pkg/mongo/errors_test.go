@@ -0,0 +1,85 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/auth"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name                                 string
+		err                                  error
+		wantAuthentication, wantUnauthorized bool
+		wantUnreachable                      bool
+	}{
+		{
+			name: "other error",
+			err:  errors.New("boom"),
+		},
+		{
+			name:               "authentication failed during the handshake",
+			err:                topology.ConnectionError{Wrapped: &auth.Error{}},
+			wantAuthentication: true,
+		},
+		{
+			name:               "authentication failed command",
+			err:                fmt.Errorf("error listing databases: %w", mongo.CommandError{Code: 18, Name: "AuthenticationFailed"}),
+			wantAuthentication: true,
+		},
+		{
+			name:             "unauthorized command",
+			err:              mongo.CommandError{Code: 13, Name: "Unauthorized"},
+			wantUnauthorized: true,
+		},
+		{
+			name:            "connection refused",
+			err:             topology.ConnectionError{Wrapped: errors.New("connection refused")},
+			wantUnreachable: true,
+		},
+		{
+			name:            "server selection timeout",
+			err:             errors.New("server selection error: server selection timeout"),
+			wantUnreachable: true,
+		},
+		{
+			name:            "deadline exceeded",
+			err:             context.DeadlineExceeded,
+			wantUnreachable: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsAuthenticationError(test.err); got != test.wantAuthentication {
+				t.Errorf("IsAuthenticationError = %t, want %t", got, test.wantAuthentication)
+			}
+			if got := IsUnauthorizedError(test.err); got != test.wantUnauthorized {
+				t.Errorf("IsUnauthorizedError = %t, want %t", got, test.wantUnauthorized)
+			}
+			if got := IsUnreachableError(test.err); got != test.wantUnreachable {
+				t.Errorf("IsUnreachableError = %t, want %t", got, test.wantUnreachable)
+			}
+		})
+	}
+}
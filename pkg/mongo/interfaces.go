@@ -19,6 +19,7 @@ package mongodb
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -33,10 +34,30 @@ type Client interface {
 // Database matches the interface exposed by mongo.Database.
 type Database interface {
 	ListCollectionNames(ctx context.Context, filter interface{}, opts ...*options.ListCollectionsOptions) ([]string, error)
+	Collection(name string, opts ...*options.CollectionOptions) Collection
+	RunCommand(ctx context.Context, runCommand interface{}, opts ...*options.RunCmdOptions) (bson.Raw, error)
+	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (ChangeStream, error)
+}
+
+// Collection matches the interface exposed by mongo.Collection.
+type Collection interface {
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (Cursor, error)
+	Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (ChangeStream, error)
+}
+
+// Cursor matches the interface exposed by mongo.Cursor.
+type Cursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+	Close(ctx context.Context) error
 }
 
 // ChangeStream matches the interface exposed by mongo.ChangeStream.
 type ChangeStream interface {
 	Next(ctx context.Context) bool
 	Decode(val interface{}) error
+	ResumeToken() bson.Raw
+	Err() error
+	Close(ctx context.Context) error
 }
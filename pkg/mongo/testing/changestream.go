@@ -49,6 +49,19 @@ func (tCS *TestChangeStream) Next(ctx context.Context) bool {
 	return true
 }
 
+// ResumeToken implements mongo.Client.ChangeStream.ResumeToken.
+func (tCS *TestChangeStream) ResumeToken() bson.Raw {
+	id, found := tCS.Data.NewChange["_id"]
+	if !found {
+		return nil
+	}
+	token, err := bson.Marshal(id)
+	if err != nil {
+		return nil
+	}
+	return token
+}
+
 // Decode implements mongo.Client.ChangeStream.Decode.
 func (tCS *TestChangeStream) Decode(val interface{}) error {
 	if tCS.Data.DecodeErr != nil {
@@ -62,3 +75,13 @@ func (tCS *TestChangeStream) Decode(val interface{}) error {
 		return fmt.Errorf("unknown type %T", val)
 	}
 }
+
+// Err implements mongo.Client.ChangeStream.Err.
+func (tCS *TestChangeStream) Err() error {
+	return nil
+}
+
+// Close implements mongo.Client.ChangeStream.Close.
+func (tCS *TestChangeStream) Close(ctx context.Context) error {
+	return nil
+}
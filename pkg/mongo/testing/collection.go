@@ -0,0 +1,174 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	mongo "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testCollection wraps the fake mongo.Collection.
+type testCollection struct {
+	name string
+	data TestDbData
+}
+
+// Verify that it satisfies the mongo.Collection interface.
+var _ mongo.Collection = &testCollection{}
+
+// Find implements mongo.Client.Database.Collection.Find. The documents are expected to be sorted
+// by the queried field. The only filters supported are {field: {$gt: value}} and {field: {$lte:
+// value}} as first element, which respectively skip and keep the documents up to the one with that
+// value, and {$or: [..., {field: value, _id: {$gt: id}}]} as first element, which skips the
+// documents up to the one with that value and _id. Other filters are ignored. Sorting in descending
+// order and limits are supported, projections are ignored.
+func (tc *testCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (mongo.Cursor, error) {
+	if tc.data.FindErr != nil {
+		return nil, tc.data.FindErr
+	}
+	docs := tc.data.Documents[tc.name]
+	raw, err := bson.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	if elems, err := bson.Raw(raw).Elements(); err == nil && len(elems) > 0 && elems[0].Key() == "$or" {
+		if docs, err = skipUpToKey(docs, elems[0].Value()); err != nil {
+			return nil, err
+		}
+	} else if err == nil && len(elems) > 0 {
+		field := elems[0].Key()
+		operators, isDocument := elems[0].Value().DocumentOK()
+		for i, doc := range docs {
+			if !isDocument {
+				break
+			}
+			value, err := bson.Marshal(bson.M{field: doc[field]})
+			if err != nil {
+				return nil, err
+			}
+			if after, err := operators.LookupErr("$gt"); err == nil && bytes.Equal(bson.Raw(value).Lookup(field).Value, after.Value) {
+				docs = docs[i+1:]
+				break
+			}
+			if upTo, err := operators.LookupErr("$lte"); err == nil && bytes.Equal(bson.Raw(value).Lookup(field).Value, upTo.Value) {
+				docs = docs[:i+1]
+				break
+			}
+		}
+	}
+	opt := options.MergeFindOptions(opts...)
+	if sort, ok := opt.Sort.(bson.D); ok && len(sort) > 0 && sort[0].Value == -1 {
+		reversed := make([]bson.M, 0, len(docs))
+		for i := len(docs) - 1; i >= 0; i-- {
+			reversed = append(reversed, docs[i])
+		}
+		docs = reversed
+	}
+	if opt.Limit != nil && int(*opt.Limit) < len(docs) {
+		docs = docs[:*opt.Limit]
+	}
+	return &TestCursor{Docs: docs}, nil
+}
+
+// skipUpToKey skips the documents up to the one matched by the last branch {field: value, _id:
+// {$gt: id}} of an $or filter.
+func skipUpToKey(docs []bson.M, or bson.RawValue) ([]bson.M, error) {
+	branches, ok := or.ArrayOK()
+	if !ok {
+		return docs, nil
+	}
+	values, err := branches.Values()
+	if err != nil || len(values) == 0 {
+		return docs, err
+	}
+	branch, ok := values[len(values)-1].DocumentOK()
+	if !ok {
+		return docs, nil
+	}
+	elems, err := branch.Elements()
+	if err != nil || len(elems) != 2 {
+		return docs, err
+	}
+	field := elems[0].Key()
+	id, err := elems[1].Value().Document().LookupErr("$gt")
+	if err != nil {
+		return docs, nil
+	}
+	for i, doc := range docs {
+		key, err := bson.Marshal(bson.M{"v": doc[field], "id": doc["_id"]})
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(bson.Raw(key).Lookup("v").Value, elems[0].Value().Value) &&
+			bytes.Equal(bson.Raw(key).Lookup("id").Value, id.Value) {
+			return docs[i+1:], nil
+		}
+	}
+	return docs, nil
+}
+
+// Watch implements mongo.Client.Database.Collection.Watch.
+func (tc *testCollection) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (mongo.ChangeStream, error) {
+	if tc.data.WatchErr != nil {
+		return nil, tc.data.WatchErr
+	}
+	return &TestChangeStream{Data: tc.data.CSData}, nil
+}
+
+// TestCursor wraps the fake mongo.Cursor.
+type TestCursor struct {
+	Docs    []bson.M
+	current bson.M
+}
+
+// Verify that it satisfies the mongo.Cursor interface.
+var _ mongo.Cursor = &TestCursor{}
+
+// Next implements mongo.Client.Cursor.Next.
+func (c *TestCursor) Next(ctx context.Context) bool {
+	if len(c.Docs) == 0 {
+		return false
+	}
+	c.current, c.Docs = c.Docs[0], c.Docs[1:]
+	return true
+}
+
+// Decode implements mongo.Client.Cursor.Decode.
+func (c *TestCursor) Decode(val interface{}) error {
+	switch v := val.(type) {
+	case *bson.M:
+		*v = c.current
+		return nil
+	default:
+		return fmt.Errorf("unknown type %T", val)
+	}
+}
+
+// Err implements mongo.Client.Cursor.Err.
+func (c *TestCursor) Err() error {
+	return nil
+}
+
+// Close implements mongo.Client.Cursor.Close.
+func (c *TestCursor) Close(ctx context.Context) error {
+	return nil
+}
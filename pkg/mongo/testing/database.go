@@ -20,6 +20,7 @@ import (
 	"context"
 
 	mongo "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -30,8 +31,18 @@ type testDatabase struct {
 
 // TestDbData is the data used to configure the test MongoDb Database.
 type TestDbData struct {
-	ListCollErr error
-	Collections []string
+	ListCollErr      error
+	Collections      []string
+	RunCommandErr    error
+	RunCommandResult bson.M
+	// CommandResults are the results of the commands by name, RunCommandResult is the result of
+	// the other commands.
+	CommandResults map[string]bson.M
+	FindErr        error
+	// Documents are the documents of each collection, sorted by _id.
+	Documents map[string][]bson.M
+	WatchErr  error
+	CSData    TestCSData
 }
 
 // Verify that it satisfies the mongo.Database interface.
@@ -44,3 +55,32 @@ func (tdb *testDatabase) ListCollectionNames(ctx context.Context, filter interfa
 	}
 	return tdb.data.Collections, nil
 }
+
+// Collection implements mongo.Client.Database.Collection.
+func (tdb *testDatabase) Collection(name string, opts ...*options.CollectionOptions) mongo.Collection {
+	return &testCollection{
+		name: name,
+		data: tdb.data,
+	}
+}
+
+// RunCommand implements mongo.Client.Database.RunCommand.
+func (tdb *testDatabase) RunCommand(ctx context.Context, runCommand interface{}, opts ...*options.RunCmdOptions) (bson.Raw, error) {
+	if tdb.data.RunCommandErr != nil {
+		return nil, tdb.data.RunCommandErr
+	}
+	if cmd, ok := runCommand.(bson.D); ok && len(cmd) > 0 {
+		if result, ok := tdb.data.CommandResults[cmd[0].Key]; ok {
+			return bson.Marshal(result)
+		}
+	}
+	return bson.Marshal(tdb.data.RunCommandResult)
+}
+
+// Watch implements mongo.Client.Database.Watch.
+func (tdb *testDatabase) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (mongo.ChangeStream, error) {
+	if tdb.data.WatchErr != nil {
+		return nil, tdb.data.WatchErr
+	}
+	return &TestChangeStream{Data: tdb.data.CSData}, nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// X509AuthMechanism is the authentication mechanism using the client certificate.
+const X509AuthMechanism = "MONGODB-X509"
+
+// SetTLS enables TLS on the client options, trusting the PEM CA bundle, or the system CA bundle if
+// it is empty, and presenting the PEM client certificate and key if given.
+func SetTLS(opts *options.ClientOptions, ca, certificate, key []byte) error {
+	config := &tls.Config{}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return errors.New("no certificate found in the TLS CA bundle")
+		}
+		config.RootCAs = pool
+	}
+	if len(certificate) > 0 || len(key) > 0 {
+		pair, err := tls.X509KeyPair(certificate, key)
+		if err != nil {
+			return fmt.Errorf("invalid TLS client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	if opts.Auth != nil && opts.Auth.AuthMechanism == X509AuthMechanism && len(config.Certificates) == 0 {
+		return fmt.Errorf("%s authentication needs a TLS client certificate", X509AuthMechanism)
+	}
+	opts.SetTLSConfig(config)
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// selfSigned returns a PEM self-signed certificate and its PEM key.
+func selfSigned(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestSetTLS(t *testing.T) {
+	certificate, key := selfSigned(t)
+	x509Auth := &options.Credential{AuthMechanism: X509AuthMechanism}
+
+	tests := []struct {
+		name             string
+		auth             *options.Credential
+		ca, cert, key    []byte
+		wantRoots        bool
+		wantCertificates int
+		wantErr          bool
+	}{
+		{
+			name: "system CA bundle",
+		},
+		{
+			name:      "CA bundle",
+			ca:        certificate,
+			wantRoots: true,
+		},
+		{
+			name:             "X509 authentication",
+			auth:             x509Auth,
+			ca:               certificate,
+			cert:             certificate,
+			key:              key,
+			wantRoots:        true,
+			wantCertificates: 1,
+		},
+		{
+			name:    "X509 authentication without certificate",
+			auth:    x509Auth,
+			wantErr: true,
+		},
+		{
+			name:    "invalid CA bundle",
+			ca:      []byte("not a certificate"),
+			wantErr: true,
+		},
+		{
+			name:    "certificate without key",
+			cert:    certificate,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := options.Client()
+			if test.auth != nil {
+				opts.SetAuth(*test.auth)
+			}
+			err := SetTLS(opts, test.ca, test.cert, test.key)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("SetTLS got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetTLS got error %v", err)
+			}
+			if opts.TLSConfig == nil {
+				t.Fatalf("TLS is not enabled")
+			}
+			if got := opts.TLSConfig.RootCAs != nil; got != test.wantRoots {
+				t.Errorf("Expected custom CA bundle %t, got %t", test.wantRoots, got)
+			}
+			if got := len(opts.TLSConfig.Certificates); got != test.wantCertificates {
+				t.Errorf("Expected %d client certificates, got %d", test.wantCertificates, got)
+			}
+		})
+	}
+}
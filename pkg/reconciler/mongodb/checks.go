@@ -0,0 +1,243 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Defaults of the connection checks, overridden by the environment of the controller.
+const (
+	// defaultCheckCacheTTL is how long the successful result of a connection check is reused.
+	defaultCheckCacheTTL = 5 * time.Minute
+	// defaultCheckServerSelectionTimeout bounds the time to find a server during a check.
+	defaultCheckServerSelectionTimeout = 10 * time.Second
+	// defaultCheckTimeout bounds the time spent in the operations of a check.
+	defaultCheckTimeout = 30 * time.Second
+	// namespacePendingInterval is how often a source waiting for its database or collection to
+	// be created is checked again.
+	namespacePendingInterval = 30 * time.Second
+	// checkAppName is the application name of the clients of the checks, which are shared by the
+	// sources that do not set one.
+	checkAppName = "mongodbsource-controller"
+)
+
+// connectionChecks bounds the connection checks, caches their successful results and pools the
+// clients they use. A nil connectionChecks runs every check with a new client and the default
+// timeouts.
+type connectionChecks struct {
+	ttl                    time.Duration
+	serverSelectionTimeout time.Duration
+	timeout                time.Duration
+	// now returns the current time, it is replaced in unit tests.
+	now func() time.Time
+
+	// mu guards results and clients.
+	mu sync.Mutex
+	// results are the successful results of the checks of the sources.
+	results map[types.NamespacedName]checkResult
+	// clients are the connected clients, by configuration.
+	clients map[string]*pooledClient
+}
+
+// checkResult is the cached result of the connection check of a source.
+type checkResult struct {
+	// key identifies the spec of the source and the versions of its secrets when it was checked.
+	key         string
+	collections []string
+	topology    *v1alpha1.MongoDbSourceTopology
+	checked     time.Time
+	expires     time.Time
+}
+
+// pooledClient is a connected client shared by the checks of the sources with the same
+// configuration.
+type pooledClient struct {
+	client   mongoclient.Client
+	lastUsed time.Time
+	// inUse counts the checks using the client, which is disconnected once it is unused and
+	// dropped from the pool.
+	inUse   int
+	dropped bool
+}
+
+// newConnectionChecks creates a connectionChecks caching the results for ttl and bounding the
+// checks with the given timeouts.
+func newConnectionChecks(ttl, serverSelectionTimeout, timeout time.Duration) *connectionChecks {
+	return &connectionChecks{
+		ttl:                    ttl,
+		serverSelectionTimeout: serverSelectionTimeout,
+		timeout:                timeout,
+		now:                    time.Now,
+		results:                make(map[types.NamespacedName]checkResult),
+		clients:                make(map[string]*pooledClient),
+	}
+}
+
+// bound bounds the server selection of the client options and returns a context bounding the
+// operations of a check.
+func (c *connectionChecks) bound(ctx context.Context, opts *options.ClientOptions) (context.Context, context.CancelFunc) {
+	serverSelectionTimeout, timeout := defaultCheckServerSelectionTimeout, defaultCheckTimeout
+	if c != nil {
+		serverSelectionTimeout, timeout = c.serverSelectionTimeout, c.timeout
+	}
+	if opts.ServerSelectionTimeout == nil || *opts.ServerSelectionTimeout > serverSelectionTimeout {
+		opts.SetServerSelectionTimeout(serverSelectionTimeout)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// result returns the cached result of the check of src, unless it was made with another key, has
+// expired, or is at least maxAge old when maxAge is not 0.
+func (c *connectionChecks) result(src types.NamespacedName, key string, maxAge time.Duration) ([]string, *v1alpha1.MongoDbSourceTopology, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[src]
+	now := c.now()
+	if !ok || result.key != key || !now.Before(result.expires) || (maxAge > 0 && now.Sub(result.checked) >= maxAge) {
+		return nil, nil, false
+	}
+	return result.collections, result.topology.DeepCopy(), true
+}
+
+// store caches the successful result of the check of src made with key.
+func (c *connectionChecks) store(src types.NamespacedName, key string, collections []string, topology *v1alpha1.MongoDbSourceTopology) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for name, result := range c.results {
+		if !now.Before(result.expires) {
+			delete(c.results, name)
+		}
+	}
+	c.results[src] = checkResult{key: key, collections: collections, topology: topology.DeepCopy(), checked: now, expires: now.Add(c.ttl)}
+}
+
+// forget drops the cached result of the check of src.
+func (c *connectionChecks) forget(src types.NamespacedName) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.results, src)
+}
+
+// client returns a connected client for the configuration identified by key, creating it with
+// opts if it is not pooled yet. The returned function releases the client once the check is
+// done, dropping it from the pool if the client failed, see clientFailed.
+func (c *connectionChecks) client(ctx context.Context, key string, createFn mongoclient.CreateFn, opts *options.ClientOptions) (mongoclient.Client, func(failed bool), error) {
+	if c == nil {
+		client, err := connect(ctx, createFn, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func(bool) { disconnect(client) }, nil
+	}
+
+	pc, idle, err := c.acquire(ctx, key, createFn, opts)
+	for _, client := range idle {
+		disconnect(client)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return pc.client, func(failed bool) { c.release(key, pc, failed) }, nil
+}
+
+// clientFailed returns whether the error of a check is a failure of its client to reach or
+// authenticate to the servers, so that the client must not be reused. A missing database or
+// collection, or missing prerequisites, do not affect the client.
+func clientFailed(err error) bool {
+	return mongoclient.IsUnreachableError(err) || mongoclient.IsAuthenticationError(err) || mongoclient.IsUnauthorizedError(err)
+}
+
+// acquire returns the pooled client for key, creating it if needed, and drops the clients that
+// have not been used for longer than the TTL, which it returns to be disconnected.
+func (c *connectionChecks) acquire(ctx context.Context, key string, createFn mongoclient.CreateFn, opts *options.ClientOptions) (*pooledClient, []mongoclient.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	var idle []mongoclient.Client
+	for k, pc := range c.clients {
+		if k != key && pc.inUse == 0 && now.Sub(pc.lastUsed) >= c.ttl {
+			delete(c.clients, k)
+			idle = append(idle, pc.client)
+		}
+	}
+	pc, ok := c.clients[key]
+	if !ok {
+		// Connecting does not wait for the servers, which are only selected by the operations.
+		client, err := connect(ctx, createFn, opts)
+		if err != nil {
+			return nil, idle, err
+		}
+		pc = &pooledClient{client: client}
+		c.clients[key] = pc
+	}
+	pc.inUse++
+	pc.lastUsed = now
+	return pc, idle, nil
+}
+
+// release releases a pooled client, dropping it from the pool if failed, and disconnects it once
+// it is dropped and no longer used.
+func (c *connectionChecks) release(key string, pc *pooledClient, failed bool) {
+	c.mu.Lock()
+	pc.inUse--
+	if failed && c.clients[key] == pc {
+		delete(c.clients, key)
+		pc.dropped = true
+	}
+	unused := pc.dropped && pc.inUse == 0
+	c.mu.Unlock()
+	if unused {
+		disconnect(pc.client)
+	}
+}
+
+// connect creates a client with opts and connects it.
+func connect(ctx context.Context, createFn mongoclient.CreateFn, opts *options.ClientOptions) (mongoclient.Client, error) {
+	client, err := createFn(opts)
+	if err != nil {
+		return nil, connectionFailure(reasonInvalidURI, "Unable to create the MongoDb client", err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		return nil, connectionFailure(reasonServerUnreachable, "Unable to connect to MongoDb", err)
+	}
+	return client, nil
+}
+
+// disconnect disconnects a client, bounding the time spent closing its connections.
+func disconnect(client mongoclient.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCheckServerSelectionTimeout)
+	defer cancel()
+	client.Disconnect(ctx)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestConnectionChecksResults(t *testing.T) {
+	now := time.Now()
+	c := newConnectionChecks(time.Minute, time.Second, time.Second)
+	c.now = func() time.Time { return now }
+	src := types.NamespacedName{Namespace: testNS, Name: sourceName}
+
+	if _, _, ok := c.result(src, "key", 0); ok {
+		t.Errorf("Expected no cached result before the first check")
+	}
+	c.store(src, "key", []string{coll}, nil)
+	if got, _, ok := c.result(src, "key", 0); !ok || len(got) != 1 || got[0] != coll {
+		t.Errorf("Expected cached collections [%s], got %v, %t", coll, got, ok)
+	}
+	if _, _, ok := c.result(src, "other", 0); ok {
+		t.Errorf("Expected no cached result for another key")
+	}
+
+	// A resync shorter than the TTL runs the check again.
+	now = now.Add(30 * time.Second)
+	if _, _, ok := c.result(src, "key", 0); !ok {
+		t.Errorf("Expected a cached result before it expires")
+	}
+	if _, _, ok := c.result(src, "key", 30*time.Second); ok {
+		t.Errorf("Expected no cached result as old as the resync interval")
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, _, ok := c.result(src, "key", 0); ok {
+		t.Errorf("Expected no cached result once expired")
+	}
+
+	c.store(src, "key", nil, nil)
+	c.forget(src)
+	if _, _, ok := c.result(src, "key", 0); ok {
+		t.Errorf("Expected no cached result once forgotten")
+	}
+}
+
+func TestConnectionChecksClients(t *testing.T) {
+	now := time.Now()
+	c := newConnectionChecks(time.Minute, time.Second, time.Second)
+	c.now = func() time.Time { return now }
+	created := 0
+	createFn := func(opts ...*options.ClientOptions) (mongoclient.Client, error) {
+		created++
+		return mongotesting.TestClientCreator(nil)(opts...)
+	}
+	ctx := context.Background()
+	acquire := func(key string) func(bool) {
+		t.Helper()
+		_, release, err := c.client(ctx, key, createFn, options.Client())
+		if err != nil {
+			t.Fatalf("client got error %v", err)
+		}
+		return release
+	}
+
+	// The checks of the same configuration share the client.
+	acquire("a")(false)
+	acquire("a")(false)
+	if created != 1 {
+		t.Errorf("Expected 1 client, got %d", created)
+	}
+
+	// A failed check drops the client.
+	acquire("a")(true)
+	acquire("a")(false)
+	if created != 2 {
+		t.Errorf("Expected a new client after a failure, got %d clients", created)
+	}
+
+	// The idle clients are dropped after the TTL.
+	acquire("b")(false)
+	now = now.Add(time.Minute)
+	acquire("c")(false)
+	if _, ok := c.clients["a"]; ok {
+		t.Errorf("Expected the idle client to be dropped")
+	}
+	if len(c.clients) != 1 {
+		t.Errorf("Expected 1 pooled client, got %d", len(c.clients))
+	}
+}
+
+func TestClientFailed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error"},
+		{
+			name: "collection not found",
+			err:  connectionFailure(reasonCollectionNotFound, "The collection was not found", nil),
+		},
+		{
+			name: "prerequisites missing",
+			err:  errors.New("the server does not support change streams"),
+		},
+		{
+			name: "unreachable",
+			err:  fmt.Errorf("error listing databases: %w", context.DeadlineExceeded),
+			want: true,
+		},
+		{
+			name: "authentication failed",
+			err:  operationFailure("Unable to list databases", mongo.CommandError{Code: 18, Name: "AuthenticationFailed"}),
+			want: true,
+		},
+		{
+			name: "unauthorized",
+			err:  operationFailure("Unable to list databases", mongo.CommandError{Code: 13, Name: "Unauthorized"}),
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := clientFailed(test.err); got != test.want {
+				t.Errorf("clientFailed got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
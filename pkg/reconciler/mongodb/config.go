@@ -0,0 +1,165 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+)
+
+// Constants of the controller ConfigMap.
+const (
+	// configName is the name of the ConfigMap configuring the controller.
+	configName = "config-mongodb-source"
+	// resyncIntervalKey is the key of the interval after which the sources are checked again,
+	// "0" disables the resync.
+	resyncIntervalKey = "resyncInterval"
+	// adapterDefaultsKey is the key of the cluster-wide defaults of the pod template of the
+	// receive adapters, in YAML, with the fields of the adapter field of the sources.
+	adapterDefaultsKey = "adapterDefaults"
+
+	// defaultResyncInterval is the resync interval when the ConfigMap does not set it.
+	defaultResyncInterval = 10 * time.Minute
+	// resyncJitter is the maximum fraction of the interval added to spread the resyncs.
+	resyncJitter = 0.2
+)
+
+// resync holds the interval after which the sources are reconciled again, so that their connection
+// and prerequisites are checked even when nothing they watch changes. A nil resync never resyncs.
+type resync struct {
+	// mu guards interval.
+	mu       sync.RWMutex
+	interval time.Duration
+}
+
+// newResync creates a resync with the default interval.
+func newResync() *resync {
+	return &resync{interval: defaultResyncInterval}
+}
+
+// resyncIntervalFromConfigMap returns the resync interval set by the controller ConfigMap.
+func resyncIntervalFromConfigMap(cm *corev1.ConfigMap) (time.Duration, error) {
+	value, ok := cm.Data[resyncIntervalKey]
+	if !ok {
+		return defaultResyncInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", resyncIntervalKey, value, err)
+	}
+	if interval < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", resyncIntervalKey, value)
+	}
+	return interval, nil
+}
+
+// update updates the interval from the controller ConfigMap, and keeps the current one if the
+// ConfigMap is invalid.
+func (r *resync) update(cm *corev1.ConfigMap) error {
+	interval, err := resyncIntervalFromConfigMap(cm)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interval = interval
+	return nil
+}
+
+// current returns the resync interval, or 0 if the resync is disabled.
+func (r *resync) current() time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.interval
+}
+
+// next returns the delay before the next resync of a source, with a jitter spreading the resyncs
+// of the sources reconciled together, or 0 if the resync is disabled.
+func (r *resync) next() time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.interval == 0 {
+		return 0
+	}
+	return wait.Jitter(r.interval, resyncJitter)
+}
+
+// adapterDefaults holds the cluster-wide defaults of the pod template of the receive adapters.
+type adapterDefaults struct {
+	// mu guards adapter.
+	mu      sync.RWMutex
+	adapter *v1alpha1.MongoDbSourceAdapter
+}
+
+// adapterDefaultsFromConfigMap returns the receive adapter defaults set by the controller
+// ConfigMap, or nil if it sets none.
+func adapterDefaultsFromConfigMap(cm *corev1.ConfigMap) (*v1alpha1.MongoDbSourceAdapter, error) {
+	value, ok := cm.Data[adapterDefaultsKey]
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	adapter := &v1alpha1.MongoDbSourceAdapter{}
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(value), len(value)).Decode(adapter); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", adapterDefaultsKey, err)
+	}
+	if err := adapter.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", adapterDefaultsKey, err)
+	}
+	return adapter, nil
+}
+
+// update updates the defaults from the controller ConfigMap, and keeps the current ones if the
+// ConfigMap is invalid. It returns whether the defaults changed.
+func (d *adapterDefaults) update(cm *corev1.ConfigMap) (bool, error) {
+	adapter, err := adapterDefaultsFromConfigMap(cm)
+	if err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if equality.Semantic.DeepEqual(d.adapter, adapter) {
+		return false, nil
+	}
+	d.adapter = adapter
+	return true, nil
+}
+
+// get returns a copy of the defaults, or nil if there are none or d is nil.
+func (d *adapterDefaults) get() *v1alpha1.MongoDbSourceAdapter {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.adapter.DeepCopy()
+}
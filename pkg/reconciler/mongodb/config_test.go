@@ -0,0 +1,165 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+)
+
+func TestResyncIntervalFromConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    time.Duration
+		wantErr bool
+	}{{
+		name: "default",
+		want: defaultResyncInterval,
+	}, {
+		name: "interval",
+		data: map[string]string{resyncIntervalKey: "1h"},
+		want: time.Hour,
+	}, {
+		name: "disabled",
+		data: map[string]string{resyncIntervalKey: "0"},
+		want: 0,
+	}, {
+		name:    "invalid",
+		data:    map[string]string{resyncIntervalKey: "often"},
+		wantErr: true,
+	}, {
+		name:    "negative",
+		data:    map[string]string{resyncIntervalKey: "-1m"},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resyncIntervalFromConfigMap(&corev1.ConfigMap{Data: test.data})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("resyncIntervalFromConfigMap got error %v, want error %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("resyncIntervalFromConfigMap got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResyncNext(t *testing.T) {
+	var disabled *resync
+	if got := disabled.next(); got != 0 {
+		t.Errorf("nil resync next got %v, want 0", got)
+	}
+
+	r := newResync()
+	if err := r.update(&corev1.ConfigMap{Data: map[string]string{resyncIntervalKey: "1m"}}); err != nil {
+		t.Fatalf("update got error %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if got := r.next(); got < time.Minute || got > time.Minute+time.Duration(resyncJitter*float64(time.Minute)) {
+			t.Errorf("next got %v, want between 1m and 1m12s", got)
+		}
+	}
+	if got := r.current(); got != time.Minute {
+		t.Errorf("current got %v, want 1m", got)
+	}
+
+	// An invalid ConfigMap keeps the interval.
+	if err := r.update(&corev1.ConfigMap{Data: map[string]string{resyncIntervalKey: "often"}}); err == nil {
+		t.Error("update got no error")
+	}
+	if got := r.next(); got < time.Minute {
+		t.Errorf("next got %v after an invalid update, want at least 1m", got)
+	}
+	if err := r.update(&corev1.ConfigMap{Data: map[string]string{resyncIntervalKey: "0"}}); err != nil {
+		t.Fatalf("update got error %v", err)
+	}
+	if got := r.next(); got != 0 {
+		t.Errorf("disabled resync next got %v, want 0", got)
+	}
+}
+
+func TestAdapterDefaultsFromConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *v1alpha1.MongoDbSourceAdapter
+		wantErr bool
+	}{{
+		name: "no defaults",
+	}, {
+		name: "defaults",
+		data: map[string]string{adapterDefaultsKey: `
+nodeSelector:
+  kubernetes.io/os: linux
+priorityClassName: sources
+`},
+		want: &v1alpha1.MongoDbSourceAdapter{
+			NodeSelector:      map[string]string{"kubernetes.io/os": "linux"},
+			PriorityClassName: "sources",
+		},
+	}, {
+		name:    "invalid yaml",
+		data:    map[string]string{adapterDefaultsKey: "nodeSelector: [linux"},
+		wantErr: true,
+	}, {
+		name:    "invalid defaults",
+		data:    map[string]string{adapterDefaultsKey: "priorityClassName: Sources_"},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := adapterDefaultsFromConfigMap(&corev1.ConfigMap{Data: test.data})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("adapterDefaultsFromConfigMap got error %v, want error %t", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("adapterDefaultsFromConfigMap (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestAdapterDefaultsUpdate(t *testing.T) {
+	var disabled *adapterDefaults
+	if got := disabled.get(); got != nil {
+		t.Errorf("nil adapterDefaults get got %v, want nil", got)
+	}
+
+	d := &adapterDefaults{}
+	cm := &corev1.ConfigMap{Data: map[string]string{adapterDefaultsKey: "priorityClassName: sources"}}
+	if changed, err := d.update(cm); err != nil || !changed {
+		t.Fatalf("update got %t, %v, want true, nil", changed, err)
+	}
+	if changed, err := d.update(cm); err != nil || changed {
+		t.Errorf("update of the same defaults got %t, %v, want false, nil", changed, err)
+	}
+
+	// An invalid ConfigMap keeps the defaults.
+	if _, err := d.update(&corev1.ConfigMap{Data: map[string]string{adapterDefaultsKey: "priorityClassName: Sources_"}}); err == nil {
+		t.Error("update got no error")
+	}
+	if got := d.get(); got == nil || got.PriorityClassName != "sources" {
+		t.Errorf("get got %v after an invalid update, want the previous defaults", got)
+	}
+}
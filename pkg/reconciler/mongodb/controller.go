@@ -19,6 +19,7 @@ package mongodb
 import (
 	"context"
 	"os"
+	"time"
 
 	mongowrapper "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
 	deploymentinformer "knative.dev/pkg/client/injection/kube/informers/apps/v1/deployment"
@@ -26,14 +27,21 @@ import (
 	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
 	"github.com/googleinterns/knative-source-mongodb/pkg/client/injection/informers/sources/v1alpha1/mongodbsource"
 	v1alpha1mongodbsource "github.com/googleinterns/knative-source-mongodb/pkg/client/injection/reconciler/sources/v1alpha1/mongodbsource"
+	"github.com/googleinterns/knative-source-mongodb/pkg/reconciler/mongodb/resources"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
 	reconcilersource "knative.dev/eventing/pkg/reconciler/source"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	configmapinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap"
 	secretinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/secret"
+	roleinformer "knative.dev/pkg/client/injection/kube/informers/rbac/v1/role"
+	rolebindinginformer "knative.dev/pkg/client/injection/kube/informers/rbac/v1/rolebinding"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/resolver"
+	"knative.dev/pkg/tracker"
 )
 
 // Declare Constants.
@@ -42,6 +50,13 @@ const (
 	// image. It must be defined.
 	raImageEnvVar = "MONGODB_RA_IMAGE"
 
+	// checkCacheTTLEnvVar, checkServerSelectionTimeoutEnvVar and checkTimeoutEnvVar are the names
+	// of the environment variables that contain the durations configuring the connection checks.
+	// They are optional.
+	checkCacheTTLEnvVar               = "MONGODB_CHECK_CACHE_TTL"
+	checkServerSelectionTimeoutEnvVar = "MONGODB_CHECK_SERVER_SELECTION_TIMEOUT"
+	checkTimeoutEnvVar                = "MONGODB_CHECK_TIMEOUT"
+
 	component = "mongodbsource"
 )
 
@@ -56,6 +71,9 @@ func NewController(
 	deploymentInformer := deploymentinformer.Get(ctx)
 	mongodbsourceInformer := mongodbsource.Get(ctx)
 	secretInformer := secretinformer.Get(ctx)
+	configMapInformer := configmapinformer.Get(ctx)
+	roleInformer := roleinformer.Get(ctx)
+	roleBindingInformer := rolebindinginformer.Get(ctx)
 
 	raImage, defined := os.LookupEnv(raImageEnvVar)
 	if !defined {
@@ -63,17 +81,60 @@ func NewController(
 		return nil
 	}
 
+	var durations [3]time.Duration
+	for i, env := range []struct {
+		name         string
+		defaultValue time.Duration
+	}{
+		{checkCacheTTLEnvVar, defaultCheckCacheTTL},
+		{checkServerSelectionTimeoutEnvVar, defaultCheckServerSelectionTimeout},
+		{checkTimeoutEnvVar, defaultCheckTimeout},
+	} {
+		durations[i] = env.defaultValue
+		if value, ok := os.LookupEnv(env.name); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				logging.FromContext(ctx).Errorf("invalid duration %q in environment variable %q: %v", value, env.name, err)
+				return nil
+			}
+			durations[i] = d
+		}
+	}
+
 	r := &Reconciler{
 		receiveAdapterImage: raImage,
 		kubeClientSet:       kubeclient.Get(ctx),
 		secretLister:        secretInformer.Lister(),
 		deploymentLister:    deploymentInformer.Lister(),
+		configMapLister:     configMapInformer.Lister(),
+		roleLister:          roleInformer.Lister(),
+		roleBindingLister:   roleBindingInformer.Lister(),
 		configs:             reconcilersource.WatchConfigurations(ctx, component, cmw),
+		checks:              newConnectionChecks(durations[0], durations[1], durations[2]),
+		resync:              newResync(),
+		adapterDefaults:     &adapterDefaults{},
 		createClientFn:      mongowrapper.NewClient,
 	}
 	impl := v1alpha1mongodbsource.NewImpl(ctx, r)
+	r.enqueueAfter = impl.EnqueueKeyAfter
+
+	// Watch the interval of the resyncs and the receive adapter defaults, which change the
+	// receive adapters of all the sources.
+	cmw.Watch(configName, func(cm *corev1.ConfigMap) {
+		if err := r.resync.update(cm); err != nil {
+			logger.Errorw("Invalid controller ConfigMap, keeping the current resync interval", zap.Error(err))
+		}
+		changed, err := r.adapterDefaults.update(cm)
+		if err != nil {
+			logger.Errorw("Invalid controller ConfigMap, keeping the current receive adapter defaults", zap.Error(err))
+		}
+		if changed {
+			impl.GlobalResync(mongodbsourceInformer.Informer())
+		}
+	})
 
 	r.sinkResolver = resolver.NewURIResolver(ctx, impl.EnqueueKey)
+	r.tracker = tracker.New(impl.EnqueueKey, controller.GetTrackerLease(ctx))
 
 	mongoGK := v1alpha1.Kind("MongoDbSource")
 
@@ -84,7 +145,33 @@ func NewController(
 		FilterFunc: controller.FilterControllerGK(mongoGK),
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
+	// The credentials secrets are not owned by the sources referencing them.
+	secretInformer.Informer().AddEventHandler(controller.HandleAll(
+		controller.EnsureTypeMeta(r.tracker.OnChanged, corev1.SchemeGroupVersion.WithKind("Secret")),
+	))
+	deploymentInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterControllerGK(mongoGK),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+	// The sources watched by a multi-tenant receive adapter report its availability.
 	deploymentInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterWithName(resources.MultiTenantAdapterName),
+		Handler: controller.HandleAll(func(interface{}) {
+			impl.FilteredGlobalResync(func(obj interface{}) bool {
+				src, ok := obj.(*v1alpha1.MongoDbSource)
+				return ok && src.Scope() != v1alpha1.ScopeResource
+			}, mongodbsourceInformer.Informer())
+		}),
+	})
+	configMapInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterControllerGK(mongoGK),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+	roleInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterControllerGK(mongoGK),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+	roleBindingInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 		FilterFunc: controller.FilterControllerGK(mongoGK),
 		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 	})
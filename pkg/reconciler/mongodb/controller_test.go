@@ -33,7 +33,10 @@ import (
 	_ "github.com/googleinterns/knative-source-mongodb/pkg/reconciler/testing"
 	_ "knative.dev/eventing/pkg/reconciler/source"
 	_ "knative.dev/pkg/client/injection/kube/informers/apps/v1/deployment/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/secret/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/rbac/v1/role/fake"
+	_ "knative.dev/pkg/client/injection/kube/informers/rbac/v1/rolebinding/fake"
 )
 
 func TestNew(t *testing.T) {
@@ -68,6 +71,14 @@ func TestNew(t *testing.T) {
 		Data: map[string]string{
 			"_example": "test-config",
 		},
+	}, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config-mongodb-source",
+			Namespace: "google-sources",
+		},
+		Data: map[string]string{
+			"resyncInterval": "5m",
+		},
 	})
 
 	c := NewController(ctx, cmw)
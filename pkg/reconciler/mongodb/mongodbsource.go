@@ -18,33 +18,57 @@ package mongodb
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"net/url"
+	"time"
 
 	"go.uber.org/zap"
 	"knative.dev/pkg/logging"
 
 	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
 	"github.com/googleinterns/knative-source-mongodb/pkg/client/injection/reconciler/sources/v1alpha1/mongodbsource"
 	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
 	"github.com/googleinterns/knative-source-mongodb/pkg/reconciler/mongodb/resources"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	appsv1listers "k8s.io/client-go/listers/apps/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
+	rbacv1listers "k8s.io/client-go/listers/rbac/v1"
 	reconcilersource "knative.dev/eventing/pkg/reconciler/source"
 	"knative.dev/pkg/apis"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/reconciler"
 	"knative.dev/pkg/resolver"
+	"knative.dev/pkg/system"
+	"knative.dev/pkg/tracker"
 )
 
+// errSecretKeyMissing is returned when a key referenced by the source is missing from its secret.
+var errSecretKeyMissing = errors.New("missing secret key")
+
+// Reasons of the events sent when a source is suspended or resumed.
+const (
+	reasonSuspended = "Suspended"
+	reasonResumed   = "Resumed"
+)
+
+// reasonReceiveAdaptersStopping is the reason of the event sent when a deleted source waits for
+// its receive adapters to stop before it is finalized.
+const reasonReceiveAdaptersStopping = "ReceiveAdaptersStopping"
+
 // Reconciler implements controller.Reconciler for MongoDbSource resources.
 type Reconciler struct {
 	receiveAdapterImage string `envconfig:"MONGODB_RA_IMAGE" required:"true"`
@@ -52,11 +76,27 @@ type Reconciler struct {
 	sinkResolver        *resolver.URIResolver
 
 	// Lister
-	deploymentLister appsv1listers.DeploymentLister
-	secretLister     corev1listers.SecretLister
+	deploymentLister  appsv1listers.DeploymentLister
+	secretLister      corev1listers.SecretLister
+	configMapLister   corev1listers.ConfigMapLister
+	roleLister        rbacv1listers.RoleLister
+	roleBindingLister rbacv1listers.RoleBindingLister
 
 	configs reconcilersource.ConfigAccessor
 
+	// tracker tracks the secrets referenced by the sources, which they do not own.
+	tracker tracker.Interface
+
+	// checks bounds the connection checks and caches their results.
+	checks *connectionChecks
+
+	// enqueueAfter reconciles a source again after a delay.
+	enqueueAfter func(key types.NamespacedName, delay time.Duration)
+	// resync is the interval after which the sources are checked again.
+	resync *resync
+	// adapterDefaults are the cluster-wide defaults of the pod template of the receive adapters.
+	adapterDefaults *adapterDefaults
+
 	// createClientFn is the function used to create the Mongo client that interacts with the database.
 	// This is needed so that we can inject a mock client for UTs purposes.
 	createClientFn mongoclient.CreateFn
@@ -65,12 +105,33 @@ type Reconciler struct {
 // Check that our Reconciler implements Interface
 var _ mongodbsource.Interface = (*Reconciler)(nil)
 
+// Check that our Reconciler implements Finalizer
+var _ mongodbsource.Finalizer = (*Reconciler)(nil)
+
 // ReconcileKind implements Interface.ReconcileKind.
 func (r *Reconciler) ReconcileKind(ctx context.Context, src *v1alpha1.MongoDbSource) reconciler.Event {
 	// Steps:
 	// 1. Resolve the sink.
-	// 2. Ensure it can connect to the DB with the specified credentials, and that the DB and collection exists.
-	// 3. Reconcile the receive adapter.
+	// 2. Ensure it can connect to the DB with the specified credentials, that the DB and collection
+	//    exists, and that the server and user meet the prerequisites of the source.
+	// 3. Reconcile the checkpoint ConfigMap, rebalancing the partitions if any, and the permissions
+	//    of the receive adapter.
+	// 4. Reconcile the receive adapter of each partition.
+	// 5. Check the source again after the resync interval, to notice a dropped database or a
+	//    revoked user even when nothing the controller watches changes.
+	// A suspended source skips them all, and only stops its receive adapters.
+
+	// Stop the receive adapters of a suspended source, keeping its checkpoints. The sink and the
+	// database are not checked, they may be under maintenance.
+	if src.Spec.Suspend {
+		if err := r.suspendReceiveAdapters(ctx, src); err != nil {
+			logging.FromContext(ctx).Desugar().Error("Failed to suspend receive adapters", zap.Error(err))
+			return err
+		}
+		markSuspended(ctx, src)
+		return nil
+	}
+	markResumed(ctx, src)
 
 	// Resolve the specified sink.
 	sinkURI, err := r.resolveSink(ctx, src)
@@ -80,81 +141,521 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, src *v1alpha1.MongoDbSou
 	}
 	src.Status.MarkSink(sinkURI)
 
-	// Check that we can connect to the DB.
-	err = r.checkConnection(ctx, src)
+	// Check that we can connect to the DB, and report the deployment found.
+	collections, topology, err := r.checkConnection(ctx, src)
+	if topology != nil {
+		src.Status.Topology = topology
+	}
+	var prerequisitesErr *prerequisitesError
+	if errors.As(err, &prerequisitesErr) {
+		markConnectionSuccess(ctx, src)
+		markChangeStreamNotSupported(ctx, src, prerequisitesErr.Error())
+		return err
+	} else if err != nil {
+		reason, message := failureReason(err)
+		markConnectionFailed(ctx, src, reason, message)
+		return err
+	}
+	markConnectionSuccess(ctx, src)
+	markChangeStreamSupported(ctx, src)
+
+	// Check again later for the database or collection not created yet.
+	key := types.NamespacedName{Namespace: src.Namespace, Name: src.Name}
+	if reason, message := pendingNamespace(src, topology); reason != "" {
+		src.Status.MarkNamespacePending(reason, "%s", message)
+		r.enqueueAfter(key, namespacePendingInterval)
+	} else {
+		src.Status.MarkNamespaceExists()
+	}
+
+	// Reconcile the checkpoint store and its permissions.
+	layout, err := r.reconcileCheckpoint(ctx, src, resources.AssignCollections(src, collections))
 	if err != nil {
-		src.Status.MarkConnectionFailed(err)
+		logging.FromContext(ctx).Desugar().Error("Failed to reconcile checkpoint ConfigMap", zap.Error(err))
 		return err
 	}
-	src.Status.MarkConnectionSuccess()
+	if src.Scope() != v1alpha1.ScopeResource {
+		// The multi-tenant receive adapter has its own permissions and Deployment.
+		if err := r.reconcileMultiTenantAdapter(ctx, src); err != nil {
+			logging.FromContext(ctx).Desugar().Error("Failed to reconcile multi-tenant receive adapter", zap.Error(err))
+			return err
+		}
+	} else {
+		if err := r.reconcileRBAC(ctx, src); err != nil {
+			logging.FromContext(ctx).Desugar().Error("Failed to reconcile receive adapter RBAC", zap.Error(err))
+			return err
+		}
+
+		// Reconcile the receive adapters.
+		ras, err := r.reconcileReceiveAdapters(ctx, src, resources.MakePartitions(layout))
+		if err != nil {
+			logging.FromContext(ctx).Desugar().Error("Failed to reconcile Deployment", zap.Error(err))
+			return err
+		}
+		src.Status.PropagateDeploymentAvailability(ras...)
+	}
 
-	// Reconcile the receive adapter.
-	ra, err := r.reconcileReceiveAdapter(ctx, src)
+	// Report whether the receive adapters lost the history of the change stream.
+	if err := r.propagateHistoryLost(src); err != nil {
+		logging.FromContext(ctx).Desugar().Error("Failed to read checkpoints", zap.Error(err))
+		return err
+	}
+
+	// The failures are retried with backoff, only the successful checks need a resync.
+	if delay := r.resync.next(); delay > 0 {
+		r.enqueueAfter(key, delay)
+	}
+	return nil
+}
+
+// FinalizeKind implements Finalizer.FinalizeKind. It deletes the state of the source that is not
+// garbage collected along with it: the Leases of its receive adapters, which they create
+// themselves, and its checkpoints, first copied for the recreated source if the source retains
+// them.
+func (r *Reconciler) FinalizeKind(ctx context.Context, src *v1alpha1.MongoDbSource) reconciler.Event {
+	// Stop the receive adapters first, so that they do not renew their Leases once deleted. The
+	// source is finalized again when their Deployments are gone.
+	stopping, err := r.stopReceiveAdapters(ctx, src)
 	if err != nil {
-		logging.FromContext(ctx).Desugar().Error("Failed to reconcile Deployment", zap.Error(err))
+		logging.FromContext(ctx).Desugar().Error("Failed to delete receive adapters", zap.Error(err))
 		return err
 	}
-	src.Status.PropagateDeploymentAvailability(ra)
+	if stopping {
+		return reconciler.NewEvent(corev1.EventTypeWarning, reasonReceiveAdaptersStopping, "Waiting for the receive adapters to stop")
+	}
+	r.checks.forget(types.NamespacedName{Namespace: src.Namespace, Name: src.Name})
+
+	// The Leases are selected by their labels rather than their names, so that the ones of the
+	// partitions removed since are deleted too.
+	selector := labels.SelectorFromSet(resources.LeaseLabels(src)).String()
+	err = r.kubeClientSet.CoordinationV1().Leases(src.Namespace).DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: selector})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logging.FromContext(ctx).Desugar().Error("Failed to delete receive adapter Leases", zap.Error(err))
+		return fmt.Errorf("error deleting leases: %v", err)
+	}
 
+	if src.Spec.RetainCheckpointOnDelete {
+		if err := r.retainCheckpoint(ctx, src); err != nil {
+			logging.FromContext(ctx).Desugar().Error("Failed to retain checkpoint ConfigMap", zap.Error(err))
+			return err
+		}
+	}
+	name := checkpoint.ConfigMapName(src)
+	err = r.kubeClientSet.CoreV1().ConfigMaps(src.Namespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logging.FromContext(ctx).Desugar().Error("Failed to delete checkpoint ConfigMap", zap.Error(err))
+		return fmt.Errorf("error deleting checkpoint configmap %q: %v", name, err)
+	}
 	return nil
 }
 
-// checkConnection checks the secret, credentials, database and collection existence.
-func (r *Reconciler) checkConnection(ctx context.Context, src *v1alpha1.MongoDbSource) reconciler.Event {
+// stopReceiveAdapters deletes the receive adapter Deployments of the source in the foreground, so
+// that they remain until their pods are gone. It returns whether any of them still exists.
+func (r *Reconciler) stopReceiveAdapters(ctx context.Context, src *v1alpha1.MongoDbSource) (bool, error) {
+	existing, err := r.deploymentLister.Deployments(src.Namespace).List(labels.SelectorFromSet(resources.Labels(src.Name)))
+	if err != nil {
+		return false, fmt.Errorf("error listing receive adapters: %v", err)
+	}
+	stopping := false
+	for _, d := range existing {
+		if !metav1.IsControlledBy(d, src.GetObjectMeta()) {
+			continue
+		}
+		stopping = true
+		if d.DeletionTimestamp != nil {
+			continue
+		}
+		logging.FromContext(ctx).Desugar().Info("Deleting receive adapter", zap.String("receiveAdapter", d.Name))
+		foreground := metav1.DeletePropagationForeground
+		err := r.kubeClientSet.AppsV1().Deployments(d.Namespace).Delete(d.Name, &metav1.DeleteOptions{PropagationPolicy: &foreground})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("error deleting receive adapter %q: %v", d.Name, err)
+		}
+	}
+	return stopping, nil
+}
+
+// retainCheckpoint copies the checkpoints of src to the ConfigMap named after the source only, owned
+// by no source, from which the source recreated with the same name resumes if it watches the same
+// stream. It replaces the checkpoints retained by a previous source of the same name, if any.
+func (r *Reconciler) retainCheckpoint(ctx context.Context, src *v1alpha1.MongoDbSource) error {
+	cm, err := r.configMapLister.ConfigMaps(src.Namespace).Get(checkpoint.ConfigMapName(src))
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting checkpoint configmap: %v", err)
+	}
+	// The checkpoints are still retained when the cluster is unknown, but never adopted.
+	cluster, err := r.clusterName(src)
+	if err != nil {
+		logging.FromContext(ctx).Desugar().Warn("Retaining checkpoints of an unknown cluster", zap.Error(err))
+	}
+	expected := resources.MakeRetainedCheckpointConfigMap(src, cluster, resources.Labels(src.Name), cm.Data)
+	logging.FromContext(ctx).Desugar().Info("Retaining checkpoints", zap.String("configMap", expected.Name))
+	retained, err := r.configMapLister.ConfigMaps(expected.Namespace).Get(expected.Name)
+	if apierrors.IsNotFound(err) {
+		if _, err := r.kubeClientSet.CoreV1().ConfigMaps(expected.Namespace).Create(expected); err != nil {
+			return fmt.Errorf("error creating retained checkpoint configmap: %v", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting retained checkpoint configmap: %v", err)
+	} else if metav1.GetControllerOf(retained) != nil {
+		return fmt.Errorf("configmap %q is owned by another resource", retained.Name)
+	}
+	retained = retained.DeepCopy()
+	retained.Labels = expected.Labels
+	retained.Annotations = expected.Annotations
+	retained.Data = expected.Data
+	if _, err := r.kubeClientSet.CoreV1().ConfigMaps(retained.Namespace).Update(retained); err != nil {
+		return fmt.Errorf("error updating retained checkpoint configmap: %v", err)
+	}
+	return nil
+}
+
+// retainedCheckpoint returns the checkpoints retained by a deleted source of the same name as src,
+// or nil if there are none. They are only adopted from a ConfigMap owned by no source, labeled as
+// the checkpoints of src and made by a source watching the same stream, as the resume tokens and
+// operation times of another stream would skip or replay changes.
+func (r *Reconciler) retainedCheckpoint(ctx context.Context, src *v1alpha1.MongoDbSource) (*corev1.ConfigMap, error) {
+	name := resources.RetainedCheckpointConfigMapName(src)
+	cm, err := r.configMapLister.ConfigMaps(src.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting retained checkpoint ConfigMap %q: %v", name, err)
+	}
+	if metav1.GetControllerOf(cm) != nil || !labels.SelectorFromSet(resources.Labels(src.Name)).Matches(labels.Set(cm.Labels)) {
+		return nil, nil
+	}
+	cluster, err := r.clusterName(src)
+	if err != nil {
+		return nil, err
+	}
+	if !resources.RetainedCheckpointMatches(cm, src, cluster) {
+		logging.FromContext(ctx).Desugar().Warn("Ignoring the retained checkpoints of another stream",
+			zap.String("configMap", cm.Name), zap.Any("annotations", cm.Annotations))
+		return nil, nil
+	}
+	return cm, nil
+}
+
+// checkConnection checks the secret, credentials, database and collection existence. It returns
+// the topology of the deployment once it could reach it, and the collections of the database when
+// they are needed to check the collection or to partition. The successful checks are cached until
+// the spec or the secrets of the source change, or the cache expires. A cached check is never older
+// than the resync interval, so that the resyncs run the checks again.
+func (r *Reconciler) checkConnection(ctx context.Context, src *v1alpha1.MongoDbSource) ([]string, *v1alpha1.MongoDbSourceTopology, reconciler.Event) {
+	// Reconcile again when the referenced secrets change.
+	if err := r.trackSecrets(src); err != nil {
+		return nil, nil, err
+	}
+
 	// Try to connect to the database and see if it works.
 	secret, err := r.secretLister.Secrets(src.Namespace).Get(src.Spec.Secret.Name)
 	if err != nil {
 		logging.FromContext(ctx).Desugar().Error("Unable to read MongoDb credentials secret", zap.Error(err))
-		return err
+		return nil, nil, secretFailure("Unable to read the MongoDb credentials secret", err)
 	}
-	rawURI, ok := secret.Data["URI"]
-	if !ok {
-		return errors.New("Unable to get MongoDb URI field")
+	opts, err := mongoclient.ClientOptions(secret.Data, src.Spec.Secret.Keys)
+	if err != nil {
+		return nil, nil, connectionFailure(reasonSecretKeyMissing, fmt.Sprintf("The MongoDb credentials secret %q has no connection string or hosts", secret.Name), err)
+	}
+	versions := []string{secret.ResourceVersion}
+	var files [3][]byte
+	if tls := src.Spec.TLS; tls != nil {
+		for i, selector := range []*corev1.SecretKeySelector{tls.CA, tls.Certificate, tls.Key} {
+			var version string
+			if files[i], version, err = r.secretKey(src.Namespace, selector); err != nil {
+				logging.FromContext(ctx).Desugar().Error("Unable to read MongoDb TLS secret", zap.Error(err))
+				return nil, nil, secretFailure("Unable to read the MongoDb TLS secret", err)
+			}
+			versions = append(versions, version)
+		}
+		if err := mongoclient.SetTLS(opts, files[0], files[1], files[2]); err != nil {
+			return nil, nil, connectionFailure(reasonInvalidTLS, "Invalid MongoDb TLS configuration", err)
+		}
+	}
+	// The clients are shared by the sources, which do not lend them their names.
+	if err := mongoclient.SetConnection(opts, src.Spec.Connection, checkAppName); err != nil {
+		return nil, nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, nil, connectionFailure(reasonInvalidURI, "Invalid MongoDb connection string or options", err)
 	}
-	URI := string(rawURI)
 
-	// Connect to the MongoDb replica-set.
-	client, err := r.createClientFn(options.Client().ApplyURI(URI))
+	name := types.NamespacedName{Namespace: src.Namespace, Name: src.Name}
+	resultKey, err := hashKey(src.Spec, versions)
 	if err != nil {
-		logging.FromContext(ctx).Desugar().Error("Error creating mongo client", zap.Error(err))
-		return err
+		return nil, nil, err
 	}
-	err = client.Connect(ctx)
+	if collections, topology, ok := r.checks.result(name, resultKey, r.resync.current()); ok {
+		return collections, topology, nil
+	}
+	clientKey, err := hashKey(secret.Data, src.Spec.Secret.Keys, files, src.Spec.Connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	collections, topology, err := r.runCheck(ctx, src, clientKey, opts)
+	reportCheckLatency(ctx, time.Since(start), err == nil)
+	if err != nil {
+		return nil, topology, err
+	}
+	// The namespaces pending are checked until they are created.
+	if reason, _ := pendingNamespace(src, topology); reason == "" {
+		r.checks.store(name, resultKey, collections, topology)
+	}
+	return collections, topology, nil
+}
+
+// runCheck connects to MongoDb with a client for the configuration identified by clientKey, and
+// checks the database and collection existence within the check timeouts.
+func (r *Reconciler) runCheck(ctx context.Context, src *v1alpha1.MongoDbSource, clientKey string, opts *options.ClientOptions) ([]string, *v1alpha1.MongoDbSourceTopology, error) {
+	ctx, cancel := r.checks.bound(ctx, opts)
+	defer cancel()
+
+	// Connect to the MongoDb replica-set.
+	client, release, err := r.checks.client(ctx, clientKey, r.createClientFn, opts)
 	if err != nil {
 		logging.FromContext(ctx).Desugar().Error("Error connecting to mongo client", zap.Error(err))
-		return err
+		return nil, nil, err
+	}
+	collections, topology, err := checkDatabase(ctx, client, src, opts.Hosts)
+	release(clientFailed(err))
+	return collections, topology, err
+}
+
+// checkDatabase checks the database and collection existence, and that the server and user meet
+// the prerequisites of the mode of the source. It returns the topology of the deployment, whose
+// routers are the seeds when it is a sharded cluster, even if the checks fail after reaching it.
+// The database and collection are only required to exist if the source requires it.
+func checkDatabase(ctx context.Context, client mongoclient.Client, src *v1alpha1.MongoDbSource, seeds []string) ([]string, *v1alpha1.MongoDbSourceTopology, error) {
+	topology, info, err := describeServer(ctx, client.Database("admin"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if topology.Type == v1alpha1.TopologySharded {
+		topology.Hosts = seeds
 	}
-	defer client.Disconnect(ctx)
 
 	// See if database exists in available databases.
 	databases, err := client.ListDatabaseNames(ctx, bson.M{})
 	if err != nil {
 		logging.FromContext(ctx).Desugar().Error("Error listing databases", zap.Error(err))
-		return err
+		return nil, topology, operationFailure("list the databases", err)
 	}
-	if !stringInSlice(src.Spec.Database, databases) {
+	requireNamespace := src.Spec.RequireExistingNamespace == nil || *src.Spec.RequireExistingNamespace
+	topology.DatabaseExists = stringInSlice(src.Spec.Database, databases)
+	if !topology.DatabaseExists && requireNamespace {
 		err = fmt.Errorf("database %q not found in available databases", src.Spec.Database)
 		logging.FromContext(ctx).Desugar().Error("Database not found in available databases", zap.Any("database", src.Spec.Database), zap.Any("availableDatabases", fmt.Sprint(databases)), zap.Error(err))
-		return err
+		return nil, topology, connectionFailure(reasonDatabaseNotFound, "The MongoDb database does not exist", err)
 	}
 
-	// See if collection exists in available collections.
-	if src.Spec.Collection != "" {
-		collections, err := client.Database(src.Spec.Database).ListCollectionNames(ctx, bson.M{})
-		if err != nil {
+	// Change streams need a replica set or a sharded cluster.
+	if src.Spec.Mode != v1alpha1.SourceModePoll && topology.Type == v1alpha1.TopologyStandalone {
+		return nil, topology, connectionFailure(reasonNotReplicaSet, "The MongoDb server is not a replica set or a sharded cluster",
+			errors.New(`the MongoDb server is a standalone server, which does not support change streams: deploy a replica set or set spec.mode to "poll"`))
+	}
+
+	if src.Spec.Collection == "" && src.Spec.Partitioning == "" {
+		return nil, topology, preflight(ctx, client, src, info)
+	}
+	var collections []string
+	if topology.DatabaseExists {
+		if collections, err = client.Database(src.Spec.Database).ListCollectionNames(ctx, bson.M{}); err != nil {
 			logging.FromContext(ctx).Desugar().Error("Error listing collections", zap.Error(err))
-			return err
+			return nil, topology, operationFailure("list the collections", err)
 		}
-		if !stringInSlice(src.Spec.Collection, collections) {
+	}
+
+	// See if collection exists in available collections.
+	if src.Spec.Collection != "" {
+		exists := stringInSlice(src.Spec.Collection, collections)
+		topology.CollectionExists = &exists
+		if !exists && requireNamespace {
 			err = fmt.Errorf("collection %q not found in available collections", src.Spec.Collection)
 			logging.FromContext(ctx).Desugar().Error("Collection not found in available collections", zap.Any("collection", src.Spec.Collection), zap.Any("availableCollections", fmt.Sprint(collections)), zap.Error(err))
-			return err
+			return nil, topology, connectionFailure(reasonCollectionNotFound, "The MongoDb collection does not exist", err)
 		}
 	}
 
+	if err := preflight(ctx, client, src, info); err != nil {
+		return nil, topology, err
+	}
+	return collections, topology, nil
+}
+
+// pendingNamespace returns the reason and message of the NamespacePending condition of src if its
+// database or collection does not exist yet, or an empty reason.
+func pendingNamespace(src *v1alpha1.MongoDbSource, topology *v1alpha1.MongoDbSourceTopology) (string, string) {
+	switch {
+	case !topology.DatabaseExists:
+		return reasonDatabaseNotFound, fmt.Sprintf("The MongoDb database %q does not exist yet", src.Spec.Database)
+	case topology.CollectionExists != nil && !*topology.CollectionExists:
+		return reasonCollectionNotFound, fmt.Sprintf("The MongoDb collection %q does not exist yet", src.Spec.Collection)
+	}
+	return "", ""
+}
+
+// hashKey returns the hex SHA-256 of the JSON encoding of values.
+func hashKey(values ...interface{}) (string, error) {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("error hashing the connection check: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// secretNames returns the names of the credentials and TLS secrets referenced by src.
+func secretNames(src *v1alpha1.MongoDbSource) []string {
+	names := []string{src.Spec.Secret.Name}
+	if tls := src.Spec.TLS; tls != nil {
+		for _, selector := range []*corev1.SecretKeySelector{tls.CA, tls.Certificate, tls.Key} {
+			if selector != nil {
+				names = append(names, selector.Name)
+			}
+		}
+	}
+	return names
+}
+
+// trackSecrets tracks the credentials and TLS secrets referenced by src.
+func (r *Reconciler) trackSecrets(src *v1alpha1.MongoDbSource) error {
+	for _, name := range secretNames(src) {
+		ref := tracker.Reference{APIVersion: "v1", Kind: "Secret", Namespace: src.Namespace, Name: name}
+		if err := r.tracker.TrackReference(ref, src); err != nil {
+			return fmt.Errorf("unable to track secret %q: %w", name, err)
+		}
+	}
 	return nil
 }
 
+// secretKey returns the value of the selected key of a secret and the resource version of the
+// secret, or nil if selector is nil.
+func (r *Reconciler) secretKey(namespace string, selector *corev1.SecretKeySelector) ([]byte, string, error) {
+	if selector == nil {
+		return nil, "", nil
+	}
+	secret, err := r.secretLister.Secrets(namespace).Get(selector.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: secret %q has no key %q", errSecretKeyMissing, selector.Name, selector.Key)
+	}
+	return value, secret.ResourceVersion, nil
+}
+
+// secretFailure returns the connectionError of a secret that could not be read.
+func secretFailure(message string, err error) error {
+	reason := reasonConnectionFailed
+	switch {
+	case apierrors.IsNotFound(err):
+		reason = reasonSecretNotFound
+	case errors.Is(err, errSecretKeyMissing):
+		reason = reasonSecretKeyMissing
+	}
+	return connectionFailure(reason, message, err)
+}
+
+// markConnectionFailed marks the connection of src as failed, and sends an event if it was not
+// already failing for the same reason.
+func markConnectionFailed(ctx context.Context, src *v1alpha1.MongoDbSource, reason, message string) {
+	cond := src.Status.GetCondition(v1alpha1.MongoDbConditionConnectionEstablished)
+	if cond == nil || !cond.IsFalse() || cond.Reason != reason {
+		controller.GetEventRecorder(ctx).Event(src, corev1.EventTypeWarning, reason, message)
+	}
+	src.Status.MarkConnectionFailed(reason, "%s", message)
+}
+
+// markConnectionSuccess marks the connection of src as established, and sends an event if it
+// was failing.
+func markConnectionSuccess(ctx context.Context, src *v1alpha1.MongoDbSource) {
+	if cond := src.Status.GetCondition(v1alpha1.MongoDbConditionConnectionEstablished); cond != nil && cond.IsFalse() {
+		controller.GetEventRecorder(ctx).Event(src, corev1.EventTypeNormal, reasonConnectionEstablished, "Connected to MongoDb")
+	}
+	src.Status.MarkConnectionSuccess()
+}
+
+// markSuspended marks src as suspended, and sends an event if it was not already suspended.
+func markSuspended(ctx context.Context, src *v1alpha1.MongoDbSource) {
+	if cond := src.Status.GetCondition(v1alpha1.MongoDbConditionSuspended); cond == nil || !cond.IsTrue() {
+		controller.GetEventRecorder(ctx).Event(src, corev1.EventTypeNormal, reasonSuspended, "The receive adapters are stopped")
+	}
+	src.Status.MarkSuspended()
+}
+
+// markResumed marks src as no longer suspended, and sends an event if it was suspended.
+func markResumed(ctx context.Context, src *v1alpha1.MongoDbSource) {
+	if cond := src.Status.GetCondition(v1alpha1.MongoDbConditionSuspended); cond != nil && cond.IsTrue() {
+		controller.GetEventRecorder(ctx).Event(src, corev1.EventTypeNormal, reasonResumed, "The receive adapters are started again")
+	}
+	src.Status.MarkResumed()
+}
+
+// markChangeStreamNotSupported marks the prerequisites of src as missing, and sends an event if
+// they were not already missing with the same message.
+func markChangeStreamNotSupported(ctx context.Context, src *v1alpha1.MongoDbSource, message string) {
+	cond := src.Status.GetCondition(v1alpha1.MongoDbConditionChangeStreamSupported)
+	if cond == nil || !cond.IsFalse() || cond.Message != message {
+		controller.GetEventRecorder(ctx).Event(src, corev1.EventTypeWarning, reasonPrerequisitesMissing, message)
+	}
+	src.Status.MarkChangeStreamNotSupported(reasonPrerequisitesMissing, "%s", message)
+}
+
+// markChangeStreamSupported marks the prerequisites of src as met, and sends an event if they were
+// missing.
+func markChangeStreamSupported(ctx context.Context, src *v1alpha1.MongoDbSource) {
+	if cond := src.Status.GetCondition(v1alpha1.MongoDbConditionChangeStreamSupported); cond != nil && cond.IsFalse() {
+		controller.GetEventRecorder(ctx).Event(src, corev1.EventTypeNormal, reasonPrerequisitesMet, "The MongoDb server and user meet the prerequisites of the source")
+	}
+	src.Status.MarkChangeStreamSupported()
+}
+
+// describeServer returns the topology of the deployment of the server, without its routers, and
+// the version of the server.
+func describeServer(ctx context.Context, admin mongoclient.Database) (*v1alpha1.MongoDbSourceTopology, buildInfo, error) {
+	var info buildInfo
+	raw, err := admin.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}})
+	if err != nil {
+		logging.FromContext(ctx).Desugar().Error("Error running isMaster", zap.Error(err))
+		return nil, info, operationFailure("run isMaster", err)
+	}
+	var isMaster struct {
+		SetName  string   `bson:"setName"`
+		Msg      string   `bson:"msg"`
+		Hosts    []string `bson:"hosts"`
+		Passives []string `bson:"passives"`
+		Arbiters []string `bson:"arbiters"`
+	}
+	if err := bson.Unmarshal(raw, &isMaster); err != nil {
+		return nil, info, err
+	}
+	topology := &v1alpha1.MongoDbSourceTopology{Type: v1alpha1.TopologyStandalone}
+	switch {
+	case isMaster.SetName != "":
+		topology.Type, topology.ReplicaSet = v1alpha1.TopologyReplicaSet, isMaster.SetName
+		topology.Hosts = append(append(append([]string(nil), isMaster.Hosts...), isMaster.Passives...), isMaster.Arbiters...)
+	case isMaster.Msg == "isdbgrid":
+		topology.Type = v1alpha1.TopologySharded
+	}
+
+	raw, err = admin.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}})
+	if err != nil {
+		return nil, info, operationFailure("run buildInfo", err)
+	}
+	if err := bson.Unmarshal(raw, &info); err != nil {
+		return nil, info, err
+	}
+	topology.ServerVersion = info.Version
+	return topology, info, nil
+}
+
 // resolveSink checks the resolvability of the specified sink.
 func (r *Reconciler) resolveSink(ctx context.Context, src *v1alpha1.MongoDbSource) (*apis.URL, error) {
 	dest := src.Spec.Sink.DeepCopy()
@@ -167,16 +668,123 @@ func (r *Reconciler) resolveSink(ctx context.Context, src *v1alpha1.MongoDbSourc
 	return r.sinkResolver.URIFromDestinationV1(*dest, src)
 }
 
-// reconcileReceiveAdapter reconciles the Receive Adapter Deployment.
-func (r *Reconciler) reconcileReceiveAdapter(ctx context.Context, src *v1alpha1.MongoDbSource) (*appsv1.Deployment, error) {
-	ceSourcePrefix, err := r.makeCeSourcePrefix(ctx, src)
+// reconcileReceiveAdapters reconciles the Receive Adapter Deployment of each partition, or the
+// single one if the source is not partitioned, and deletes the others.
+func (r *Reconciler) reconcileReceiveAdapters(ctx context.Context, src *v1alpha1.MongoDbSource, partitions []resources.Partition) ([]*appsv1.Deployment, error) {
+	var ras []*appsv1.Deployment
+	if partitions == nil {
+		ra, err := r.reconcileReceiveAdapter(ctx, src, nil)
+		if err != nil {
+			return nil, err
+		}
+		ras = append(ras, ra)
+	}
+	src.Status.Partitions = nil
+	for i := range partitions {
+		ra, err := r.reconcileReceiveAdapter(ctx, src, &partitions[i])
+		if err != nil {
+			return nil, err
+		}
+		ras = append(ras, ra)
+		src.Status.Partitions = append(src.Status.Partitions, v1alpha1.MongoDbSourcePartition{
+			Index:       int32(partitions[i].Index),
+			Deployment:  ra.Name,
+			Collections: partitions[i].Collections,
+		})
+	}
+
+	// Delete the receive adapters of the partitions that no longer exist.
+	if err := r.deleteObsoleteReceiveAdapters(ctx, src, ras); err != nil {
+		return nil, err
+	}
+	return ras, nil
+}
+
+// deleteObsoleteReceiveAdapters deletes the receive adapter Deployments of the source but ras.
+func (r *Reconciler) deleteObsoleteReceiveAdapters(ctx context.Context, src *v1alpha1.MongoDbSource, ras []*appsv1.Deployment) error {
+	existing, err := r.deploymentLister.Deployments(src.Namespace).List(labels.SelectorFromSet(resources.Labels(src.Name)))
+	if err != nil {
+		return fmt.Errorf("error listing receive adapters: %v", err)
+	}
+	for _, d := range existing {
+		if !metav1.IsControlledBy(d, src.GetObjectMeta()) || containsDeployment(ras, d.Name) {
+			continue
+		}
+		logging.FromContext(ctx).Desugar().Info("Deleting obsolete receive adapter", zap.String("receiveAdapter", d.Name))
+		if err := r.kubeClientSet.AppsV1().Deployments(d.Namespace).Delete(d.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting receive adapter %q: %v", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// suspendReceiveAdapters scales the receive adapter Deployments of the source to zero. They save
+// their checkpoints when they stop, and resume from them when they are scaled up again. A source
+// watched by a multi-tenant receive adapter has no Deployment of its own, the multi-tenant
+// receive adapter stops its stream.
+func (r *Reconciler) suspendReceiveAdapters(ctx context.Context, src *v1alpha1.MongoDbSource) error {
+	existing, err := r.deploymentLister.Deployments(src.Namespace).List(labels.SelectorFromSet(resources.Labels(src.Name)))
+	if err != nil {
+		return fmt.Errorf("error listing receive adapters: %v", err)
+	}
+	for _, d := range existing {
+		if !metav1.IsControlledBy(d, src.GetObjectMeta()) || (d.Spec.Replicas != nil && *d.Spec.Replicas == 0) {
+			continue
+		}
+		logging.FromContext(ctx).Desugar().Info("Stopping receive adapter", zap.String("receiveAdapter", d.Name))
+		d = d.DeepCopy()
+		zero := int32(0)
+		d.Spec.Replicas = &zero
+		if _, err := r.kubeClientSet.AppsV1().Deployments(d.Namespace).Update(d); err != nil {
+			return fmt.Errorf("error stopping receive adapter %q: %v", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// reconcileMultiTenantAdapter deletes the own receive adapters of a source watched by a
+// multi-tenant receive adapter, and propagates the availability of the latter.
+func (r *Reconciler) reconcileMultiTenantAdapter(ctx context.Context, src *v1alpha1.MongoDbSource) error {
+	if err := r.deleteObsoleteReceiveAdapters(ctx, src, nil); err != nil {
+		return err
+	}
+	src.Status.Partitions = nil
+
+	namespace := system.Namespace()
+	if src.Scope() == v1alpha1.ScopeNamespace {
+		namespace = src.Namespace
+	}
+	ra, err := r.deploymentLister.Deployments(namespace).Get(resources.MultiTenantAdapterName)
+	if apierrors.IsNotFound(err) {
+		src.Status.MarkNoDeployment("MultiTenantAdapterNotFound", "The multi-tenant receive adapter %s/%s does not exist", namespace, resources.MultiTenantAdapterName)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting multi-tenant receive adapter: %v", err)
+	}
+	src.Status.PropagateDeploymentAvailability(ra)
+	return nil
+}
+
+// reconcileReceiveAdapter reconciles the Receive Adapter Deployment of a partition, or of the
+// source if partition is nil.
+func (r *Reconciler) reconcileReceiveAdapter(ctx context.Context, src *v1alpha1.MongoDbSource, partition *resources.Partition) (*appsv1.Deployment, error) {
+	clusterName, err := r.clusterName(src)
+	if err != nil {
+		return nil, err
+	}
+	raLabels := resources.Labels(src.Name)
+	if partition != nil {
+		raLabels = resources.PartitionLabels(src.Name, partition.Index)
+	}
 	args := &resources.ReceiveAdapterArgs{
-		Image:          r.receiveAdapterImage,
-		Labels:         resources.Labels(src.Name),
-		Source:         src,
-		CeSourcePrefix: ceSourcePrefix,
-		SinkURL:        src.Status.SinkURI.String(),
-		Configs:        r.configs,
+		Image:           r.receiveAdapterImage,
+		Labels:          raLabels,
+		Source:          src,
+		ClusterName:     clusterName,
+		SinkURL:         src.Status.SinkURI.String(),
+		Configs:         r.configs,
+		Partition:       partition,
+		AdapterDefaults: r.adapterDefaults.get(),
 	}
 	expected, err := resources.MakeReceiveAdapter(args)
 	if err != nil {
@@ -194,65 +802,202 @@ func (r *Reconciler) reconcileReceiveAdapter(ctx context.Context, src *v1alpha1.
 	} else if !metav1.IsControlledBy(ra, src.GetObjectMeta()) {
 		return nil, fmt.Errorf("deployment %q is not owned by %s %q",
 			ra.Name, src.GetGroupVersionKind().Kind, src.GetObjectMeta().GetName())
-	} else if r.podSpecImageSync(expected.Spec.Template.Spec, ra.Spec.Template.Spec) {
+	}
+	ra = ra.DeepCopy()
+	dirty := syncPodTemplate(&expected.Spec.Template, &ra.Spec.Template)
+	if !equality.Semantic.DeepDerivative(expected.Labels, ra.Labels) {
+		ra.Labels = expected.Labels
+		dirty = true
+	}
+	if !equality.Semantic.DeepEqual(expected.Spec.Replicas, ra.Spec.Replicas) {
+		ra.Spec.Replicas = expected.Spec.Replicas
+		dirty = true
+	}
+	if dirty {
 		if ra, err = r.kubeClientSet.AppsV1().Deployments(expected.Namespace).Update(ra); err != nil {
 			return ra, err
 		}
 		return ra, nil
-	} else {
-		logging.FromContext(ctx).Desugar().Debug("Reusing existing receive adapter", zap.Any("receiveAdapter", ra))
 	}
+	logging.FromContext(ctx).Desugar().Debug("Reusing existing receive adapter", zap.Any("receiveAdapter", ra))
 	return ra, nil
 }
 
-// Returns false if an update is needed.
-func (r *Reconciler) podSpecImageSync(expected corev1.PodSpec, now corev1.PodSpec) bool {
-	// got needs all of the containers that want as, but it is allowed to have more.
-	dirty := false
-	for _, ec := range expected.Containers {
-		n, nc := getContainer(ec.Name, now)
-		if nc == nil {
-			now.Containers = append(now.Containers, ec)
-			dirty = true
-			continue
-		}
-		if nc.Image != ec.Image {
-			now.Containers[n].Image = ec.Image
-			dirty = true
+// containsDeployment returns whether a Deployment with the given name is in the list.
+func containsDeployment(ds []*appsv1.Deployment, name string) bool {
+	for _, d := range ds {
+		if d.Name == name {
+			return true
 		}
 	}
-	return dirty
+	return false
 }
 
-// getContainer gets a container by name.
-func getContainer(name string, spec corev1.PodSpec) (int, *corev1.Container) {
-	for i, c := range spec.Containers {
-		if c.Name == name {
-			return i, &c
+// reconcileCheckpoint makes sure the ConfigMap holding the checkpoints of the receive adapters
+// exists, matches the assignment of collections to partitions, if any, and follows the rewind
+// annotation of the source. It returns the layout of the checkpoints of the partitions. The
+// checkpoints are otherwise owned by the receive adapters.
+func (r *Reconciler) reconcileCheckpoint(ctx context.Context, src *v1alpha1.MongoDbSource, assignment [][]string) (*checkpoint.Layout, error) {
+	expected := resources.MakeCheckpointConfigMap(src, resources.Labels(src.Name))
+	var retained *corev1.ConfigMap
+	cm, err := r.configMapLister.ConfigMaps(expected.Namespace).Get(expected.Name)
+	if apierrors.IsNotFound(err) {
+		// A recreated source resumes from the checkpoints retained when it was deleted.
+		if retained, err = r.retainedCheckpoint(ctx, src); err != nil {
+			return nil, err
+		}
+		if retained != nil {
+			logging.FromContext(ctx).Desugar().Info("Adopting retained checkpoints", zap.String("configMap", retained.Name))
+			expected.Data = retained.DeepCopy().Data
 		}
+		cm = expected
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting checkpoint ConfigMap %q: %v", expected.Name, err)
+	} else if !metav1.IsControlledBy(cm, src.GetObjectMeta()) {
+		return nil, fmt.Errorf("configmap %q is not owned by %s %q",
+			cm.Name, src.GetGroupVersionKind().Kind, src.GetObjectMeta().GetName())
 	}
-	return -1, nil
-}
 
-// makeCeSourcePrefix computes the Cloud Event source prefix for the Event Source variable.
-func (r *Reconciler) makeCeSourcePrefix(ctx context.Context, src *v1alpha1.MongoDbSource) (string, error) {
-	secret, err := r.secretLister.Secrets(src.Namespace).Get(src.Spec.Secret.Name)
+	layout, rebalanced, err := resources.RebalanceCheckpoints(cm, assignment)
 	if err != nil {
-		logging.FromContext(ctx).Error("Unable to read MongoDb credentials secret", zap.Error(err))
-		return "", err
+		return nil, err
 	}
-	rawURI, ok := secret.Data["URI"]
-	if !ok {
-		logging.FromContext(ctx).Error("Unable to get MongoDb URI field", zap.Any("secretName", secret.Name), zap.Any("secretNamespace", secret.Namespace))
-		return "", err
+	updated := cm
+	if rebalanced != nil {
+		logging.FromContext(ctx).Desugar().Info("Rebalancing partitions", zap.Any("collections", assignment))
+		updated = rebalanced
+	}
+	rewind := src.Annotations[v1alpha1.RewindAnnotation]
+	rewound, err := resources.RewindCheckpoints(updated, layout, rewind)
+	if err != nil {
+		return nil, err
+	}
+	if rewound != nil {
+		logging.FromContext(ctx).Desugar().Info("Rewinding checkpoints", zap.String("rewind", rewind))
+		updated = rewound
 	}
 
-	url, err := url.Parse(string(rawURI))
+	if cm == expected {
+		if _, err = r.kubeClientSet.CoreV1().ConfigMaps(updated.Namespace).Create(updated); err != nil {
+			return nil, err
+		}
+		if retained != nil {
+			err = r.kubeClientSet.CoreV1().ConfigMaps(retained.Namespace).Delete(retained.Name, &metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("error deleting retained checkpoint configmap %q: %v", retained.Name, err)
+			}
+		}
+		return layout, nil
+	}
+	if updated != cm {
+		if _, err = r.kubeClientSet.CoreV1().ConfigMaps(updated.Namespace).Update(updated); err != nil {
+			return nil, err
+		}
+	}
+	return layout, nil
+}
+
+// propagateHistoryLost reports the last loss of the history of the change stream recorded by the
+// receive adapters in their checkpoints.
+func (r *Reconciler) propagateHistoryLost(src *v1alpha1.MongoDbSource) error {
+	cm, err := r.configMapLister.ConfigMaps(src.Namespace).Get(checkpoint.ConfigMapName(src))
+	if apierrors.IsNotFound(err) {
+		src.Status.MarkHistoryRetained()
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting checkpoint ConfigMap: %v", err)
+	}
+	lost, err := resources.LastHistoryLost(cm)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if lost == nil {
+		src.Status.MarkHistoryRetained()
+		return nil
+	}
+	src.Status.MarkHistoryLost(v1alpha1.HistoryLostPolicy(lost.Policy),
+		"The change stream history was lost at %s and the %q policy applied: %s",
+		lost.Time.UTC().Format(time.RFC3339), lost.Policy, lost.Message)
+	return nil
+}
+
+// reconcileRBAC reconciles the Role and RoleBinding granting the service account of the receive
+// adapter access to its checkpoint ConfigMap.
+func (r *Reconciler) reconcileRBAC(ctx context.Context, src *v1alpha1.MongoDbSource) error {
+	labels := resources.Labels(src.Name)
+
+	expectedRole := resources.MakeRole(src, labels)
+	role, err := r.roleLister.Roles(expectedRole.Namespace).Get(expectedRole.Name)
+	if apierrors.IsNotFound(err) {
+		if _, err = r.kubeClientSet.RbacV1().Roles(expectedRole.Namespace).Create(expectedRole); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("error getting role %q: %v", expectedRole.Name, err)
+	} else if !metav1.IsControlledBy(role, src.GetObjectMeta()) {
+		return fmt.Errorf("role %q is not owned by %s %q",
+			role.Name, src.GetGroupVersionKind().Kind, src.GetObjectMeta().GetName())
+	} else if !equality.Semantic.DeepEqual(expectedRole.Rules, role.Rules) {
+		role = role.DeepCopy()
+		role.Rules = expectedRole.Rules
+		if _, err = r.kubeClientSet.RbacV1().Roles(role.Namespace).Update(role); err != nil {
+			return err
+		}
 	}
 
-	return fmt.Sprintf("mongodb://%s", url.Hostname()), nil
+	expectedBinding := resources.MakeRoleBinding(src, labels)
+	binding, err := r.roleBindingLister.RoleBindings(expectedBinding.Namespace).Get(expectedBinding.Name)
+	if apierrors.IsNotFound(err) {
+		_, err = r.kubeClientSet.RbacV1().RoleBindings(expectedBinding.Namespace).Create(expectedBinding)
+		return err
+	} else if err != nil {
+		return fmt.Errorf("error getting rolebinding %q: %v", expectedBinding.Name, err)
+	} else if !metav1.IsControlledBy(binding, src.GetObjectMeta()) {
+		return fmt.Errorf("rolebinding %q is not owned by %s %q",
+			binding.Name, src.GetGroupVersionKind().Kind, src.GetObjectMeta().GetName())
+	} else if !equality.Semantic.DeepEqual(expectedBinding.Subjects, binding.Subjects) {
+		// The role reference of a RoleBinding is immutable, only the subjects can change.
+		binding = binding.DeepCopy()
+		binding.Subjects = expectedBinding.Subjects
+		_, err = r.kubeClientSet.RbacV1().RoleBindings(binding.Namespace).Update(binding)
+		return err
+	}
+	return nil
+}
+
+// syncPodTemplate updates the pod template of a receive adapter to the expected one, and returns
+// whether it changed. The fields defaulted by the API server are ignored, while the hash annotation
+// notices the fields removed from the expected pod spec. The changes of the secrets are not
+// noticed on purpose: the receive adapter reconnects with the rotated credentials without a
+// rollout.
+func syncPodTemplate(expected, now *corev1.PodTemplateSpec) bool {
+	hash := expected.Annotations[resources.ConfigHashAnnotation]
+	if now.Annotations[resources.ConfigHashAnnotation] == hash &&
+		equality.Semantic.DeepDerivative(expected.Labels, now.Labels) &&
+		equality.Semantic.DeepDerivative(expected.Spec, now.Spec) {
+		return false
+	}
+	now.Labels = expected.Labels
+	if now.Annotations == nil {
+		now.Annotations = make(map[string]string, 1)
+	}
+	now.Annotations[resources.ConfigHashAnnotation] = hash
+	now.Spec = expected.Spec
+	return true
+}
+
+// clusterName returns the name identifying the MongoDb deployment of the source in the
+// CloudEvents source of its events, from its topology or else its credentials.
+func (r *Reconciler) clusterName(src *v1alpha1.MongoDbSource) (string, error) {
+	secret, err := r.secretLister.Secrets(src.Namespace).Get(src.Spec.Secret.Name)
+	if err != nil {
+		return "", fmt.Errorf("error reading MongoDb credentials secret: %w", err)
+	}
+	name, err := mongoclient.ClusterName(secret.Data, src.Spec.Secret.Keys, src.Status.Topology)
+	if err != nil {
+		return "", fmt.Errorf("error identifying the MongoDb cluster: %w", err)
+	}
+	return name, nil
 }
 
 // Helper function: finds if string exists in array of strings.
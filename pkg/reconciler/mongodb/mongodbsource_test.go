@@ -21,21 +21,35 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	require "github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/resolver"
+	"knative.dev/pkg/system"
+	"knative.dev/pkg/tracker"
 
 	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/bson"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
@@ -46,6 +60,7 @@ import (
 	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 
 	sourcesv1alpha1 "github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
 	"github.com/googleinterns/knative-source-mongodb/pkg/client/injection/reconciler/sources/v1alpha1/mongodbsource"
 	"github.com/googleinterns/knative-source-mongodb/pkg/reconciler/mongodb/resources"
 	. "github.com/googleinterns/knative-source-mongodb/pkg/reconciler/testing"
@@ -60,6 +75,12 @@ var (
 		Host:   sinkDNS,
 		Path:   "/",
 	}
+
+	// finalizerUpdatedEvent is the event sent when the finalizer is added to the source.
+	finalizerUpdatedEvent = Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "test-mongodb-source" finalizers`)
+
+	// replicaSet is the isMaster and buildInfo result of a replica set member.
+	replicaSet = bson.M{"ismaster": true, "setName": "rs0", "hosts": bson.A{"mongo-0:27017", "mongo-1:27017"}, "version": "4.4.1"}
 )
 
 const (
@@ -72,6 +93,9 @@ const (
 	db          = "db"
 	coll        = "coll"
 	validURI    = "mongodb://valid"
+
+	finalizerName  = "mongodbsources.sources.google.com"
+	testCheckpoint = `{"resumeToken":"{\"_data\":\"825F3A\"}","operationTime":{"T":10,"I":1}}`
 )
 
 func init() {
@@ -99,21 +123,24 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: "secret",
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "secret"},
 						},
 					}),
 					WithMongoDbSourceUID(sourceUID),
 				),
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 				Object: NewMongoDbSource(sourceName, testNS,
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: "secret",
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "secret"},
 						},
 					}),
 					WithMongoDbSourceUID(sourceUID),
@@ -123,6 +150,7 @@ func TestAllCases(t *testing.T) {
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
 				Eventf(corev1.EventTypeWarning, `UpdateFailed Failed to update status for "test-mongodb-source":`,
 					`missing field(s): spec.sink`),
 			},
@@ -142,6 +170,9 @@ func TestAllCases(t *testing.T) {
 				newSink(),
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 				Object: NewMongoDbSource(sourceName, testNS,
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
@@ -153,10 +184,12 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed(`secret "" not found`),
+					WithMongoDbSourceConnectionFailed("SecretNotFound", `Unable to read the MongoDb credentials secret: secret "" not found`),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "SecretNotFound", `Unable to read the MongoDb credentials secret: secret "" not found`),
 				Eventf(corev1.EventTypeWarning, `UpdateFailed Failed to update status for "test-mongodb-source":`,
 					`missing field(s): spec.secret`),
 			},
@@ -169,8 +202,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -188,13 +221,16 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 				Object: NewMongoDbSource(sourceName, testNS,
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -202,24 +238,92 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed("Unable to get MongoDb URI field"),
+					WithMongoDbSourceConnectionFailed("SecretKeyMissing", `The MongoDb credentials secret "test-secret" has no connection string or hosts: Unable to get MongoDb URI, host or hosts field`),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "SecretKeyMissing", `The MongoDb credentials secret "test-secret" has no connection string or hosts: Unable to get MongoDb URI, host or hosts field`),
 				Eventf(corev1.EventTypeWarning, "InternalError",
-					"Unable to get MongoDb URI field"),
+					`The MongoDb credentials secret "test-secret" has no connection string or hosts: Unable to get MongoDb URI, host or hosts field`),
 			},
 		},
 		{
-			Name:    "can't create mongodb client",
+			Name:    "TLS CA secret not found",
+			WantErr: true,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						TLS: &sourcesv1alpha1.MongoDbSourceTLS{
+							CA: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "mongo-ca"},
+								Key:                  "ca.crt",
+							},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+			},
+			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						TLS: &sourcesv1alpha1.MongoDbSourceTLS{
+							CA: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "mongo-ca"},
+								Key:                  "ca.crt",
+							},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceConnectionFailed("SecretNotFound", `Unable to read the MongoDb TLS secret: secret "mongo-ca" not found`),
+				),
+			}},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "SecretNotFound", `Unable to read the MongoDb TLS secret: secret "mongo-ca" not found`),
+				Eventf(corev1.EventTypeWarning, "InternalError",
+					`Unable to read the MongoDb TLS secret: secret "mongo-ca" not found`),
+			},
+		},
+		{
+			Name:    "invalid connection string",
 			WantErr: true,
 			Objects: []runtime.Object{
 				NewMongoDbSource(sourceName, testNS,
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -237,6 +341,63 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceConnectionFailed("InvalidURI", `Invalid MongoDb connection string or options: error parsing uri: scheme must be "mongodb" or "mongodb+srv"`),
+				),
+			}},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "InvalidURI", `Invalid MongoDb connection string or options: error parsing uri: scheme must be "mongodb" or "mongodb+srv"`),
+				Eventf(corev1.EventTypeWarning, "InternalError",
+					`Invalid MongoDb connection string or options: error parsing uri: scheme must be "mongodb" or "mongodb+srv"`),
+			},
+		},
+		{
+			Name:    "can't create mongodb client",
+			WantErr: true,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+			},
+			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					CreateClientErr: errors.New(`Error creating mongo client`),
@@ -247,8 +408,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -256,12 +417,14 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed(`Error creating mongo client`),
+					WithMongoDbSourceConnectionFailed("InvalidURI", `Unable to create the MongoDb client: Error creating mongo client`),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "InvalidURI", `Unable to create the MongoDb client: Error creating mongo client`),
 				Eventf(corev1.EventTypeWarning, "InternalError",
-					`Error creating mongo client`),
+					`Unable to create the MongoDb client: Error creating mongo client`),
 			},
 		},
 		{
@@ -272,8 +435,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -291,6 +454,9 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					ConnectErr: errors.New(`Error connecting to mongo client`),
@@ -301,8 +467,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -310,12 +476,14 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed(`Error connecting to mongo client`),
+					WithMongoDbSourceConnectionFailed("ServerUnreachable", `Unable to connect to MongoDb: Error connecting to mongo client`),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "ServerUnreachable", `Unable to connect to MongoDb: Error connecting to mongo client`),
 				Eventf(corev1.EventTypeWarning, "InternalError",
-					`Error connecting to mongo client`),
+					`Unable to connect to MongoDb: Error connecting to mongo client`),
 			},
 		},
 		{
@@ -326,8 +494,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -345,9 +513,13 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					ListDbErr: errors.New(`Error listing databases`),
+					DbData:    mongotesting.TestDbData{RunCommandResult: replicaSet},
 				},
 			},
 			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
@@ -355,8 +527,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -364,12 +536,15 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed("Error listing databases"),
+					WithMongoDbSourceTopology(replicaSetTopology(false, nil)),
+					WithMongoDbSourceConnectionFailed("ConnectionFailed", "Unable to list the databases: Error listing databases"),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "ConnectionFailed", "Unable to list the databases: Error listing databases"),
 				Eventf(corev1.EventTypeWarning, "InternalError",
-					"Error listing databases"),
+					"Unable to list the databases: Error listing databases"),
 			},
 		},
 		{
@@ -380,8 +555,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -399,9 +574,13 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					Databases: []string{"otherDb"},
+					DbData:    mongotesting.TestDbData{RunCommandResult: replicaSet},
 				},
 			},
 			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
@@ -409,8 +588,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -418,12 +597,15 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed(fmt.Sprintf(`database %q not found in available databases`, db)),
+					WithMongoDbSourceTopology(replicaSetTopology(false, nil)),
+					WithMongoDbSourceConnectionFailed("DatabaseNotFound", fmt.Sprintf(`The MongoDb database does not exist: database %q not found in available databases`, db)),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "DatabaseNotFound", fmt.Sprintf(`The MongoDb database does not exist: database %q not found in available databases`, db)),
 				Eventf(corev1.EventTypeWarning, "InternalError",
-					fmt.Sprintf(`database %q not found in available databases`, db)),
+					fmt.Sprintf(`The MongoDb database does not exist: database %q not found in available databases`, db)),
 			},
 		},
 		{
@@ -434,8 +616,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -453,11 +635,15 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					Databases: []string{"otherDb", db},
 					DbData: mongotesting.TestDbData{
-						ListCollErr: errors.New("Error listing collections"),
+						RunCommandResult: replicaSet,
+						ListCollErr:      errors.New("Error listing collections"),
 					},
 				},
 			},
@@ -466,8 +652,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -475,12 +661,15 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed("Error listing collections"),
+					WithMongoDbSourceTopology(replicaSetTopology(true, nil)),
+					WithMongoDbSourceConnectionFailed("ConnectionFailed", "Unable to list the collections: Error listing collections"),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "ConnectionFailed", "Unable to list the collections: Error listing collections"),
 				Eventf(corev1.EventTypeWarning, "InternalError",
-					"Error listing collections"),
+					"Unable to list the collections: Error listing collections"),
 			},
 		},
 		{
@@ -491,8 +680,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -510,11 +699,78 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					Databases: []string{"otherDb", db},
 					DbData: mongotesting.TestDbData{
-						Collections: []string{"otherColl"},
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl"},
+					},
+				},
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(false))),
+					WithMongoDbSourceConnectionFailed("CollectionNotFound", fmt.Sprintf(`The MongoDb collection does not exist: collection %q not found in available collections`, coll)),
+				),
+			}},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "CollectionNotFound", fmt.Sprintf(`The MongoDb collection does not exist: collection %q not found in available collections`, coll)),
+				Eventf(corev1.EventTypeWarning, "InternalError",
+					fmt.Sprintf(`The MongoDb collection does not exist: collection %q not found in available collections`, coll)),
+			},
+		},
+		{
+			Name:    "standalone server without change streams",
+			WantErr: true,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+			},
+			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: bson.M{"ismaster": true},
 					},
 				},
 			},
@@ -523,8 +779,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -532,12 +788,89 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
-					WithMongoDbSourceConnectionFailed(fmt.Sprintf(`collection %q not found in available collections`, coll)),
+					WithMongoDbSourceTopology(&sourcesv1alpha1.MongoDbSourceTopology{Type: sourcesv1alpha1.TopologyStandalone, DatabaseExists: true}),
+					WithMongoDbSourceConnectionFailed("NotReplicaSet", `The MongoDb server is not a replica set or a sharded cluster: the MongoDb server is a standalone server, which does not support change streams: deploy a replica set or set spec.mode to "poll"`),
 				),
 			}},
 			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "NotReplicaSet", `The MongoDb server is not a replica set or a sharded cluster: the MongoDb server is a standalone server, which does not support change streams: deploy a replica set or set spec.mode to "poll"`),
 				Eventf(corev1.EventTypeWarning, "InternalError",
-					fmt.Sprintf(`collection %q not found in available collections`, coll)),
+					`The MongoDb server is not a replica set or a sharded cluster: the MongoDb server is a standalone server, which does not support change streams: deploy a replica set or set spec.mode to "poll"`),
+			},
+		},
+		{
+			Name:    "server too old for change streams",
+			WantErr: true,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+			},
+			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						Collections:      []string{coll},
+						RunCommandResult: replicaSet,
+						CommandResults: map[string]bson.M{
+							"buildInfo": {"version": "3.4.0", "versionArray": bson.A{3, 4, 0, 0}},
+						},
+					},
+				},
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(&sourcesv1alpha1.MongoDbSourceTopology{
+						Type:             sourcesv1alpha1.TopologyReplicaSet,
+						ReplicaSet:       "rs0",
+						ServerVersion:    "3.4.0",
+						Hosts:            []string{"mongo-0:27017", "mongo-1:27017"},
+						DatabaseExists:   true,
+						CollectionExists: ptr.Bool(true),
+					}),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamNotSupported("PrerequisitesMissing", "the MongoDb server or user miss prerequisites of the source: the server version 3.4.0 is older than 3.6, which the source needs"),
+				),
+			}},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeWarning, "PrerequisitesMissing", "the MongoDb server or user miss prerequisites of the source: the server version 3.4.0 is older than 3.6, which the source needs"),
+				Eventf(corev1.EventTypeWarning, "InternalError", "the MongoDb server or user miss prerequisites of the source: the server version 3.4.0 is older than 3.6, which the source needs"),
 			},
 		},
 		{
@@ -548,8 +881,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -567,11 +900,18 @@ func TestAllCases(t *testing.T) {
 				},
 			},
 			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					Databases: []string{"otherDb", db},
 					DbData: mongotesting.TestDbData{
-						Collections: []string{"otherColl", coll},
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
 					},
 				},
 			},
@@ -580,8 +920,8 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
 						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 					}),
@@ -589,26 +929,32 @@ func TestAllCases(t *testing.T) {
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
 					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
 					WithMongoDbSourceNotDeployed(fmt.Sprintf("mongodbsource-%s-%s", sourceName, sourceUID)),
 				),
 			}},
 			WantCreates: []runtime.Object{
+				makeCheckpointConfigMap(),
+				makeRole(),
+				makeRoleBinding(),
 				makeReceiveAdapter(t),
 			},
 		},
 		{
-			Name:    "valid",
+			Name:    "wait for the collection to be created",
 			WantErr: false,
 			Objects: []runtime.Object{
 				NewMongoDbSource(sourceName, testNS,
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
-						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+						SourceSpec:               duckv1.SourceSpec{Sink: newSinkDestination()},
+						RequireExistingNamespace: ptr.Bool(false),
 					}),
 					WithMongoDbSourceUID(sourceUID),
 				),
@@ -622,14 +968,20 @@ func TestAllCases(t *testing.T) {
 						"URI": []byte(validURI),
 					},
 				},
-				makeAvailableReceiveAdapter(t),
 			},
 			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
 			OtherTestData: map[string]interface{}{
 				"mongo": mongotesting.TestClientData{
 					Databases: []string{"otherDb", db},
 					DbData: mongotesting.TestDbData{
-						Collections: []string{"otherColl", coll},
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl"},
 					},
 				},
 			},
@@ -638,58 +990,1094 @@ func TestAllCases(t *testing.T) {
 					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 						Database:   db,
 						Collection: coll,
-						Secret: corev1.LocalObjectReference{
-							Name: secretName,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 						},
-						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+						SourceSpec:               duckv1.SourceSpec{Sink: newSinkDestination()},
+						RequireExistingNamespace: ptr.Bool(false),
 					}),
 					WithMongoDbSourceUID(sourceUID),
 					// Status Update:
 					WithInitMongoDbSourceConditions,
 					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(false))),
 					WithMongoDbSourceConnectionSuccess(),
-					WithMongoDbSourceDeployed(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceNamespacePending("CollectionNotFound", `The MongoDb collection "coll" does not exist yet`),
+					WithMongoDbSourceNotDeployed(fmt.Sprintf("mongodbsource-%s-%s", sourceName, sourceUID)),
 				),
 			}},
-		},
-	}
-
-	defer logtesting.ClearAll()
-	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, testData map[string]interface{}) controller.Reconciler {
-		ctx = addressable.WithDuck(ctx)
-		r := &Reconciler{
-			kubeClientSet:       fakekubeclient.Get(ctx),
-			deploymentLister:    listers.GetDeploymentLister(),
-			secretLister:        listers.GetSecretLister(),
-			receiveAdapterImage: testRAImage,
-			configs:             &reconcilersource.EmptyVarsGenerator{},
-			sinkResolver:        resolver.NewURIResolver(ctx, func(types.NamespacedName) {}),
-			createClientFn:      mongotesting.TestClientCreator(testData["mongo"]),
-		}
-
-		return mongodbsource.NewReconciler(ctx, logging.FromContext(ctx), fakesourcesclient.Get(ctx), listers.GetMongoDbSourceLister(), controller.GetEventRecorder(ctx), r)
-	}))
-}
-
-// newSink returns an unstructured v1.Service which is special-cased for resolving the URI.
-func newSink() *unstructured.Unstructured {
-	return &unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": "v1",
-			"kind":       "Service",
-			"metadata": map[string]interface{}{
-				"namespace": testNS,
-				"name":      sinkName,
+			WantCreates: []runtime.Object{
+				makeCheckpointConfigMap(),
+				makeRole(),
+				makeRoleBinding(),
+				makeReceiveAdapter(t),
 			},
 		},
-	}
-}
-
-// newSinkDestination returns an unstructured v1.Service which is special-cased for resolving the URI.
-func newSinkDestination() duckv1.Destination {
-	return duckv1.Destination{
-		Ref: &duckv1.KReference{
-			APIVersion: "v1",
+		{
+			Name:    "valid",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				makeCheckpointConfigMap(),
+				makeRole(),
+				makeRoleBinding(),
+				makeAvailableReceiveAdapter(t),
+			},
+			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceDeployed(),
+				),
+			}},
+		},
+		{
+			Name:    "watch a cluster scoped source from the multi-tenant receive adapter",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceAnnotations(map[string]string{sourcesv1alpha1.ScopeAnnotation: sourcesv1alpha1.ScopeCluster}),
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				makeCheckpointConfigMap(),
+				makeAvailableReceiveAdapter(t),
+				NewDeployment(resources.MultiTenantAdapterName, system.Namespace(), WithDeploymentAvailable()),
+			},
+			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantDeletes: []clientgotesting.DeleteActionImpl{{
+				ActionImpl: clientgotesting.ActionImpl{
+					Namespace: testNS,
+					Verb:      "delete",
+					Resource:  appsv1.SchemeGroupVersion.WithResource("deployments"),
+				},
+				Name: makeReceiveAdapter(t).Name,
+			}},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceAnnotations(map[string]string{sourcesv1alpha1.ScopeAnnotation: sourcesv1alpha1.ScopeCluster}),
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceDeployed(),
+				),
+			}},
+		},
+		{
+			Name:    "cluster scoped source without multi-tenant receive adapter",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceAnnotations(map[string]string{sourcesv1alpha1.ScopeAnnotation: sourcesv1alpha1.ScopeCluster}),
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				makeCheckpointConfigMap(),
+			},
+			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceAnnotations(map[string]string{sourcesv1alpha1.ScopeAnnotation: sourcesv1alpha1.ScopeCluster}),
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceNoDeployment("MultiTenantAdapterNotFound",
+						fmt.Sprintf("The multi-tenant receive adapter %s/%s does not exist", system.Namespace(), resources.MultiTenantAdapterName)),
+				),
+			}},
+		},
+		{
+			Name:    "suspended source",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+						Suspend:    true,
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// The database is not checked while the source is suspended.
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceDeployed(),
+				),
+				makeCheckpointConfigMap(),
+				makeAvailableReceiveAdapter(t),
+			},
+			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeNormal, "Suspended", "The receive adapters are stopped"),
+			},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: func() *appsv1.Deployment {
+					ra := makeAvailableReceiveAdapter(t)
+					ra.Spec.Replicas = ptr.Int32(0)
+					return ra
+				}(),
+			}},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+						Suspend:    true,
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceSuspended(),
+				),
+			}},
+		},
+		{
+			Name:    "resumed source",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+						Suspend:    false,
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceSuspended(),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				makeCheckpointConfigMap(),
+				makeRole(),
+				makeRoleBinding(),
+				func() *appsv1.Deployment {
+					ra := makeAvailableReceiveAdapter(t)
+					ra.Spec.Replicas = ptr.Int32(0)
+					return ra
+				}(),
+			},
+			Key: testNS + "/" + sourceName,
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+				Eventf(corev1.EventTypeNormal, "Resumed", "The receive adapters are started again"),
+			},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: makeAvailableReceiveAdapter(t),
+			}},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+						Suspend:    false,
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceDeployed(),
+				),
+			}},
+		},
+		{
+			Name:    "history lost",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				func() *corev1.ConfigMap {
+					cm := makeCheckpointConfigMap()
+					cm.Data = map[string]string{checkpoint.DefaultKey: `{"resumeToken":"{\"_data\":\"01\"}","historyLost":{"time":"2020-08-01T00:00:00Z","policy":"fail","message":"resume point lost"}}`}
+					return cm
+				}(),
+				makeRole(),
+				makeRoleBinding(),
+				makeAvailableReceiveAdapter(t),
+			},
+			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceDeployed(),
+					WithMongoDbSourceHistoryLost(sourcesv1alpha1.HistoryLostFail,
+						`The change stream history was lost at 2020-08-01T00:00:00Z and the "fail" policy applied: resume point lost`),
+				),
+			}},
+		},
+		{
+			Name:    "update a drifted receive adapter, the credentials change does not roll it out",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            secretName,
+						Namespace:       testNS,
+						ResourceVersion: "2",
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				makeCheckpointConfigMap(),
+				makeRole(),
+				makeRoleBinding(),
+				func() *appsv1.Deployment {
+					ra := makeAvailableReceiveAdapter(t)
+					ra.Spec.Template.Spec.Containers[0].Env[0].Value = "http://old-sink/"
+					return ra
+				}(),
+			},
+			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: func() *appsv1.Deployment {
+					ra := makeReceiveAdapter(t)
+					WithDeploymentAvailable()(ra)
+					return ra
+				}(),
+			}},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceDeployed(),
+				),
+			}},
+		},
+		{
+			Name:    "update outdated receive adapter permissions",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+				),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				makeCheckpointConfigMap(),
+				func() *rbacv1.Role {
+					role := makeRole()
+					role.Rules[0].Verbs = []string{"get"}
+					return role
+				}(),
+				func() *rbacv1.RoleBinding {
+					binding := makeRoleBinding()
+					binding.Subjects[0].Name = "old-service-account"
+					return binding
+				}(),
+				makeAvailableReceiveAdapter(t),
+			},
+			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: makeRole(),
+			}, {
+				Object: makeRoleBinding(),
+			}},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, ptr.Bool(true))),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceDeployed(),
+				),
+			}},
+		},
+		{
+			Name:    "partition the database",
+			WantErr: false,
+			Objects: []runtime.Object{
+				makePartitionedSource(),
+				newSink(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      secretName,
+						Namespace: testNS,
+					},
+					Data: map[string][]byte{
+						"URI": []byte(validURI),
+					},
+				},
+				func() *corev1.ConfigMap {
+					cm := makeCheckpointConfigMap()
+					cm.Data = map[string]string{checkpoint.DefaultKey: testCheckpoint}
+					return cm
+				}(),
+				makeRole(),
+				makeRoleBinding(),
+				makeAvailableReceiveAdapter(t),
+			},
+			Key: testNS + "/" + sourceName,
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchFinalizers(testNS, sourceName),
+			},
+			OtherTestData: map[string]interface{}{
+				"mongo": mongotesting.TestClientData{
+					Databases: []string{"otherDb", db},
+					DbData: mongotesting.TestDbData{
+						RunCommandResult: replicaSet,
+						Collections:      []string{"otherColl", coll},
+					},
+				},
+			},
+			WantUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: func() *corev1.ConfigMap {
+					cm := makeCheckpointConfigMap()
+					cm.Data = map[string]string{
+						checkpoint.LayoutKey: `{"epoch":1,"collections":[["coll"],["otherColl"]]}`,
+						"checkpoint-1-0":     testCheckpoint,
+						"checkpoint-1-1":     testCheckpoint,
+					}
+					return cm
+				}(),
+			}, {
+				Object: resources.MakeRole(makePartitionedSource(), resources.Labels(sourceName)),
+			}},
+			WantCreates: []runtime.Object{
+				makePartitionReceiveAdapter(t, resources.Partition{Index: 0, Collections: []string{coll}, CheckpointKey: "checkpoint-1-0"}),
+				makePartitionReceiveAdapter(t, resources.Partition{Index: 1, Collections: []string{"otherColl"}, CheckpointKey: "checkpoint-1-1"}),
+			},
+			WantDeletes: []clientgotesting.DeleteActionImpl{{
+				ActionImpl: clientgotesting.ActionImpl{
+					Namespace: testNS,
+					Verb:      "delete",
+					Resource:  appsv1.SchemeGroupVersion.WithResource("deployments"),
+				},
+				Name: makeReceiveAdapter(t).Name,
+			}},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(makePartitionedSource().Spec),
+					WithMongoDbSourceUID(sourceUID),
+					// Status Update:
+					WithInitMongoDbSourceConditions,
+					WithMongoDbSourceSink(sinkURI),
+					WithMongoDbSourceTopology(replicaSetTopology(true, nil)),
+					WithMongoDbSourceConnectionSuccess(),
+					WithMongoDbSourceChangeStreamSupported(),
+					WithMongoDbSourceNotDeployed(fmt.Sprintf("mongodbsource-%s-%s-0", sourceName, sourceUID)),
+					WithMongoDbSourcePartitions(sourcesv1alpha1.MongoDbSourcePartition{
+						Index:       0,
+						Deployment:  fmt.Sprintf("mongodbsource-%s-%s-0", sourceName, sourceUID),
+						Collections: []string{coll},
+					}, sourcesv1alpha1.MongoDbSourcePartition{
+						Index:       1,
+						Deployment:  fmt.Sprintf("mongodbsource-%s-%s-1", sourceName, sourceUID),
+						Collections: []string{"otherColl"},
+					}),
+				),
+			}},
+		},
+		{
+			Name:    "stop the receive adapters of a deleted source",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					WithMongoDbSourceFinalizers(finalizerName),
+					WithMongoDbSourceDeleted,
+				),
+				makeCheckpointConfigMap(),
+				makeAvailableReceiveAdapter(t),
+			},
+			Key: testNS + "/" + sourceName,
+			WantDeletes: []clientgotesting.DeleteActionImpl{{
+				ActionImpl: clientgotesting.ActionImpl{
+					Namespace: testNS,
+					Verb:      "delete",
+					Resource:  appsv1.SchemeGroupVersion.WithResource("deployments"),
+				},
+				Name: makeReceiveAdapter(t).Name,
+			}},
+			WantEvents: []string{
+				Eventf(corev1.EventTypeWarning, "ReceiveAdaptersStopping", "Waiting for the receive adapters to stop"),
+			},
+		},
+		{
+			Name:    "delete the checkpoints and leases of a deleted source",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec:               duckv1.SourceSpec{Sink: newSinkDestination()},
+						RetainCheckpointOnDelete: false,
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					WithMongoDbSourceFinalizers(finalizerName),
+					WithMongoDbSourceDeleted,
+				),
+				makeCheckpointConfigMap(),
+			},
+			Key: testNS + "/" + sourceName,
+			WantDeleteCollections: []clientgotesting.DeleteCollectionActionImpl{
+				deleteLeases(),
+			},
+			WantDeletes: []clientgotesting.DeleteActionImpl{{
+				ActionImpl: clientgotesting.ActionImpl{
+					Namespace: testNS,
+					Verb:      "delete",
+					Resource:  corev1.SchemeGroupVersion.WithResource("configmaps"),
+				},
+				Name: makeCheckpointConfigMap().Name,
+			}},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchRemoveFinalizers(testNS, sourceName),
+			},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+		},
+		{
+			Name:    "retain the checkpoints of a deleted source",
+			WantErr: false,
+			Objects: []runtime.Object{
+				NewMongoDbSource(sourceName, testNS,
+					WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+						Database:   db,
+						Collection: coll,
+						Secret: sourcesv1alpha1.MongoDbSourceSecret{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						},
+						SourceSpec:               duckv1.SourceSpec{Sink: newSinkDestination()},
+						RetainCheckpointOnDelete: true,
+					}),
+					WithMongoDbSourceUID(sourceUID),
+					WithMongoDbSourceFinalizers(finalizerName),
+					WithMongoDbSourceDeleted,
+				),
+				func() *corev1.ConfigMap {
+					cm := makeCheckpointConfigMap()
+					cm.Data = map[string]string{checkpoint.DefaultKey: testCheckpoint}
+					return cm
+				}(),
+				makeSecret(),
+			},
+			Key: testNS + "/" + sourceName,
+			WantCreates: []runtime.Object{
+				makeRetainedCheckpointConfigMap(),
+			},
+			WantDeleteCollections: []clientgotesting.DeleteCollectionActionImpl{
+				deleteLeases(),
+			},
+			WantDeletes: []clientgotesting.DeleteActionImpl{{
+				ActionImpl: clientgotesting.ActionImpl{
+					Namespace: testNS,
+					Verb:      "delete",
+					Resource:  corev1.SchemeGroupVersion.WithResource("configmaps"),
+				},
+				Name: makeCheckpointConfigMap().Name,
+			}},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				patchRemoveFinalizers(testNS, sourceName),
+			},
+			WantEvents: []string{
+				finalizerUpdatedEvent,
+			},
+		},
+	}
+
+	defer logtesting.ClearAll()
+	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, testData map[string]interface{}) controller.Reconciler {
+		ctx = addressable.WithDuck(ctx)
+		r := &Reconciler{
+			kubeClientSet:       fakekubeclient.Get(ctx),
+			deploymentLister:    listers.GetDeploymentLister(),
+			configMapLister:     listers.GetConfigMapLister(),
+			roleLister:          listers.GetRoleLister(),
+			roleBindingLister:   listers.GetRoleBindingLister(),
+			secretLister:        listers.GetSecretLister(),
+			receiveAdapterImage: testRAImage,
+			configs:             &reconcilersource.EmptyVarsGenerator{},
+			sinkResolver:        resolver.NewURIResolver(ctx, func(types.NamespacedName) {}),
+			createClientFn:      mongotesting.TestClientCreator(testData["mongo"]),
+			tracker:             &FakeTracker{},
+			enqueueAfter:        func(types.NamespacedName, time.Duration) {},
+		}
+
+		return mongodbsource.NewReconciler(ctx, logging.FromContext(ctx), fakesourcesclient.Get(ctx), listers.GetMongoDbSourceLister(), controller.GetEventRecorder(ctx), r)
+	}))
+}
+
+func TestConnectionEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	ctx := controller.WithEventRecorder(context.Background(), recorder)
+	src := makeSource()
+	src.Status.InitializeConditions()
+
+	markConnectionFailed(ctx, src, reasonServerUnreachable, "Unable to reach the MongoDb servers")
+	markConnectionFailed(ctx, src, reasonServerUnreachable, "Unable to reach the MongoDb servers")
+	markConnectionFailed(ctx, src, reasonAuthenticationFailed, "Authentication to MongoDb failed")
+	markConnectionSuccess(ctx, src)
+	markConnectionSuccess(ctx, src)
+	close(recorder.Events)
+
+	var got []string
+	for event := range recorder.Events {
+		got = append(got, event)
+	}
+	want := []string{
+		"Warning ServerUnreachable Unable to reach the MongoDb servers",
+		"Warning AuthenticationFailed Authentication to MongoDb failed",
+		"Normal ConnectionEstablished Connected to MongoDb",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected events (-want +got) %s", diff)
+	}
+}
+
+func TestChangeStreamEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	ctx := controller.WithEventRecorder(context.Background(), recorder)
+	src := makeSource()
+	src.Status.InitializeConditions()
+
+	markChangeStreamSupported(ctx, src)
+	markChangeStreamNotSupported(ctx, src, "missing find")
+	markChangeStreamNotSupported(ctx, src, "missing find")
+	markChangeStreamNotSupported(ctx, src, "missing changeStream")
+	markChangeStreamSupported(ctx, src)
+	close(recorder.Events)
+
+	var got []string
+	for event := range recorder.Events {
+		got = append(got, event)
+	}
+	want := []string{
+		"Warning PrerequisitesMissing missing find",
+		"Warning PrerequisitesMissing missing changeStream",
+		"Normal PrerequisitesMet The MongoDb server and user meet the prerequisites of the source",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected events (-want +got) %s", diff)
+	}
+}
+
+func TestCheckDatabaseTopology(t *testing.T) {
+	seeds := []string{"mongos-0:27017", "mongos-1:27017"}
+	tests := []struct {
+		name     string
+		isMaster bson.M
+		want     *sourcesv1alpha1.MongoDbSourceTopology
+	}{{
+		name:     "replica set",
+		isMaster: bson.M{"setName": "rs0", "hosts": bson.A{"mongo-0:27017"}, "passives": bson.A{"mongo-1:27017"}, "arbiters": bson.A{"mongo-2:27017"}, "version": "4.2.0"},
+		want: &sourcesv1alpha1.MongoDbSourceTopology{
+			Type:             sourcesv1alpha1.TopologyReplicaSet,
+			ReplicaSet:       "rs0",
+			ServerVersion:    "4.2.0",
+			Hosts:            []string{"mongo-0:27017", "mongo-1:27017", "mongo-2:27017"},
+			DatabaseExists:   true,
+			CollectionExists: ptr.Bool(true),
+		},
+	}, {
+		name:     "sharded cluster",
+		isMaster: bson.M{"msg": "isdbgrid", "version": "4.4.0"},
+		want: &sourcesv1alpha1.MongoDbSourceTopology{
+			Type:             sourcesv1alpha1.TopologySharded,
+			ServerVersion:    "4.4.0",
+			Hosts:            seeds,
+			DatabaseExists:   true,
+			CollectionExists: ptr.Bool(true),
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := mongotesting.TestClientCreator(mongotesting.TestClientData{
+				Databases: []string{db},
+				DbData: mongotesting.TestDbData{
+					Collections:      []string{coll},
+					RunCommandResult: test.isMaster,
+				},
+			})()
+			_, got, err := checkDatabase(context.Background(), client, makeSource(), seeds)
+			if err != nil {
+				t.Fatalf("checkDatabase got error %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Unexpected topology (-want +got) %s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncPodTemplate(t *testing.T) {
+	expected := makeReceiveAdapter(t).Spec.Template
+
+	// The fields defaulted by the API server and the other annotations are kept.
+	defaulted := expected.DeepCopy()
+	defaulted.Annotations["kubectl.kubernetes.io/restartedAt"] = "2020-08-01T00:00:00Z"
+	defaulted.Spec.DNSPolicy = corev1.DNSClusterFirst
+	defaulted.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+	defaulted.Spec.Containers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+	if syncPodTemplate(&expected, defaulted) {
+		t.Error("syncPodTemplate updated a pod template only differing by defaults")
+	}
+
+	drifted := defaulted.DeepCopy()
+	drifted.Spec.ServiceAccountName = "other"
+	if !syncPodTemplate(&expected, drifted) {
+		t.Fatal("syncPodTemplate did not update a drifted pod template")
+	}
+	if diff := cmp.Diff(expected.Spec, drifted.Spec); diff != "" {
+		t.Errorf("Unexpected pod spec (-want +got) %s", diff)
+	}
+	if got := drifted.Annotations["kubectl.kubernetes.io/restartedAt"]; got == "" {
+		t.Error("syncPodTemplate dropped the other annotations")
+	}
+}
+
+func TestTrackSecrets(t *testing.T) {
+	src := makeSource()
+	src.Spec.TLS = &sourcesv1alpha1.MongoDbSourceTLS{
+		CA: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "mongo-ca"}, Key: "ca.crt"},
+	}
+	fakeTracker := &FakeTracker{}
+	r := &Reconciler{tracker: fakeTracker}
+	if err := r.trackSecrets(src); err != nil {
+		t.Fatalf("trackSecrets got error %v", err)
+	}
+
+	want := []tracker.Reference{
+		{APIVersion: "v1", Kind: "Secret", Namespace: testNS, Name: secretName},
+		{APIVersion: "v1", Kind: "Secret", Namespace: testNS, Name: "mongo-ca"},
+	}
+	less := func(a, b tracker.Reference) bool { return a.Name < b.Name }
+	if diff := cmp.Diff(want, fakeTracker.References(), cmpopts.SortSlices(less)); diff != "" {
+		t.Errorf("Unexpected tracked secrets (-want +got) %s", diff)
+	}
+}
+
+func TestRetainedCheckpoint(t *testing.T) {
+	ctx := logtesting.TestContextWithLogger(t)
+	current := makeCheckpointConfigMap()
+	current.Data = map[string]string{checkpoint.DefaultKey: testCheckpoint}
+	kubeClient := fakekubeclientset.NewSimpleClientset(current)
+	// reconciler returns a Reconciler listing the current ConfigMaps of kubeClient.
+	reconciler := func() *Reconciler {
+		t.Helper()
+		cms, err := kubeClient.CoreV1().ConfigMaps(testNS).List(metav1.ListOptions{})
+		require.NoError(t, err)
+		objs := []runtime.Object{makeSecret()}
+		for i := range cms.Items {
+			objs = append(objs, &cms.Items[i])
+		}
+		listers := NewListers(objs)
+		return &Reconciler{
+			kubeClientSet:    kubeClient,
+			deploymentLister: listers.GetDeploymentLister(),
+			configMapLister:  listers.GetConfigMapLister(),
+			secretLister:     listers.GetSecretLister(),
+		}
+	}
+
+	// The deleted source retains its checkpoints.
+	deleted := makeSource()
+	deleted.Spec.RetainCheckpointOnDelete = true
+	if event := reconciler().FinalizeKind(ctx, deleted); event != nil {
+		t.Fatalf("FinalizeKind got %v", event)
+	}
+	if _, err := kubeClient.CoreV1().ConfigMaps(testNS).Get(current.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("The checkpoint ConfigMap of the deleted source was not deleted: %v", err)
+	}
+
+	// A source recreated with the same name but watching another collection does not.
+	other := makeSource()
+	other.UID = "1234"
+	other.Spec.Collection = "other"
+	if _, err := reconciler().reconcileCheckpoint(ctx, other, nil); err != nil {
+		t.Fatalf("reconcileCheckpoint got error %v", err)
+	}
+	got, err := kubeClient.CoreV1().ConfigMaps(testNS).Get(checkpoint.ConfigMapName(other), metav1.GetOptions{})
+	require.NoError(t, err)
+	if len(got.Data) != 0 {
+		t.Errorf("The source watching another collection adopted the retained checkpoints: %v", got.Data)
+	}
+	if _, err := kubeClient.CoreV1().ConfigMaps(testNS).Get(resources.RetainedCheckpointConfigMapName(other), metav1.GetOptions{}); err != nil {
+		t.Errorf("The retained checkpoint ConfigMap was deleted without being adopted: %v", err)
+	}
+
+	// The source recreated with the same name and stream resumes from them.
+	recreated := makeSource()
+	recreated.UID = "5678"
+	if _, err := reconciler().reconcileCheckpoint(ctx, recreated, nil); err != nil {
+		t.Fatalf("reconcileCheckpoint got error %v", err)
+	}
+	got, err = kubeClient.CoreV1().ConfigMaps(testNS).Get(checkpoint.ConfigMapName(recreated), metav1.GetOptions{})
+	require.NoError(t, err)
+	if !metav1.IsControlledBy(got, recreated) {
+		t.Errorf("The checkpoint ConfigMap is not controlled by the recreated source: %v", got.OwnerReferences)
+	}
+	if diff := cmp.Diff(current.Data, got.Data); diff != "" {
+		t.Errorf("Unexpected checkpoints of the recreated source (-want +got) %s", diff)
+	}
+	if _, err := kubeClient.CoreV1().ConfigMaps(testNS).Get(resources.RetainedCheckpointConfigMapName(recreated), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("The retained checkpoint ConfigMap was not deleted once adopted: %v", err)
+	}
+}
+
+// replicaSetTopology returns the topology of the replicaSet server.
+func replicaSetTopology(databaseExists bool, collectionExists *bool) *sourcesv1alpha1.MongoDbSourceTopology {
+	return &sourcesv1alpha1.MongoDbSourceTopology{
+		Type:             sourcesv1alpha1.TopologyReplicaSet,
+		ReplicaSet:       "rs0",
+		ServerVersion:    "4.4.1",
+		Hosts:            []string{"mongo-0:27017", "mongo-1:27017"},
+		DatabaseExists:   databaseExists,
+		CollectionExists: collectionExists,
+	}
+}
+
+// newSink returns an unstructured v1.Service which is special-cased for resolving the URI.
+func newSink() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata": map[string]interface{}{
+				"namespace": testNS,
+				"name":      sinkName,
+			},
+		},
+	}
+}
+
+// newSinkDestination returns an unstructured v1.Service which is special-cased for resolving the URI.
+func newSinkDestination() duckv1.Destination {
+	return duckv1.Destination{
+		Ref: &duckv1.KReference{
+			APIVersion: "v1",
 			Kind:       "Service",
 			Name:       sinkName,
 			Namespace:  testNS,
@@ -697,6 +2085,100 @@ func newSinkDestination() duckv1.Destination {
 	}
 }
 
+func makeSource() *sourcesv1alpha1.MongoDbSource {
+	return NewMongoDbSource(sourceName, testNS,
+		WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+			Database:   db,
+			Collection: coll,
+			Secret: sourcesv1alpha1.MongoDbSourceSecret{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+			},
+			SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
+		}),
+		WithMongoDbSourceUID(sourceUID),
+	)
+}
+
+func makePartitionedSource() *sourcesv1alpha1.MongoDbSource {
+	replicas := int32(2)
+	return NewMongoDbSource(sourceName, testNS,
+		WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
+			Database: db,
+			Secret: sourcesv1alpha1.MongoDbSourceSecret{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+			},
+			Replicas:     &replicas,
+			Partitioning: sourcesv1alpha1.PartitionByCollections,
+			SourceSpec:   duckv1.SourceSpec{Sink: newSinkDestination()},
+		}),
+		WithMongoDbSourceUID(sourceUID),
+	)
+}
+
+func makePartitionReceiveAdapter(t *testing.T, partition resources.Partition) *appsv1.Deployment {
+	t.Helper()
+
+	src := makePartitionedSource()
+	src.Status.MarkSink(sinkURI)
+	ra, err := resources.MakeReceiveAdapter(&resources.ReceiveAdapterArgs{
+		Image:       testRAImage,
+		Source:      src,
+		Labels:      resources.PartitionLabels(sourceName, partition.Index),
+		ClusterName: "rs0",
+		SinkURL:     sinkURI.String(),
+		Configs:     &reconcilersource.EmptyVarsGenerator{},
+		Partition:   &partition,
+	})
+	require.NoError(t, err)
+
+	return ra
+}
+
+// deleteLeases returns the deletion of the Leases of the receive adapters of the source.
+func deleteLeases() clientgotesting.DeleteCollectionActionImpl {
+	return clientgotesting.DeleteCollectionActionImpl{
+		ActionImpl: clientgotesting.ActionImpl{
+			Namespace: testNS,
+			Verb:      "delete-collection",
+			Resource:  coordinationv1.SchemeGroupVersion.WithResource("leases"),
+		},
+		ListRestrictions: clientgotesting.ListRestrictions{
+			Labels: labels.SelectorFromSet(resources.Labels(sourceName)),
+			Fields: fields.Everything(),
+		},
+	}
+}
+
+func makeCheckpointConfigMap() *corev1.ConfigMap {
+	return resources.MakeCheckpointConfigMap(makeSource(), resources.Labels(sourceName))
+}
+
+func makeRetainedCheckpointConfigMap() *corev1.ConfigMap {
+	// The cluster is named after the host of validURI.
+	return resources.MakeRetainedCheckpointConfigMap(makeSource(), "valid", resources.Labels(sourceName),
+		map[string]string{checkpoint.DefaultKey: testCheckpoint})
+}
+
+func makeSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: testNS,
+		},
+		Data: map[string][]byte{
+			"URI": []byte(validURI),
+		},
+	}
+}
+
+func makeRole() *rbacv1.Role {
+	return resources.MakeRole(makeSource(), resources.Labels(sourceName))
+}
+
+func makeRoleBinding() *rbacv1.RoleBinding {
+	return resources.MakeRoleBinding(makeSource(), resources.Labels(sourceName))
+}
+
 func makeReceiveAdapterWithName(t *testing.T, sourceName string) *appsv1.Deployment {
 	t.Helper()
 
@@ -704,8 +2186,8 @@ func makeReceiveAdapterWithName(t *testing.T, sourceName string) *appsv1.Deploym
 		WithMongoDbSourceSpec(sourcesv1alpha1.MongoDbSourceSpec{
 			Database:   db,
 			Collection: coll,
-			Secret: corev1.LocalObjectReference{
-				Name: secretName,
+			Secret: sourcesv1alpha1.MongoDbSourceSecret{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
 			},
 			SourceSpec: duckv1.SourceSpec{Sink: newSinkDestination()},
 		}),
@@ -714,14 +2196,15 @@ func makeReceiveAdapterWithName(t *testing.T, sourceName string) *appsv1.Deploym
 		WithInitMongoDbSourceConditions,
 		WithMongoDbSourceSink(sinkURI),
 		WithMongoDbSourceConnectionSuccess(),
+		WithMongoDbSourceChangeStreamSupported(),
 	)
 	args := resources.ReceiveAdapterArgs{
-		Image:          testRAImage,
-		Source:         src,
-		Labels:         resources.Labels(sourceName),
-		CeSourcePrefix: validURI,
-		SinkURL:        sinkURI.String(),
-		Configs:        &reconcilersource.EmptyVarsGenerator{},
+		Image:       testRAImage,
+		Source:      src,
+		Labels:      resources.Labels(sourceName),
+		ClusterName: "rs0",
+		SinkURL:     sinkURI.String(),
+		Configs:     &reconcilersource.EmptyVarsGenerator{},
 	}
 
 	ra, err := resources.MakeReceiveAdapter(&args)
@@ -730,6 +2213,22 @@ func makeReceiveAdapterWithName(t *testing.T, sourceName string) *appsv1.Deploym
 	return ra
 }
 
+func patchFinalizers(namespace, name string) clientgotesting.PatchActionImpl {
+	action := clientgotesting.PatchActionImpl{}
+	action.Name = name
+	action.Namespace = namespace
+	action.Patch = []byte(`{"metadata":{"finalizers":["` + finalizerName + `"],"resourceVersion":""}}`)
+	return action
+}
+
+func patchRemoveFinalizers(namespace, name string) clientgotesting.PatchActionImpl {
+	action := clientgotesting.PatchActionImpl{}
+	action.Name = name
+	action.Namespace = namespace
+	action.Patch = []byte(`{"metadata":{"finalizers":[],"resourceVersion":""}}`)
+	return action
+}
+
 func makeReceiveAdapter(t *testing.T) *appsv1.Deployment {
 	return makeReceiveAdapterWithName(t, sourceName)
 }
@@ -0,0 +1,227 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Constants of the preflight checks.
+const (
+	// reasonPrerequisitesMissing is the reason of the ChangeStreamSupported condition when the
+	// server or user miss prerequisites of the source.
+	reasonPrerequisitesMissing = "PrerequisitesMissing"
+	// reasonPrerequisitesMet is the reason of the event sent when the prerequisites are met again.
+	reasonPrerequisitesMet = "PrerequisitesMet"
+
+	// oplogDatabase and oplogCollection hold the oplog read in oplog mode.
+	oplogDatabase   = "local"
+	oplogCollection = "oplog.rs"
+)
+
+// prerequisitesError lists the prerequisites of the source that the server or user miss.
+type prerequisitesError struct {
+	missing []string
+}
+
+// Error implements error.Error.
+func (e *prerequisitesError) Error() string {
+	return "the MongoDb server or user miss prerequisites of the source: " + strings.Join(e.missing, "; ")
+}
+
+// serverVersion is a MongoDb server version, as major and minor.
+type serverVersion [2]int32
+
+// String returns the version as "major.minor".
+func (v serverVersion) String() string {
+	return fmt.Sprintf("%d.%d", v[0], v[1])
+}
+
+// less returns whether v is older than o.
+func (v serverVersion) less(o serverVersion) bool {
+	return v[0] < o[0] || (v[0] == o[0] && v[1] < o[1])
+}
+
+// Server versions introducing the features used by the change streams.
+var (
+	// changeStreamVersion introduced the change streams on collections.
+	changeStreamVersion = serverVersion{3, 6}
+	// databaseChangeStreamVersion introduced the change streams on databases, and starting them at
+	// an operation time, as after a snapshot or when the history is lost.
+	databaseChangeStreamVersion = serverVersion{4, 0}
+)
+
+// requirement is an action the user needs on a resource: a collection, or all the collections of
+// a database if collection is empty.
+type requirement struct {
+	action     string
+	database   string
+	collection string
+}
+
+// String describes the requirement.
+func (r requirement) String() string {
+	if r.collection == "" {
+		return fmt.Sprintf("the %q action on the database %q", r.action, r.database)
+	}
+	return fmt.Sprintf("the %q action on the collection %q", r.action, r.database+"."+r.collection)
+}
+
+// privilege is a privilege of the authenticated user, as returned by connectionStatus.
+type privilege struct {
+	Resource struct {
+		DB          string `bson:"db"`
+		Collection  string `bson:"collection"`
+		Cluster     bool   `bson:"cluster"`
+		AnyResource bool   `bson:"anyResource"`
+	} `bson:"resource"`
+	Actions []string `bson:"actions"`
+}
+
+// allows returns whether the privilege grants the requirement.
+func (p privilege) allows(r requirement) bool {
+	if !stringInSlice(r.action, p.Actions) {
+		return false
+	}
+	if p.Resource.AnyResource {
+		return true
+	}
+	if p.Resource.Cluster {
+		return false
+	}
+	switch p.Resource.DB {
+	case r.database:
+	case "":
+		// The privileges on all the databases exclude the system databases.
+		if r.database == oplogDatabase || r.database == "config" {
+			return false
+		}
+	default:
+		return false
+	}
+	return p.Resource.Collection == "" || p.Resource.Collection == r.collection
+}
+
+// buildInfo is the version of the server, as returned by buildInfo.
+type buildInfo struct {
+	Version      string  `bson:"version"`
+	VersionArray []int32 `bson:"versionArray"`
+}
+
+// preflight checks that the server version and the privileges of the user support the mode of
+// src, and returns a prerequisitesError listing the prerequisites that are missing.
+func preflight(ctx context.Context, client mongoclient.Client, src *v1alpha1.MongoDbSource, info buildInfo) error {
+	admin := client.Database("admin")
+	var missing []string
+
+	// Check the server version, unless it is unknown.
+	if minimum := minimumVersion(src); minimum != nil && len(info.VersionArray) >= 2 {
+		version := serverVersion{info.VersionArray[0], info.VersionArray[1]}
+		if version.less(*minimum) {
+			missing = append(missing, fmt.Sprintf("the server version %s is older than %s, which the source needs", info.Version, minimum))
+		}
+	}
+
+	// Check the privileges of the user, unless the server does not authenticate the users.
+	raw, err := admin.RunCommand(ctx, bson.D{{Key: "connectionStatus", Value: 1}, {Key: "showPrivileges", Value: true}})
+	if err != nil {
+		return operationFailure("run connectionStatus", err)
+	}
+	var status struct {
+		AuthInfo struct {
+			AuthenticatedUsers []bson.Raw  `bson:"authenticatedUsers"`
+			Privileges         []privilege `bson:"authenticatedUserPrivileges"`
+		} `bson:"authInfo"`
+	}
+	if err := bson.Unmarshal(raw, &status); err != nil {
+		return operationFailure("decode connectionStatus", err)
+	}
+	if len(status.AuthInfo.AuthenticatedUsers) > 0 {
+		for _, r := range requirements(src) {
+			if !allowed(status.AuthInfo.Privileges, r) {
+				missing = append(missing, "the user lacks "+r.String())
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return &prerequisitesError{missing: missing}
+	}
+	return nil
+}
+
+// minimumVersion returns the oldest server version supporting the change stream of src, or nil if
+// src does not read a change stream.
+func minimumVersion(src *v1alpha1.MongoDbSource) *serverVersion {
+	if src.Spec.Mode != "" && src.Spec.Mode != v1alpha1.SourceModeChangeStream {
+		return nil
+	}
+	if src.Spec.Collection == "" || startsAtOperationTime(src) {
+		return &databaseChangeStreamVersion
+	}
+	return &changeStreamVersion
+}
+
+// startsAtOperationTime returns whether the change stream of src may be started at an operation
+// time: at the start time, after a snapshot, or once restarted after losing its history.
+func startsAtOperationTime(src *v1alpha1.MongoDbSource) bool {
+	if src.Spec.StartAt != nil && src.Spec.StartAt.Time != nil {
+		return true
+	}
+	if src.Spec.Snapshot != nil && src.Spec.Snapshot.Mode != v1alpha1.SnapshotNever {
+		return true
+	}
+	switch src.Spec.OnHistoryLost {
+	case v1alpha1.HistoryLostRestart, v1alpha1.HistoryLostSnapshot:
+		return true
+	}
+	return false
+}
+
+// requirements returns the actions the user needs to read the changes of src.
+func requirements(src *v1alpha1.MongoDbSource) []requirement {
+	db, coll := src.Spec.Database, src.Spec.Collection
+	switch src.Spec.Mode {
+	case v1alpha1.SourceModePoll:
+		return []requirement{{action: "find", database: db, collection: coll}}
+	case v1alpha1.SourceModeOplog:
+		return []requirement{
+			{action: "find", database: oplogDatabase, collection: oplogCollection},
+			{action: "find", database: db, collection: coll},
+		}
+	}
+	return []requirement{
+		{action: "changeStream", database: db, collection: coll},
+		{action: "find", database: db, collection: coll},
+	}
+}
+
+// allowed returns whether one of the privileges grants the requirement.
+func allowed(privileges []privilege, r requirement) bool {
+	for _, p := range privileges {
+		if p.allows(r) {
+			return true
+		}
+	}
+	return false
+}
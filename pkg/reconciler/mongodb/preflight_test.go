@@ -0,0 +1,164 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	sourcesv1alpha1 "github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	mongotesting "github.com/googleinterns/knative-source-mongodb/pkg/mongo/testing"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPreflight(t *testing.T) {
+	user := bson.A{bson.M{"user": "source", "db": "admin"}}
+	privileges := func(privileges ...bson.M) bson.M {
+		granted := bson.A{}
+		for _, p := range privileges {
+			granted = append(granted, p)
+		}
+		return bson.M{"authInfo": bson.M{"authenticatedUsers": user, "authenticatedUserPrivileges": granted}}
+	}
+	on := func(db, coll string, actions ...string) bson.M {
+		return bson.M{"resource": bson.M{"db": db, "collection": coll}, "actions": actions}
+	}
+	version := func(major, minor int32) buildInfo {
+		return buildInfo{Version: "x", VersionArray: []int32{major, minor, 0, 0}}
+	}
+
+	tests := []struct {
+		name          string
+		mode          sourcesv1alpha1.SourceMode
+		collection    string
+		snapshot      *sourcesv1alpha1.MongoDbSourceSnapshot
+		onHistoryLost sourcesv1alpha1.HistoryLostPolicy
+		buildInfo     buildInfo
+		status        bson.M
+		wantMissing   []string
+	}{
+		{
+			name:      "authentication disabled",
+			buildInfo: version(4, 4),
+			status:    bson.M{"authInfo": bson.M{"authenticatedUsers": bson.A{}}},
+		},
+		{
+			name:       "collection change stream",
+			collection: coll,
+			buildInfo:  version(3, 6),
+			status:     privileges(on(db, coll, "changeStream", "find")),
+		},
+		{
+			name:       "collection change stream after a snapshot on an old server",
+			collection: coll,
+			snapshot:   &sourcesv1alpha1.MongoDbSourceSnapshot{Mode: sourcesv1alpha1.SnapshotInitial},
+			buildInfo:  version(3, 6),
+			status:     privileges(on(db, coll, "changeStream", "find")),
+			wantMissing: []string{
+				`the server version x is older than 4.0, which the source needs`,
+			},
+		},
+		{
+			name:       "collection change stream never snapshotted on an old server",
+			collection: coll,
+			snapshot:   &sourcesv1alpha1.MongoDbSourceSnapshot{Mode: sourcesv1alpha1.SnapshotNever},
+			buildInfo:  version(3, 6),
+			status:     privileges(on(db, coll, "changeStream", "find")),
+		},
+		{
+			name:          "collection change stream restarted on lost history on an old server",
+			collection:    coll,
+			onHistoryLost: sourcesv1alpha1.HistoryLostRestart,
+			buildInfo:     version(3, 6),
+			status:        privileges(on(db, coll, "changeStream", "find")),
+			wantMissing: []string{
+				`the server version x is older than 4.0, which the source needs`,
+			},
+		},
+		{
+			name:          "collection change stream snapshotted on lost history",
+			collection:    coll,
+			onHistoryLost: sourcesv1alpha1.HistoryLostSnapshot,
+			buildInfo:     version(4, 0),
+			status:        privileges(on(db, coll, "changeStream", "find")),
+		},
+		{
+			name:          "collection change stream failing on lost history on an old server",
+			collection:    coll,
+			onHistoryLost: sourcesv1alpha1.HistoryLostFail,
+			buildInfo:     version(3, 6),
+			status:        privileges(on(db, coll, "changeStream", "find")),
+		},
+		{
+			name:      "all the databases",
+			buildInfo: version(4, 0),
+			status:    privileges(on("", "", "changeStream", "find", "listCollections")),
+		},
+		{
+			name:      "database change stream on an old server without privileges",
+			buildInfo: version(3, 6),
+			status:    privileges(on(db, coll, "changeStream", "find"), on("other", "", "changeStream")),
+			wantMissing: []string{
+				`the server version x is older than 4.0, which the source needs`,
+				`the user lacks the "changeStream" action on the database "db"`,
+				`the user lacks the "find" action on the database "db"`,
+			},
+		},
+		{
+			name:       "oplog without privileges on the oplog",
+			mode:       sourcesv1alpha1.SourceModeOplog,
+			collection: coll,
+			buildInfo:  version(3, 2),
+			status:     privileges(on("", "", "find")),
+			wantMissing: []string{
+				`the user lacks the "find" action on the collection "local.oplog.rs"`,
+			},
+		},
+		{
+			name:      "poll with any resource",
+			mode:      sourcesv1alpha1.SourceModePoll,
+			buildInfo: version(3, 0),
+			status:    privileges(bson.M{"resource": bson.M{"anyResource": true}, "actions": bson.A{"find"}}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := makeSource()
+			src.Spec.Mode, src.Spec.Collection = test.mode, test.collection
+			src.Spec.Snapshot, src.Spec.OnHistoryLost = test.snapshot, test.onHistoryLost
+			client, _ := mongotesting.TestClientCreator(mongotesting.TestClientData{
+				DbData: mongotesting.TestDbData{
+					CommandResults: map[string]bson.M{"connectionStatus": test.status},
+				},
+			})()
+
+			var gotMissing []string
+			err := preflight(context.Background(), client, src, test.buildInfo)
+			if err != nil {
+				prerequisitesErr, ok := err.(*prerequisitesError)
+				if !ok {
+					t.Fatalf("preflight got error %v", err)
+				}
+				gotMissing = prerequisitesErr.missing
+			}
+			if diff := cmp.Diff(test.wantMissing, gotMissing); diff != "" {
+				t.Errorf("Unexpected missing prerequisites (-want +got) %s", diff)
+			}
+		})
+	}
+}
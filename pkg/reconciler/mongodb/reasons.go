@@ -0,0 +1,92 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"errors"
+
+	mongoclient "github.com/googleinterns/knative-source-mongodb/pkg/mongo"
+)
+
+// Reasons of the ConnectionEstablished condition when the connection check fails.
+const (
+	reasonSecretNotFound         = "SecretNotFound"
+	reasonSecretKeyMissing       = "SecretKeyMissing"
+	reasonInvalidURI             = "InvalidURI"
+	reasonInvalidTLS             = "InvalidTLS"
+	reasonAuthenticationFailed   = "AuthenticationFailed"
+	reasonServerUnreachable      = "ServerUnreachable"
+	reasonNotReplicaSet          = "NotReplicaSet"
+	reasonDatabaseNotFound       = "DatabaseNotFound"
+	reasonCollectionNotFound     = "CollectionNotFound"
+	reasonInsufficientPrivileges = "InsufficientPrivileges"
+	// reasonConnectionFailed is the reason of the failures that are not classified.
+	reasonConnectionFailed = "ConnectionFailed"
+
+	// reasonConnectionEstablished is the reason of the event sent when the connection check
+	// succeeds again.
+	reasonConnectionEstablished = "ConnectionEstablished"
+)
+
+// connectionError is a failure of the connection check, with the reason reported in the
+// ConnectionEstablished condition and a human-readable message.
+type connectionError struct {
+	reason  string
+	message string
+	err     error
+}
+
+// Error implements error.Error.
+func (e *connectionError) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+	return e.message + ": " + e.err.Error()
+}
+
+// Unwrap returns the error that made the check fail.
+func (e *connectionError) Unwrap() error {
+	return e.err
+}
+
+// connectionFailure returns a connectionError with the given reason.
+func connectionFailure(reason, message string, err error) error {
+	return &connectionError{reason: reason, message: message, err: err}
+}
+
+// operationFailure returns the connectionError of an operation of the connection check that
+// failed, classified by the error of the driver.
+func operationFailure(message string, err error) error {
+	switch {
+	case mongoclient.IsAuthenticationError(err):
+		return connectionFailure(reasonAuthenticationFailed, "Authentication to MongoDb failed", err)
+	case mongoclient.IsUnauthorizedError(err):
+		return connectionFailure(reasonInsufficientPrivileges, "The MongoDb user is not allowed to "+message, err)
+	case mongoclient.IsUnreachableError(err):
+		return connectionFailure(reasonServerUnreachable, "Unable to reach the MongoDb servers", err)
+	}
+	return connectionFailure(reasonConnectionFailed, "Unable to "+message, err)
+}
+
+// failureReason returns the reason and message of a failed connection check.
+func failureReason(err error) (string, string) {
+	var connErr *connectionError
+	if errors.As(err, &connErr) {
+		return connErr.reason, connErr.Error()
+	}
+	return reasonConnectionFailed, err.Error()
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/kmeta"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+)
+
+// MakeCheckpointConfigMap generates (but does not insert into K8s) the empty ConfigMap in which
+// the receive adapter stores its checkpoints.
+func MakeCheckpointConfigMap(src *v1alpha1.MongoDbSource, labels map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: src.Namespace,
+			Name:      checkpoint.ConfigMapName(src),
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*kmeta.NewControllerRef(src),
+			},
+		},
+	}
+}
+
+// Annotations of a retained checkpoint ConfigMap recording the stream of its checkpoints, which a
+// recreated source only adopts if it watches the same one.
+const (
+	RetainedDatabaseAnnotation   = "sources.google.com/retained-database"
+	RetainedCollectionAnnotation = "sources.google.com/retained-collection"
+	RetainedClusterAnnotation    = "sources.google.com/retained-cluster"
+)
+
+// RetainedCheckpointConfigMapName returns the name of the ConfigMap retaining the checkpoints of
+// a deleted MongoDbSource. Unlike checkpoint.ConfigMapName, it does not depend on the UID of the
+// source, so that the source recreated with the same name finds it.
+func RetainedCheckpointConfigMapName(src *v1alpha1.MongoDbSource) string {
+	return kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", src.Name), "retained-checkpoint")
+}
+
+// MakeRetainedCheckpointConfigMap generates (but does not insert into K8s) the ConfigMap, owned by
+// no source, retaining the checkpoints of a deleted MongoDbSource watching the given cluster.
+func MakeRetainedCheckpointConfigMap(src *v1alpha1.MongoDbSource, cluster string, labels map[string]string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: src.Namespace,
+			Name:      RetainedCheckpointConfigMapName(src),
+			Labels:    labels,
+			Annotations: map[string]string{
+				RetainedDatabaseAnnotation:   src.Spec.Database,
+				RetainedCollectionAnnotation: src.Spec.Collection,
+				RetainedClusterAnnotation:    cluster,
+			},
+		},
+		Data: data,
+	}
+}
+
+// RetainedCheckpointMatches returns whether the checkpoints retained in cm were made by a source
+// watching the same cluster, database and collection as src, so that src can resume from them.
+func RetainedCheckpointMatches(cm *corev1.ConfigMap, src *v1alpha1.MongoDbSource, cluster string) bool {
+	return cluster != "" &&
+		cm.Annotations[RetainedClusterAnnotation] == cluster &&
+		cm.Annotations[RetainedDatabaseAnnotation] == src.Spec.Database &&
+		cm.Annotations[RetainedCollectionAnnotation] == src.Spec.Collection
+}
+
+// RebalanceCheckpoints returns the checkpoint layout of the assignment of collections to
+// partitions, nil if the source is not partitioned. If the assignment changed, it also returns the
+// updated ConfigMap in which every checkpoint starts from the earliest of the previous ones, so
+// that no change is missed by the collections moving to another partition.
+func RebalanceCheckpoints(cm *corev1.ConfigMap, assignment [][]string) (*checkpoint.Layout, *corev1.ConfigMap, error) {
+	var current *checkpoint.Layout
+	if raw, found := cm.Data[checkpoint.LayoutKey]; found {
+		current = &checkpoint.Layout{}
+		if err := json.Unmarshal([]byte(raw), current); err != nil {
+			return nil, nil, fmt.Errorf("error unmarshalling checkpoint layout: %w", err)
+		}
+	}
+	if current == nil && assignment == nil {
+		return nil, nil, nil
+	}
+	if current != nil && assignment != nil && equality.Semantic.DeepEqual(current.Collections, assignment) {
+		return current, nil, nil
+	}
+
+	var previous []*checkpoint.Checkpoint
+	for key, raw := range cm.Data {
+		if key == checkpoint.LayoutKey || raw == "" {
+			continue
+		}
+		cp := &checkpoint.Checkpoint{}
+		if err := json.Unmarshal([]byte(raw), cp); err != nil {
+			return nil, nil, fmt.Errorf("error unmarshalling checkpoint %q: %w", key, err)
+		}
+		previous = append(previous, cp)
+	}
+
+	updated := cm.DeepCopy()
+	updated.Data = make(map[string]string)
+	var layout *checkpoint.Layout
+	if assignment != nil {
+		layout = &checkpoint.Layout{Epoch: 1, Collections: assignment}
+		if current != nil {
+			layout.Epoch = current.Epoch + 1
+		}
+		raw, err := json.Marshal(layout)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error marshalling checkpoint layout: %w", err)
+		}
+		updated.Data[checkpoint.LayoutKey] = string(raw)
+	}
+	if seed := checkpoint.Earliest(previous); seed != nil {
+		raw, err := json.Marshal(seed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error marshalling checkpoint: %w", err)
+		}
+		for _, key := range checkpointKeys(layout) {
+			updated.Data[key] = string(raw)
+		}
+	}
+	return layout, updated, nil
+}
+
+// RewindCheckpoints returns the updated ConfigMap in which the checkpoints made before the given
+// rewind, if any, start from its time instead. It returns nil if there is no such checkpoint.
+func RewindCheckpoints(cm *corev1.ConfigMap, layout *checkpoint.Layout, rewind string) (*corev1.ConfigMap, error) {
+	if rewind == "" {
+		return nil, nil
+	}
+	reset, err := checkpoint.NewRewind(rewind)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(reset)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling checkpoint: %w", err)
+	}
+
+	var updated *corev1.ConfigMap
+	for _, key := range checkpointKeys(layout) {
+		if current := cm.Data[key]; current != "" {
+			cp := &checkpoint.Checkpoint{}
+			if err := json.Unmarshal([]byte(current), cp); err != nil {
+				return nil, fmt.Errorf("error unmarshalling checkpoint %q: %w", key, err)
+			}
+			if cp.Rewind == rewind {
+				continue
+			}
+		}
+		if updated == nil {
+			updated = cm.DeepCopy()
+			if updated.Data == nil {
+				updated.Data = make(map[string]string)
+			}
+		}
+		updated.Data[key] = string(raw)
+	}
+	return updated, nil
+}
+
+// LastHistoryLost returns the last loss of the history of the change stream recorded in the
+// checkpoints of the ConfigMap, or nil if there is none.
+func LastHistoryLost(cm *corev1.ConfigMap) (*checkpoint.HistoryLost, error) {
+	var last *checkpoint.HistoryLost
+	for key, raw := range cm.Data {
+		if key == checkpoint.LayoutKey || raw == "" {
+			continue
+		}
+		cp := &checkpoint.Checkpoint{}
+		if err := json.Unmarshal([]byte(raw), cp); err != nil {
+			return nil, fmt.Errorf("error unmarshalling checkpoint %q: %w", key, err)
+		}
+		if cp.HistoryLost != nil && (last == nil || last.Time.Before(&cp.HistoryLost.Time)) {
+			last = cp.HistoryLost
+		}
+	}
+	return last, nil
+}
+
+// checkpointKeys returns the ConfigMap keys of the checkpoints of the given layout.
+func checkpointKeys(layout *checkpoint.Layout) []string {
+	if layout == nil {
+		return []string{checkpoint.DefaultKey}
+	}
+	keys := make([]string, 0, len(layout.Collections))
+	for i := range layout.Collections {
+		keys = append(keys, layout.PartitionKey(i))
+	}
+	return keys
+}
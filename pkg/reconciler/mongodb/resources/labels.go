@@ -16,6 +16,8 @@ limitations under the License.
 
 package resources
 
+import "strconv"
+
 const (
 	// controllerAgentName is the string used by this controller to identify
 	// itself when creating events.
@@ -29,3 +31,10 @@ func Labels(name string) map[string]string {
 		"google-source-name": name,
 	}
 }
+
+// PartitionLabels provides the Labels of the receive adapter of a partition.
+func PartitionLabels(name string, partition int) map[string]string {
+	labels := Labels(name)
+	labels["google-source-partition"] = strconv.Itoa(partition)
+	return labels
+}
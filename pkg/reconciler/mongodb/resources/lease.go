@@ -0,0 +1,53 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	"knative.dev/pkg/kmeta"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+)
+
+// LeaseName returns the name of the Lease through which the receive adapter replicas of a
+// MongoDbSource, or of one of its partitions, elect the one watching the change stream.
+func LeaseName(src *v1alpha1.MongoDbSource, partition *Partition) string {
+	suffix := "-leader"
+	if partition != nil {
+		suffix = fmt.Sprintf("-leader-%d", partition.Index)
+	}
+	return kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", src.Name), string(src.GetUID())+suffix)
+}
+
+// LeaseLabels returns the labels of the Leases of all the receive adapters of a MongoDbSource,
+// which they create themselves, whatever their partition.
+func LeaseLabels(src *v1alpha1.MongoDbSource) map[string]string {
+	return Labels(src.Name)
+}
+
+// LeaseNames returns the names of the Leases of the current receive adapters of a MongoDbSource.
+func LeaseNames(src *v1alpha1.MongoDbSource) []string {
+	if src.Spec.Partitioning == "" {
+		return []string{LeaseName(src, nil)}
+	}
+	names := make([]string, 0, PartitionCount(src))
+	for i := 0; i < PartitionCount(src); i++ {
+		names = append(names, LeaseName(src, &Partition{Index: i}))
+	}
+	return names
+}
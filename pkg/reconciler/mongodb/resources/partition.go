@@ -0,0 +1,84 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+)
+
+// Partition is the subset of the collections of a database watched by a receive adapter.
+type Partition struct {
+	Index         int
+	Collections   []string
+	CheckpointKey string
+}
+
+// PartitionCount returns the number of partitions of a partitioned MongoDbSource.
+func PartitionCount(src *v1alpha1.MongoDbSource) int {
+	if src.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*src.Spec.Replicas)
+}
+
+// AssignCollections assigns the collections of the database of a MongoDbSource to its partitions
+// according to its partitioning strategy. It returns nil if the source is not partitioned.
+func AssignCollections(src *v1alpha1.MongoDbSource, collections []string) [][]string {
+	if src.Spec.Partitioning == "" {
+		return nil
+	}
+	sorted := append([]string(nil), collections...)
+	sort.Strings(sorted)
+
+	assignment := make([][]string, PartitionCount(src))
+	for i, collection := range sorted {
+		partition := i % len(assignment)
+		if src.Spec.Partitioning == v1alpha1.PartitionByHash {
+			h := fnv.New32a()
+			h.Write([]byte(src.Spec.Database + "." + collection))
+			partition = int(h.Sum32() % uint32(len(assignment)))
+		}
+		assignment[partition] = append(assignment[partition], collection)
+	}
+	// Partitions without collections watch nothing rather than everything.
+	for i := range assignment {
+		if assignment[i] == nil {
+			assignment[i] = []string{}
+		}
+	}
+	return assignment
+}
+
+// MakePartitions returns the partitions of a checkpoint layout, or nil if there is none.
+func MakePartitions(layout *checkpoint.Layout) []Partition {
+	if layout == nil {
+		return nil
+	}
+	partitions := make([]Partition, 0, len(layout.Collections))
+	for i, collections := range layout.Collections {
+		partitions = append(partitions, Partition{
+			Index:         i,
+			Collections:   collections,
+			CheckpointKey: layout.PartitionKey(i),
+		})
+	}
+	return partitions
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+)
+
+func TestAssignCollections(t *testing.T) {
+	two := int32(2)
+	four := int32(4)
+	five := int32(5)
+	collections := []string{"d", "a", "c", "b"}
+
+	testCases := map[string]struct {
+		spec v1alpha1.MongoDbSourceSpec
+		want [][]string
+	}{
+		"not partitioned": {
+			spec: v1alpha1.MongoDbSourceSpec{Database: "db"},
+		},
+		"by collections": {
+			spec: v1alpha1.MongoDbSourceSpec{Database: "db", Partitioning: v1alpha1.PartitionByCollections, Replicas: &two},
+			want: [][]string{{"a", "c"}, {"b", "d"}},
+		},
+		"by hash": {
+			spec: v1alpha1.MongoDbSourceSpec{Database: "db", Partitioning: v1alpha1.PartitionByHash, Replicas: &four},
+			want: [][]string{{"c"}, {"d"}, {"a"}, {"b"}},
+		},
+		"more partitions than collections": {
+			spec: v1alpha1.MongoDbSourceSpec{Database: "db", Partitioning: v1alpha1.PartitionByCollections, Replicas: &five},
+			want: [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {}},
+		},
+		"single partition": {
+			spec: v1alpha1.MongoDbSourceSpec{Database: "db", Partitioning: v1alpha1.PartitionByHash},
+			want: [][]string{{"a", "b", "c", "d"}},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			got := AssignCollections(&v1alpha1.MongoDbSource{Spec: tc.spec}, collections)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected assignment (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestRebalanceCheckpoints(t *testing.T) {
+	const (
+		early  = `{"resumeToken":"{\"_data\":\"01\"}","operationTime":{"T":10,"I":1}}`
+		late   = `{"resumeToken":"{\"_data\":\"02\"}","operationTime":{"T":20,"I":1}}`
+		seed   = `{"operationTime":{"T":10,"I":1}}`
+		layout = `{"epoch":1,"collections":[["a"],["b"]]}`
+	)
+	assignment := [][]string{{"a"}, {"b"}}
+
+	testCases := map[string]struct {
+		data        map[string]string
+		assignment  [][]string
+		wantLayout  *checkpoint.Layout
+		wantUpdated map[string]string
+		wantErr     bool
+	}{
+		"not partitioned": {
+			data: map[string]string{checkpoint.DefaultKey: early},
+		},
+		"partitioning": {
+			data:       map[string]string{checkpoint.DefaultKey: early},
+			assignment: assignment,
+			wantLayout: &checkpoint.Layout{Epoch: 1, Collections: assignment},
+			wantUpdated: map[string]string{
+				checkpoint.LayoutKey: layout,
+				"checkpoint-1-0":     early,
+				"checkpoint-1-1":     early,
+			},
+		},
+		"partitioning without checkpoint": {
+			assignment: assignment,
+			wantLayout: &checkpoint.Layout{Epoch: 1, Collections: assignment},
+			wantUpdated: map[string]string{
+				checkpoint.LayoutKey: layout,
+			},
+		},
+		"unchanged partitions": {
+			data:       map[string]string{checkpoint.LayoutKey: layout, "checkpoint-1-0": early, "checkpoint-1-1": late},
+			assignment: assignment,
+			wantLayout: &checkpoint.Layout{Epoch: 1, Collections: assignment},
+		},
+		"rebalancing": {
+			data:       map[string]string{checkpoint.LayoutKey: layout, "checkpoint-1-0": late, "checkpoint-1-1": early},
+			assignment: [][]string{{"a", "b"}},
+			wantLayout: &checkpoint.Layout{Epoch: 2, Collections: [][]string{{"a", "b"}}},
+			wantUpdated: map[string]string{
+				checkpoint.LayoutKey: `{"epoch":2,"collections":[["a","b"]]}`,
+				"checkpoint-2-0":     seed,
+			},
+		},
+		"no longer partitioned": {
+			data: map[string]string{checkpoint.LayoutKey: layout, "checkpoint-1-0": late, "checkpoint-1-1": early},
+			wantUpdated: map[string]string{
+				checkpoint.DefaultKey: seed,
+			},
+		},
+		"invalid layout": {
+			data:       map[string]string{checkpoint.LayoutKey: "{"},
+			assignment: assignment,
+			wantErr:    true,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "checkpoint"},
+				Data:       tc.data,
+			}
+			gotLayout, gotUpdated, err := RebalanceCheckpoints(cm, tc.assignment)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error %v, want error=%v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantLayout, gotLayout); diff != "" {
+				t.Errorf("unexpected layout (-want, +got) = %v", diff)
+			}
+			var gotData map[string]string
+			if gotUpdated != nil {
+				gotData = gotUpdated.Data
+			}
+			if diff := cmp.Diff(tc.wantUpdated, gotData); diff != "" {
+				t.Errorf("unexpected checkpoints (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
+
+func TestRewindCheckpoints(t *testing.T) {
+	const (
+		rewind  = "2020-08-01T00:00:00Z"
+		before  = `{"resumeToken":"{\"_data\":\"01\"}","operationTime":{"T":10,"I":1}}`
+		after   = `{"resumeToken":"{\"_data\":\"02\"}","operationTime":{"T":1596240010,"I":1},"rewind":"2020-08-01T00:00:00Z"}`
+		rewound = `{"operationTime":{"T":1596240000,"I":0},"rewind":"2020-08-01T00:00:00Z"}`
+	)
+	layout := &checkpoint.Layout{Epoch: 1, Collections: [][]string{{"a"}, {"b"}}}
+
+	testCases := map[string]struct {
+		data        map[string]string
+		layout      *checkpoint.Layout
+		rewind      string
+		wantUpdated map[string]string
+		wantErr     bool
+	}{
+		"no rewind": {
+			data: map[string]string{checkpoint.DefaultKey: before},
+		},
+		"rewind": {
+			data:        map[string]string{checkpoint.DefaultKey: before},
+			rewind:      rewind,
+			wantUpdated: map[string]string{checkpoint.DefaultKey: rewound},
+		},
+		"rewind without checkpoint": {
+			rewind:      rewind,
+			wantUpdated: map[string]string{checkpoint.DefaultKey: rewound},
+		},
+		"already rewound": {
+			data:   map[string]string{checkpoint.DefaultKey: after},
+			rewind: rewind,
+		},
+		"rewind partitions": {
+			data:        map[string]string{"checkpoint-1-0": after, "checkpoint-1-1": before},
+			layout:      layout,
+			rewind:      rewind,
+			wantUpdated: map[string]string{"checkpoint-1-0": after, "checkpoint-1-1": rewound},
+		},
+		"invalid rewind": {
+			data:    map[string]string{checkpoint.DefaultKey: before},
+			rewind:  "yesterday",
+			wantErr: true,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "checkpoint"},
+				Data:       tc.data,
+			}
+			gotUpdated, err := RewindCheckpoints(cm, tc.layout, tc.rewind)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error %v, want error=%v", err, tc.wantErr)
+			}
+			var gotData map[string]string
+			if gotUpdated != nil {
+				gotData = gotUpdated.Data
+			}
+			if diff := cmp.Diff(tc.wantUpdated, gotData); diff != "" {
+				t.Errorf("unexpected checkpoints (-want, +got) = %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/kmeta"
+
+	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
+)
+
+// RoleName returns the name of the Role granting the receive adapter of a MongoDbSource access to
+// its Kubernetes resources.
+func RoleName(src *v1alpha1.MongoDbSource) string {
+	return kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", src.Name), string(src.GetUID()))
+}
+
+// MakeRole generates (but does not insert into K8s) the Role granting the receive adapter access
+// to its checkpoint ConfigMap and leader election Lease.
+func MakeRole(src *v1alpha1.MongoDbSource, labels map[string]string) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: src.Namespace,
+			Name:      RoleName(src),
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*kmeta.NewControllerRef(src),
+			},
+		},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{corev1.GroupName},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{checkpoint.ConfigMapName(src)},
+			Verbs:         []string{"get", "update"},
+		}, {
+			// Creations cannot be restricted by name.
+			APIGroups: []string{coordinationv1.GroupName},
+			Resources: []string{"leases"},
+			Verbs:     []string{"create"},
+		}, {
+			APIGroups:     []string{coordinationv1.GroupName},
+			Resources:     []string{"leases"},
+			ResourceNames: LeaseNames(src),
+			Verbs:         []string{"get", "update"},
+		}},
+	}
+}
+
+// MakeRoleBinding generates (but does not insert into K8s) the RoleBinding of the receive adapter
+// Role to the service account of the MongoDbSource.
+func MakeRoleBinding(src *v1alpha1.MongoDbSource, labels map[string]string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: src.Namespace,
+			Name:      RoleName(src),
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*kmeta.NewControllerRef(src),
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     RoleName(src),
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Namespace: src.Namespace,
+			Name:      src.Spec.ServiceAccountName,
+		}},
+	}
+}
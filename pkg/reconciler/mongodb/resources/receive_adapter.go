@@ -17,8 +17,12 @@ limitations under the License.
 package resources
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -29,33 +33,98 @@ import (
 	"knative.dev/pkg/system"
 
 	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
+	"github.com/googleinterns/knative-source-mongodb/pkg/checkpoint"
 )
 
 // ReceiveAdapterArgs are the arguments needed to create a MongoDbSource Receive Adapter.
-// Every field is required.
+// Every field is required but Partition, which is only set when partitioning.
 type ReceiveAdapterArgs struct {
-	Image          string
-	Labels         map[string]string
-	Source         *v1alpha1.MongoDbSource
-	CeSourcePrefix string
-	SinkURL        string
-	Configs        reconcilersource.ConfigAccessor
+	Image  string
+	Labels map[string]string
+	Source *v1alpha1.MongoDbSource
+	// ClusterName identifies the MongoDb deployment of the source in the CloudEvents source of
+	// its events.
+	ClusterName string
+	SinkURL     string
+	Configs     reconcilersource.ConfigAccessor
+	Partition   *Partition
+	// AdapterDefaults are the cluster-wide defaults of the pod template, overridden by the
+	// adapter field of the source. It may be nil.
+	AdapterDefaults *v1alpha1.MongoDbSourceAdapter
+}
+
+// ConfigHashAnnotation is the annotation of the pod template of a receive adapter holding a hash
+// of its pod spec, so that changing it rolls the receive adapter out. The contents of the secrets
+// it mounts are not hashed: the receive adapter reconnects by itself when they change.
+const ConfigHashAnnotation = "sources.google.com/config-hash"
+
+// MultiTenantAdapterName is the name of the Deployment of the multi-tenant receive adapter, in the
+// system namespace for the cluster-scoped sources, or in the namespace of the namespace-scoped
+// sources.
+const MultiTenantAdapterName = "mongodbsource-mt-adapter"
+
+// defaultShutdownGracePeriodSeconds is the default shutdown grace period of the receive adapter,
+// the default of MONGODB_SHUTDOWN_GRACE_PERIOD.
+const defaultShutdownGracePeriodSeconds = 20
+
+// shutdownTimeoutSeconds is the time given to the receive adapter on top of its shutdown grace
+// period to record its final checkpoint and disconnect.
+const shutdownTimeoutSeconds = 20
+
+// ReceiveAdapterName returns the name of the receive adapter Deployment of a MongoDbSource, or of
+// one of its partitions.
+func ReceiveAdapterName(src *v1alpha1.MongoDbSource, partition *Partition) string {
+	if partition == nil {
+		return kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", src.Name), string(src.GetUID()))
+	}
+	return kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", src.Name), fmt.Sprintf("%s-%d", src.GetUID(), partition.Index))
 }
 
 // MakeReceiveAdapter generates (but does not insert into K8s) the Receive Adapter Deployment for
 // MongoDb sources.
 func MakeReceiveAdapter(args *ReceiveAdapterArgs) (*v1.Deployment, error) {
 	replicas := int32(1)
+	if args.Source.Spec.Replicas != nil && args.Partition == nil {
+		replicas = *args.Source.Spec.Replicas
+	}
 
 	env, err := makeEnv(args)
 	if err != nil {
 		return nil, fmt.Errorf("error generating env vars: %w", err)
 	}
 
+	volumes, mounts := makeVolumes(args.Source)
+
+	// Give the receive adapter the time to send the pending changes and record its final
+	// checkpoint before it is killed.
+	terminationGracePeriodSeconds := int64(defaultShutdownGracePeriodSeconds + shutdownTimeoutSeconds)
+	if args.Source.Spec.ShutdownGracePeriodSeconds != nil {
+		terminationGracePeriodSeconds = *args.Source.Spec.ShutdownGracePeriodSeconds + shutdownTimeoutSeconds
+	}
+
+	podSpec := corev1.PodSpec{
+		ServiceAccountName:            args.Source.Spec.ServiceAccountName,
+		TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+		Containers: []corev1.Container{
+			{
+				Name:         "receive-adapter",
+				Image:        args.Image,
+				Env:          env,
+				VolumeMounts: mounts,
+			},
+		},
+		Volumes: volumes,
+	}
+	applyAdapter(&podSpec, mergeAdapter(args.AdapterDefaults, args.Source.Spec.Adapter))
+	hash, err := configHash(podSpec)
+	if err != nil {
+		return nil, err
+	}
+
 	return &v1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: args.Source.Namespace,
-			Name:      kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", args.Source.Name), string(args.Source.GetUID())),
+			Name:      ReceiveAdapterName(args.Source, args.Partition),
 			Labels:    args.Labels,
 			OwnerReferences: []metav1.OwnerReference{
 				*kmeta.NewControllerRef(args.Source),
@@ -68,47 +137,105 @@ func MakeReceiveAdapter(args *ReceiveAdapterArgs) (*v1.Deployment, error) {
 			Replicas: &replicas,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: args.Labels,
-				},
-				Spec: corev1.PodSpec{
-					ServiceAccountName: args.Source.Spec.ServiceAccountName,
-					Containers: []corev1.Container{
-						{
-							Name:  "receive-adapter",
-							Image: args.Image,
-							Env:   env,
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "mongodb-credentials",
-									MountPath: "/etc/mongodb-credentials",
-									ReadOnly:  true,
-								},
-							},
-						},
-					},
-					Volumes: []corev1.Volume{
-						{
-							Name: "mongodb-credentials",
-							VolumeSource: corev1.VolumeSource{
-								Secret: &corev1.SecretVolumeSource{
-									SecretName: args.Source.Spec.Secret.Name,
-								},
-							},
-						},
-					},
+					Labels:      args.Labels,
+					Annotations: map[string]string{ConfigHashAnnotation: hash},
 				},
+				Spec: podSpec,
 			},
 		},
 	}, nil
 }
 
+// mergeAdapter returns the pod template fields of a receive adapter set by the source adapter
+// field on top of the cluster-wide defaults. Either may be nil.
+func mergeAdapter(defaults, adapter *v1alpha1.MongoDbSourceAdapter) *v1alpha1.MongoDbSourceAdapter {
+	if defaults == nil {
+		return adapter
+	}
+	merged := defaults.DeepCopy()
+	if adapter == nil {
+		return merged
+	}
+	merged.Resources.Limits = mergeResources(merged.Resources.Limits, adapter.Resources.Limits)
+	merged.Resources.Requests = mergeResources(merged.Resources.Requests, adapter.Resources.Requests)
+	if len(adapter.NodeSelector) > 0 && merged.NodeSelector == nil {
+		merged.NodeSelector = make(map[string]string, len(adapter.NodeSelector))
+	}
+	for key, value := range adapter.NodeSelector {
+		merged.NodeSelector[key] = value
+	}
+	if adapter.Tolerations != nil {
+		merged.Tolerations = adapter.DeepCopy().Tolerations
+	}
+	if adapter.Affinity != nil {
+		merged.Affinity = adapter.Affinity.DeepCopy()
+	}
+	if adapter.PriorityClassName != "" {
+		merged.PriorityClassName = adapter.PriorityClassName
+	}
+	if adapter.PodSecurityContext != nil {
+		merged.PodSecurityContext = adapter.PodSecurityContext.DeepCopy()
+	}
+	if adapter.SecurityContext != nil {
+		merged.SecurityContext = adapter.SecurityContext.DeepCopy()
+	}
+	if adapter.ImagePullSecrets != nil {
+		merged.ImagePullSecrets = append([]corev1.LocalObjectReference(nil), adapter.ImagePullSecrets...)
+	}
+	return merged
+}
+
+// mergeResources returns the resource quantities of defaults replaced by those of overrides.
+func mergeResources(defaults, overrides corev1.ResourceList) corev1.ResourceList {
+	if len(overrides) > 0 && defaults == nil {
+		defaults = make(corev1.ResourceList, len(overrides))
+	}
+	for name, quantity := range overrides {
+		defaults[name] = quantity.DeepCopy()
+	}
+	return defaults
+}
+
+// applyAdapter sets the fields of the pod spec of a receive adapter from adapter, which may be
+// nil.
+func applyAdapter(podSpec *corev1.PodSpec, adapter *v1alpha1.MongoDbSourceAdapter) {
+	if adapter == nil {
+		return
+	}
+	adapter = adapter.DeepCopy()
+	podSpec.NodeSelector = adapter.NodeSelector
+	podSpec.Tolerations = adapter.Tolerations
+	podSpec.Affinity = adapter.Affinity
+	podSpec.PriorityClassName = adapter.PriorityClassName
+	podSpec.SecurityContext = adapter.PodSecurityContext
+	podSpec.ImagePullSecrets = adapter.ImagePullSecrets
+	podSpec.Containers[0].Resources = adapter.Resources
+	podSpec.Containers[0].SecurityContext = adapter.SecurityContext
+}
+
+// configHash returns the hex SHA-256 of the pod spec of a receive adapter.
+func configHash(podSpec corev1.PodSpec) (string, error) {
+	b, err := json.Marshal(struct {
+		PodSpec corev1.PodSpec `json:"podSpec"`
+	}{podSpec})
+	if err != nil {
+		return "", fmt.Errorf("failure to hash the receive adapter configuration: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func makeEnv(args *ReceiveAdapterArgs) ([]corev1.EnvVar, error) {
+	leaseLabels, err := json.Marshal(LeaseLabels(args.Source))
+	if err != nil {
+		return nil, fmt.Errorf("failure to marshal lease labels: %v", err)
+	}
 	envs := []corev1.EnvVar{{
 		Name:  adapter.EnvConfigSink,
 		Value: args.SinkURL,
 	}, {
-		Name:  "CE_SOURCE_PREFIX",
-		Value: args.CeSourcePrefix,
+		Name:  "MONGODB_CLUSTER_NAME",
+		Value: args.ClusterName,
 	}, {
 		Name:  "SYSTEM_NAMESPACE",
 		Value: system.Namespace(),
@@ -134,10 +261,121 @@ func makeEnv(args *ReceiveAdapterArgs) ([]corev1.EnvVar, error) {
 	}, {
 		Name:  "MONGODB_CREDENTIALS",
 		Value: "/etc/mongodb-credentials",
+	}, {
+		Name:  "MONGODB_CHECKPOINT_CONFIGMAP",
+		Value: checkpoint.ConfigMapName(args.Source),
+	}, {
+		Name:  "MONGODB_LEASE_NAME",
+		Value: LeaseName(args.Source, args.Partition),
+	}, {
+		Name:  "MONGODB_LEASE_LABELS",
+		Value: string(leaseLabels),
 	}}
 
 	envs = append(envs, args.Configs.ToEnvVars()...)
 
+	if args.Partition != nil {
+		collections, err := json.Marshal(args.Partition.Collections)
+		if err != nil {
+			return nil, fmt.Errorf("failure to marshal partition collections %v: %v", args.Partition.Collections, err)
+		}
+		envs = append(envs, corev1.EnvVar{
+			Name:  "MONGODB_CHECKPOINT_KEY",
+			Value: args.Partition.CheckpointKey,
+		}, corev1.EnvVar{
+			Name:  "MONGODB_PARTITION_COLLECTIONS",
+			Value: string(collections),
+		})
+	}
+
+	if args.Source.Spec.SourceTemplate != "" {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "MONGODB_SOURCE_TEMPLATE",
+			Value: args.Source.Spec.SourceTemplate,
+		})
+	}
+
+	if args.Source.Spec.ShutdownGracePeriodSeconds != nil {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "MONGODB_SHUTDOWN_GRACE_PERIOD",
+			Value: strconv.FormatInt(*args.Source.Spec.ShutdownGracePeriodSeconds, 10) + "s",
+		})
+	}
+
+	if keys := args.Source.Spec.Secret.Keys; keys != nil {
+		keysJSON, err := json.Marshal(keys)
+		if err != nil {
+			return nil, fmt.Errorf("failure to marshal secret keys %v: %v", keys, err)
+		}
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_SECRET_KEYS", Value: string(keysJSON)})
+	}
+
+	if conn := args.Source.Spec.Connection; conn != nil {
+		connJSON, err := json.Marshal(conn)
+		if err != nil {
+			return nil, fmt.Errorf("failure to marshal connection %v: %v", conn, err)
+		}
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_CONNECTION", Value: string(connJSON)})
+	}
+
+	if args.Source.Spec.TLS != nil {
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_TLS", Value: "true"})
+		for _, file := range tlsFiles(args.Source.Spec.TLS) {
+			envs = append(envs, corev1.EnvVar{Name: file.env, Value: tlsPath + "/" + file.name + "/" + file.path})
+		}
+	}
+
+	if args.Source.Spec.TraceParentField != "" {
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_TRACE_PARENT_FIELD", Value: args.Source.Spec.TraceParentField})
+	}
+
+	if snapshot := args.Source.Spec.Snapshot; snapshot != nil {
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_SNAPSHOT_MODE", Value: string(snapshot.Mode)})
+		if snapshot.BatchSize != nil {
+			envs = append(envs, corev1.EnvVar{
+				Name:  "MONGODB_SNAPSHOT_BATCH_SIZE",
+				Value: strconv.FormatInt(int64(*snapshot.BatchSize), 10),
+			})
+		}
+	}
+
+	if args.Source.Spec.Mode != "" {
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_MODE", Value: string(args.Source.Spec.Mode)})
+	}
+
+	if poll := args.Source.Spec.Poll; poll != nil {
+		if poll.Field != "" {
+			envs = append(envs, corev1.EnvVar{Name: "MONGODB_POLL_FIELD", Value: poll.Field})
+		}
+		if poll.IntervalSeconds != nil {
+			envs = append(envs, corev1.EnvVar{
+				Name:  "MONGODB_POLL_INTERVAL",
+				Value: (time.Duration(*poll.IntervalSeconds) * time.Second).String(),
+			})
+		}
+		if poll.DetectDeletes {
+			envs = append(envs, corev1.EnvVar{Name: "MONGODB_POLL_DETECT_DELETES", Value: "true"})
+		}
+	}
+
+	if args.Source.Spec.OnHistoryLost != "" {
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_ON_HISTORY_LOST", Value: string(args.Source.Spec.OnHistoryLost)})
+	}
+
+	if startAt := args.Source.Spec.StartAt; startAt != nil {
+		if startAt.Time != nil {
+			envs = append(envs, corev1.EnvVar{Name: "MONGODB_START_AT_TIME", Value: startAt.Time.UTC().Format(time.RFC3339)})
+		}
+		if startAt.ResumeToken != "" {
+			envs = append(envs, corev1.EnvVar{Name: "MONGODB_START_AT_RESUME_TOKEN", Value: startAt.ResumeToken})
+		}
+	}
+
+	// Changing the rewind restarts the receive adapter.
+	if rewind, ok := args.Source.Annotations[v1alpha1.RewindAnnotation]; ok {
+		envs = append(envs, corev1.EnvVar{Name: "MONGODB_REWIND", Value: rewind})
+	}
+
 	if args.Source.Spec.CloudEventOverrides != nil && args.Source.Spec.CloudEventOverrides.Extensions != nil {
 		ceJSON, err := json.Marshal(args.Source.Spec.CloudEventOverrides.Extensions)
 		if err != nil {
@@ -148,3 +386,66 @@ func makeEnv(args *ReceiveAdapterArgs) ([]corev1.EnvVar, error) {
 	return envs, nil
 
 }
+
+// tlsPath is where the TLS files are mounted in the receive adapter.
+const tlsPath = "/etc/mongodb-tls"
+
+// tlsFile is a TLS file mounted in the receive adapter, under tlsPath/name/path, from the selected
+// key of a secret.
+type tlsFile struct {
+	name, path, env string
+	selector        *corev1.SecretKeySelector
+}
+
+// tlsFiles returns the TLS files selected by tls.
+func tlsFiles(tls *v1alpha1.MongoDbSourceTLS) []tlsFile {
+	var files []tlsFile
+	for _, file := range []tlsFile{
+		{name: "ca", path: "ca.crt", env: "MONGODB_TLS_CA_FILE", selector: tls.CA},
+		{name: "certificate", path: "tls.crt", env: "MONGODB_TLS_CERTIFICATE_FILE", selector: tls.Certificate},
+		{name: "key", path: "tls.key", env: "MONGODB_TLS_KEY_FILE", selector: tls.Key},
+	} {
+		if file.selector != nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// makeVolumes returns the volumes of the receive adapter holding the credentials and the TLS
+// files, and their mounts.
+func makeVolumes(src *v1alpha1.MongoDbSource) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{{
+		Name: "mongodb-credentials",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: src.Spec.Secret.Name,
+			},
+		},
+	}}
+	mounts := []corev1.VolumeMount{{
+		Name:      "mongodb-credentials",
+		MountPath: "/etc/mongodb-credentials",
+		ReadOnly:  true,
+	}}
+	if src.Spec.TLS == nil {
+		return volumes, mounts
+	}
+	for _, file := range tlsFiles(src.Spec.TLS) {
+		volumes = append(volumes, corev1.Volume{
+			Name: "mongodb-tls-" + file.name,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: file.selector.Name,
+					Items:      []corev1.KeyToPath{{Key: file.selector.Key, Path: file.path}},
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "mongodb-tls-" + file.name,
+			MountPath: tlsPath + "/" + file.name,
+			ReadOnly:  true,
+		})
+	}
+	return volumes, mounts
+}
@@ -19,11 +19,13 @@ package resources
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/googleinterns/knative-source-mongodb/pkg/apis/sources/v1alpha1"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/eventing/pkg/reconciler/source"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
@@ -33,6 +35,7 @@ import (
 )
 
 func TestMakeReceiveAdapter(t *testing.T) {
+	defaultTerminationSeconds := int64(40)
 	name := "source-name"
 	src := &v1alpha1.MongoDbSource{
 		ObjectMeta: metav1.ObjectMeta{
@@ -42,7 +45,7 @@ func TestMakeReceiveAdapter(t *testing.T) {
 		},
 		Spec: v1alpha1.MongoDbSourceSpec{
 			ServiceAccountName: "source-svc-acct",
-			Secret:             corev1.LocalObjectReference{Name: "my-mongo-secret"},
+			Secret:             v1alpha1.MongoDbSourceSecret{LocalObjectReference: corev1.LocalObjectReference{Name: "my-mongo-secret"}},
 			Database:           "db",
 			Collection:         "coll",
 		},
@@ -86,7 +89,8 @@ func TestMakeReceiveAdapter(t *testing.T) {
 					},
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: "source-svc-acct",
+					ServiceAccountName:            "source-svc-acct",
+					TerminationGracePeriodSeconds: &defaultTerminationSeconds,
 					Containers: []corev1.Container{
 						{
 							Name:  "receive-adapter",
@@ -96,8 +100,8 @@ func TestMakeReceiveAdapter(t *testing.T) {
 									Name:  "K_SINK",
 									Value: "sink-uri",
 								}, {
-									Name:  "CE_SOURCE_PREFIX",
-									Value: "mongodb://",
+									Name:  "MONGODB_CLUSTER_NAME",
+									Value: "rs0",
 								}, {
 									Name:  "SYSTEM_NAMESPACE",
 									Value: "knative-testing",
@@ -123,6 +127,15 @@ func TestMakeReceiveAdapter(t *testing.T) {
 								}, {
 									Name:  "MONGODB_CREDENTIALS",
 									Value: "/etc/mongodb-credentials",
+								}, {
+									Name:  "MONGODB_CHECKPOINT_CONFIGMAP",
+									Value: kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", name), "sampleUID-checkpoint"),
+								}, {
+									Name:  "MONGODB_LEASE_NAME",
+									Value: kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", name), "sampleUID-leader"),
+								}, {
+									Name:  "MONGODB_LEASE_LABELS",
+									Value: fmt.Sprintf(`{"google-source":"controller","google-source-name":%q}`, name),
 								}, {
 									Name:  source.EnvLoggingCfg,
 									Value: "",
@@ -164,9 +177,206 @@ func TestMakeReceiveAdapter(t *testing.T) {
 		Value: `{"1":"one"}`,
 	})
 
+	traceSrc := src.DeepCopy()
+	traceSrc.Spec.TraceParentField = "traceparent"
+	traceWant := want.DeepCopy()
+	traceWant.Spec.Template.Spec.Containers[0].Env = append(traceWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_TRACE_PARENT_FIELD",
+		Value: "traceparent",
+	})
+
+	keysSrc := src.DeepCopy()
+	keysSrc.Spec.Secret.Keys = &v1alpha1.MongoDbSourceSecretKeys{Hosts: "mongo-hosts", Password: "mongo-password"}
+	keysWant := want.DeepCopy()
+	keysWant.Spec.Template.Spec.Containers[0].Env = append(keysWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_SECRET_KEYS",
+		Value: `{"hosts":"mongo-hosts","password":"mongo-password"}`,
+	})
+
+	connBatchSize := int32(50)
+	connSrc := src.DeepCopy()
+	connSrc.Spec.Connection = &v1alpha1.MongoDbSourceConnection{ReadConcern: "majority", BatchSize: &connBatchSize}
+	connWant := want.DeepCopy()
+	connWant.Spec.Template.Spec.Containers[0].Env = append(connWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_CONNECTION",
+		Value: `{"readConcern":"majority","batchSize":50}`,
+	})
+
+	tlsSrc := src.DeepCopy()
+	tlsSrc.Spec.TLS = &v1alpha1.MongoDbSourceTLS{
+		CA: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "mongo-ca"}, Key: "ca.pem"},
+		Certificate: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "mongo-client"},
+			Key:                  "tls.crt",
+		},
+		Key: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "mongo-client"}, Key: "tls.key"},
+	}
+	tlsWant := want.DeepCopy()
+	tlsContainer := &tlsWant.Spec.Template.Spec.Containers[0]
+	tlsContainer.Env = append(tlsContainer.Env, corev1.EnvVar{
+		Name:  "MONGODB_TLS",
+		Value: "true",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_TLS_CA_FILE",
+		Value: "/etc/mongodb-tls/ca/ca.crt",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_TLS_CERTIFICATE_FILE",
+		Value: "/etc/mongodb-tls/certificate/tls.crt",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_TLS_KEY_FILE",
+		Value: "/etc/mongodb-tls/key/tls.key",
+	})
+	for _, file := range []struct{ name, secret, key, path string }{
+		{"ca", "mongo-ca", "ca.pem", "ca.crt"},
+		{"certificate", "mongo-client", "tls.crt", "tls.crt"},
+		{"key", "mongo-client", "tls.key", "tls.key"},
+	} {
+		tlsContainer.VolumeMounts = append(tlsContainer.VolumeMounts, corev1.VolumeMount{
+			Name:      "mongodb-tls-" + file.name,
+			MountPath: "/etc/mongodb-tls/" + file.name,
+			ReadOnly:  true,
+		})
+		tlsWant.Spec.Template.Spec.Volumes = append(tlsWant.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: "mongodb-tls-" + file.name,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: file.secret,
+					Items:      []corev1.KeyToPath{{Key: file.key, Path: file.path}},
+				},
+			},
+		})
+	}
+
+	snapshotSrc := src.DeepCopy()
+	batchSize := int32(500)
+	snapshotSrc.Spec.Snapshot = &v1alpha1.MongoDbSourceSnapshot{Mode: v1alpha1.SnapshotInitial, BatchSize: &batchSize}
+	snapshotWant := want.DeepCopy()
+	snapshotWant.Spec.Template.Spec.Containers[0].Env = append(snapshotWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_SNAPSHOT_MODE",
+		Value: "initial",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_SNAPSHOT_BATCH_SIZE",
+		Value: "500",
+	})
+
+	pollSrc := src.DeepCopy()
+	pollInterval := int64(30)
+	pollSrc.Spec.Mode = v1alpha1.SourceModePoll
+	pollSrc.Spec.Poll = &v1alpha1.MongoDbSourcePoll{Field: "updatedAt", IntervalSeconds: &pollInterval, DetectDeletes: true}
+	pollWant := want.DeepCopy()
+	pollWant.Spec.Template.Spec.Containers[0].Env = append(pollWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_MODE",
+		Value: "poll",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_POLL_FIELD",
+		Value: "updatedAt",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_POLL_INTERVAL",
+		Value: "30s",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_POLL_DETECT_DELETES",
+		Value: "true",
+	})
+
+	startAtSrc := src.DeepCopy()
+	startAtSrc.Annotations = map[string]string{v1alpha1.RewindAnnotation: "2020-08-02T00:00:00Z"}
+	startAtSrc.Spec.StartAt = &v1alpha1.MongoDbSourceStartAt{Time: &metav1.Time{Time: time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC)}}
+	startAtWant := want.DeepCopy()
+	startAtWant.Spec.Template.Spec.Containers[0].Env = append(startAtWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_START_AT_TIME",
+		Value: "2020-08-01T00:00:00Z",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_REWIND",
+		Value: "2020-08-02T00:00:00Z",
+	})
+
+	graceSrc := src.DeepCopy()
+	graceSeconds := int64(30)
+	graceSrc.Spec.ShutdownGracePeriodSeconds = &graceSeconds
+	graceWant := want.DeepCopy()
+	terminationSeconds := int64(50)
+	graceWant.Spec.Template.Spec.TerminationGracePeriodSeconds = &terminationSeconds
+	graceWant.Spec.Template.Spec.Containers[0].Env = append(graceWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_SHUTDOWN_GRACE_PERIOD",
+		Value: "30s",
+	})
+
+	templateSrc := src.DeepCopy()
+	templateSrc.Spec.SourceTemplate = "mongodb://{{.Cluster}}/{{.Database}}"
+	templateWant := want.DeepCopy()
+	templateWant.Spec.Template.Spec.Containers[0].Env = append(templateWant.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  "MONGODB_SOURCE_TEMPLATE",
+		Value: "mongodb://{{.Cluster}}/{{.Database}}",
+	})
+
+	replicasSrc := src.DeepCopy()
+	three := int32(3)
+	replicasSrc.Spec.Replicas = &three
+	replicasWant := want.DeepCopy()
+	replicasWant.Spec.Replicas = &three
+
+	partitionSrc := replicasSrc.DeepCopy()
+	partitionSrc.Spec.Collection = ""
+	partitionSrc.Spec.Partitioning = v1alpha1.PartitionByCollections
+	partition := &Partition{Index: 1, Collections: []string{"coll"}, CheckpointKey: "checkpoint-1-1"}
+	partitionWant := want.DeepCopy()
+	partitionWant.Name = kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", name), "sampleUID-1")
+	partitionEnv := partitionWant.Spec.Template.Spec.Containers[0].Env
+	for i := range partitionEnv {
+		switch partitionEnv[i].Name {
+		case "MONGODB_COLLECTION":
+			partitionEnv[i].Value = ""
+		case "MONGODB_LEASE_NAME":
+			partitionEnv[i].Value = kmeta.ChildName(fmt.Sprintf("mongodbsource-%s-", name), "sampleUID-leader-1")
+		}
+	}
+	partitionWant.Spec.Template.Spec.Containers[0].Env = append(partitionEnv, corev1.EnvVar{
+		Name:  "MONGODB_CHECKPOINT_KEY",
+		Value: "checkpoint-1-1",
+	}, corev1.EnvVar{
+		Name:  "MONGODB_PARTITION_COLLECTIONS",
+		Value: `["coll"]`,
+	})
+
+	// The source adapter field overrides the cluster-wide defaults.
+	adapterDefaults := &v1alpha1.MongoDbSourceAdapter{
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			},
+		},
+		NodeSelector:      map[string]string{"kubernetes.io/os": "linux"},
+		PriorityClassName: "sources",
+		ImagePullSecrets:  []corev1.LocalObjectReference{{Name: "registry"}},
+	}
+	nonRoot := true
+	adapterSrc := src.DeepCopy()
+	adapterSrc.Spec.Adapter = &v1alpha1.MongoDbSourceAdapter{
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+		},
+		Tolerations:        []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		PodSecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+	}
+	adapterWant := want.DeepCopy()
+	adapterWant.Spec.Template.Spec.NodeSelector = map[string]string{"kubernetes.io/os": "linux"}
+	adapterWant.Spec.Template.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+	adapterWant.Spec.Template.Spec.PriorityClassName = "sources"
+	adapterWant.Spec.Template.Spec.SecurityContext = &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot}
+	adapterWant.Spec.Template.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	adapterWant.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+	}
+
 	testCases := map[string]struct {
-		want *v1.Deployment
-		src  *v1alpha1.MongoDbSource
+		want            *v1.Deployment
+		src             *v1alpha1.MongoDbSource
+		partition       *Partition
+		adapterDefaults *v1alpha1.MongoDbSourceAdapter
 	}{
 		"TestMakeReceiveAdapter": {
 			want: want,
@@ -174,6 +384,44 @@ func TestMakeReceiveAdapter(t *testing.T) {
 		}, "TestMakeReceiveAdapterWithExtensionOverride": {
 			want: ceWant,
 			src:  ceSrc,
+		}, "TestMakeReceiveAdapterWithShutdownGracePeriod": {
+			want: graceWant,
+			src:  graceSrc,
+		}, "TestMakeReceiveAdapterWithSourceTemplate": {
+			want: templateWant,
+			src:  templateSrc,
+		}, "TestMakeReceiveAdapterWithReplicas": {
+			want: replicasWant,
+			src:  replicasSrc,
+		}, "TestMakeReceiveAdapterWithPartition": {
+			want:      partitionWant,
+			src:       partitionSrc,
+			partition: partition,
+		}, "TestMakeReceiveAdapterWithSecretKeys": {
+			want: keysWant,
+			src:  keysSrc,
+		}, "TestMakeReceiveAdapterWithConnection": {
+			want: connWant,
+			src:  connSrc,
+		}, "TestMakeReceiveAdapterWithTLS": {
+			want: tlsWant,
+			src:  tlsSrc,
+		}, "TestMakeReceiveAdapterWithSnapshot": {
+			want: snapshotWant,
+			src:  snapshotSrc,
+		}, "TestMakeReceiveAdapterWithPoll": {
+			want: pollWant,
+			src:  pollSrc,
+		}, "TestMakeReceiveAdapterWithStartAtAndRewind": {
+			want: startAtWant,
+			src:  startAtSrc,
+		}, "TestMakeReceiveAdapterWithTraceParentField": {
+			want: traceWant,
+			src:  traceSrc,
+		}, "TestMakeReceiveAdapterWithAdapter": {
+			want:            adapterWant,
+			src:             adapterSrc,
+			adapterDefaults: adapterDefaults,
 		},
 	}
 
@@ -187,15 +435,79 @@ func TestMakeReceiveAdapter(t *testing.T) {
 					"test-key1": "test-value1",
 					"test-key2": "test-value2",
 				},
-				SinkURL:        "sink-uri",
-				CeSourcePrefix: "mongodb://",
-				Configs:        &source.EmptyVarsGenerator{},
+				SinkURL:         "sink-uri",
+				ClusterName:     "rs0",
+				Configs:         &source.EmptyVarsGenerator{},
+				Partition:       tc.partition,
+				AdapterDefaults: tc.adapterDefaults,
 			})
 
-			if diff := cmp.Diff(tc.want, got); diff != "" {
+			// The pod template is annotated with the hash of its spec.
+			want := tc.want.DeepCopy()
+			hash, err := configHash(want.Spec.Template.Spec)
+			if err != nil {
+				t.Fatalf("configHash got error %v", err)
+			}
+			want.Spec.Template.Annotations = map[string]string{ConfigHashAnnotation: hash}
+
+			if diff := cmp.Diff(want, got); diff != "" {
 				t.Errorf("unexpected deploy (-want, +got) = %v", diff)
 			}
 
 		})
 	}
 }
+
+func TestConfigHash(t *testing.T) {
+	spec := corev1.PodSpec{
+		ServiceAccountName: "source-svc-acct",
+		Containers:         []corev1.Container{{Name: "receive-adapter", Image: "test-image"}},
+	}
+	hash := func(spec corev1.PodSpec) string {
+		t.Helper()
+		h, err := configHash(spec)
+		if err != nil {
+			t.Fatalf("configHash got error %v", err)
+		}
+		return h
+	}
+
+	base := hash(spec)
+	if got := hash(spec); got != base {
+		t.Errorf("configHash is not stable: got %s, want %s", got, base)
+	}
+	changed := *spec.DeepCopy()
+	changed.ServiceAccountName = "other-svc-acct"
+	if got := hash(changed); got == base {
+		t.Error("configHash did not change with the pod spec")
+	}
+}
+
+func TestMergeAdapter(t *testing.T) {
+	defaults := &v1alpha1.MongoDbSourceAdapter{
+		NodeSelector:      map[string]string{"kubernetes.io/os": "linux"},
+		PriorityClassName: "sources",
+	}
+	if got := mergeAdapter(nil, nil); got != nil {
+		t.Errorf("mergeAdapter of nothing got %v, want nil", got)
+	}
+	if diff := cmp.Diff(defaults, mergeAdapter(defaults, nil)); diff != "" {
+		t.Errorf("mergeAdapter without source adapter (-want, +got) = %v", diff)
+	}
+
+	got := mergeAdapter(defaults, &v1alpha1.MongoDbSourceAdapter{
+		NodeSelector:      map[string]string{"topology.kubernetes.io/zone": "a"},
+		PriorityClassName: "critical",
+	})
+	want := &v1alpha1.MongoDbSourceAdapter{
+		NodeSelector:      map[string]string{"kubernetes.io/os": "linux", "topology.kubernetes.io/zone": "a"},
+		PriorityClassName: "critical",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mergeAdapter (-want, +got) = %v", diff)
+	}
+	// The defaults are shared by all the sources.
+	if len(defaults.NodeSelector) != 1 {
+		t.Errorf("mergeAdapter modified the defaults: %v", defaults.NodeSelector)
+	}
+}
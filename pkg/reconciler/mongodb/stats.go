@@ -0,0 +1,56 @@
+/*
+Copyright 2020 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	// checkLatencyStat is the latency of the connection checks that were not cached.
+	checkLatencyStat = stats.Float64("mongodb_connection_check_latency", "Latency of the MongoDb connection checks", stats.UnitMilliseconds)
+
+	// successTagKey tells whether the check succeeded.
+	successTagKey = tag.MustNewKey("success")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: checkLatencyStat.Description(),
+		Measure:     checkLatencyStat,
+		Aggregation: view.Distribution(metrics.Buckets125(1, 60000)...),
+		TagKeys:     []tag.Key{successTagKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// reportCheckLatency records the latency of a connection check, and whether it succeeded.
+func reportCheckLatency(ctx context.Context, latency time.Duration, success bool) {
+	ctx, err := tag.New(ctx, tag.Insert(successTagKey, strconv.FormatBool(success)))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, checkLatencyStat.M(float64(latency)/float64(time.Millisecond)))
+}
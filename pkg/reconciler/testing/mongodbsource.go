@@ -18,7 +18,7 @@ package testing
 
 import (
 	"context"
-	"errors"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -52,6 +52,26 @@ func WithMongoDbSourceUID(uid string) MongoDbSourceOption {
 	}
 }
 
+// WithMongoDbSourceAnnotations sets the annotations of the MongoDbSource.
+func WithMongoDbSourceAnnotations(annotations map[string]string) MongoDbSourceOption {
+	return func(c *v1alpha1.MongoDbSource) {
+		c.Annotations = annotations
+	}
+}
+
+// WithMongoDbSourceFinalizers sets the finalizers of the MongoDbSource.
+func WithMongoDbSourceFinalizers(finalizers ...string) MongoDbSourceOption {
+	return func(c *v1alpha1.MongoDbSource) {
+		c.Finalizers = finalizers
+	}
+}
+
+// WithMongoDbSourceDeleted marks the MongoDbSource as being deleted.
+func WithMongoDbSourceDeleted(c *v1alpha1.MongoDbSource) {
+	t := metav1.NewTime(time.Unix(1e9, 0))
+	c.SetDeletionTimestamp(&t)
+}
+
 // WithInitMongoDbSourceConditions initializes the MongoDbSource's conditions.
 func WithInitMongoDbSourceConditions(s *v1alpha1.MongoDbSource) {
 	s.Status.InitializeConditions()
@@ -77,9 +97,9 @@ func WithMongoDbSourceSink(uri *apis.URL) MongoDbSourceOption {
 }
 
 // WithMongoDbSourceConnectionFailed updates the status of the connection to be failed.
-func WithMongoDbSourceConnectionFailed(err string) MongoDbSourceOption {
+func WithMongoDbSourceConnectionFailed(reason, message string) MongoDbSourceOption {
 	return func(s *v1alpha1.MongoDbSource) {
-		s.Status.MarkConnectionFailed(errors.New(err))
+		s.Status.MarkConnectionFailed(reason, "%s", message)
 	}
 }
 
@@ -90,6 +110,34 @@ func WithMongoDbSourceConnectionSuccess() MongoDbSourceOption {
 	}
 }
 
+// WithMongoDbSourceTopology sets the topology of the deployment found by the connection check.
+func WithMongoDbSourceTopology(topology *v1alpha1.MongoDbSourceTopology) MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.Topology = topology
+	}
+}
+
+// WithMongoDbSourceNamespacePending updates the status of the database or collection to be pending.
+func WithMongoDbSourceNamespacePending(reason, message string) MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.MarkNamespacePending(reason, "%s", message)
+	}
+}
+
+// WithMongoDbSourceChangeStreamSupported updates the status of the prerequisites to be met.
+func WithMongoDbSourceChangeStreamSupported() MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.MarkChangeStreamSupported()
+	}
+}
+
+// WithMongoDbSourceChangeStreamNotSupported updates the status of the prerequisites to be missing.
+func WithMongoDbSourceChangeStreamNotSupported(reason, message string) MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.MarkChangeStreamNotSupported(reason, "%s", message)
+	}
+}
+
 // WithMongoDbSourceNotDeployed updates the status of the source to Not Deployed.
 func WithMongoDbSourceNotDeployed(name string) MongoDbSourceOption {
 	return func(s *v1alpha1.MongoDbSource) {
@@ -97,9 +145,38 @@ func WithMongoDbSourceNotDeployed(name string) MongoDbSourceOption {
 	}
 }
 
+// WithMongoDbSourceNoDeployment updates the status of the source to Not Deployed for the given
+// reason.
+func WithMongoDbSourceNoDeployment(reason, message string) MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.MarkNoDeployment(reason, "%s", message)
+	}
+}
+
 // WithMongoDbSourceDeployed updates the status of the source to Deployed.
 func WithMongoDbSourceDeployed() MongoDbSourceOption {
 	return func(s *v1alpha1.MongoDbSource) {
 		s.Status.PropagateDeploymentAvailability(NewDeployment("any", "any", WithDeploymentAvailable()))
 	}
 }
+
+// WithMongoDbSourceHistoryLost updates the status of the source to History Lost.
+func WithMongoDbSourceHistoryLost(policy v1alpha1.HistoryLostPolicy, message string) MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.MarkHistoryLost(policy, message)
+	}
+}
+
+// WithMongoDbSourceSuspended updates the status of the source to Suspended.
+func WithMongoDbSourceSuspended() MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.MarkSuspended()
+	}
+}
+
+// WithMongoDbSourcePartitions sets the partitions of the source.
+func WithMongoDbSourcePartitions(partitions ...v1alpha1.MongoDbSourcePartition) MongoDbSourceOption {
+	return func(s *v1alpha1.MongoDbSource) {
+		s.Status.Partitions = partitions
+	}
+}